@@ -2,7 +2,11 @@ module clog
 
 go 1.24
 
-require github.com/duckdb/duckdb-go/v2 v2.5.0
+require (
+	github.com/duckdb/duckdb-go/v2 v2.5.0
+	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/term v0.34.0
+)
 
 require (
 	github.com/apache/arrow-go/v18 v18.4.1 // indirect
@@ -0,0 +1,97 @@
+// Package bgjob runs clog work in a detached process, so a hook can hand
+// off slow work such as embedding and return to Claude straight away.
+package bgjob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// ErrLocked is returned by Lock when another process holds the lock.
+var ErrLocked = errors.New("locked by another process")
+
+// maxLogSize is the size at which Start truncates a job's log file.
+const maxLogSize = 1 << 20
+
+// Lock takes the lock file at path, returning a function that releases
+// it. A lock file older than stale is assumed to belong to a process that
+// died without releasing it, and is taken over.
+func Lock(path string, stale time.Duration) (release func(), err error) {
+	owner := []byte(strconv.Itoa(os.Getpid()) + " " + time.Now().UTC().Format(time.RFC3339Nano) + "\n")
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, werr := f.Write(owner)
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("write lock %s: %w", path, werr)
+			}
+			return func() { releaseLock(path, owner) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("create lock %s: %w", path, err)
+		}
+		fi, serr := os.Stat(path)
+		if attempt > 0 || serr != nil || time.Since(fi.ModTime()) < stale {
+			return nil, ErrLocked
+		}
+		os.Remove(path)
+	}
+}
+
+// Refresh marks the lock file at path as held now, so a job that runs
+// longer than the stale age in small steps isn't taken over while it
+// works.
+func Refresh(path string) error {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return fmt.Errorf("refresh lock %s: %w", path, err)
+	}
+	return nil
+}
+
+// releaseLock removes the lock file unless it has since been taken over.
+func releaseLock(path string, owner []byte) {
+	if b, err := os.ReadFile(path); err == nil && bytes.Equal(b, owner) {
+		os.Remove(path)
+	}
+}
+
+// Start runs the current executable with args in dir, detached from the
+// caller's session, appending its output to logPath. It does not wait for
+// the process to exit.
+func Start(dir, logPath string, args ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find executable: %w", err)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if fi, err := os.Stat(logPath); err == nil && fi.Size() > maxLogSize {
+		flags |= os.O_TRUNC
+	}
+	logFile, err := os.OpenFile(logPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("open job log: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = dir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start %s: %w", args, err)
+	}
+	// The job outlives us; don't leave the handle for it open.
+	return cmd.Process.Release()
+}
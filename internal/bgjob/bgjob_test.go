@@ -0,0 +1,69 @@
+package bgjob
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock_WhenHeld_ShouldReturnErrLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+	release, err := Lock(path, time.Hour)
+	if err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	if _, err := Lock(path, time.Hour); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	release()
+	release2, err := Lock(path, time.Hour)
+	if err != nil {
+		t.Fatalf("expected the lock free after release, got %v", err)
+	}
+	release2()
+}
+
+func TestLock_WhenStale_ShouldTakeItOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+	release, err := Lock(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path, old, old)
+
+	release2, err := Lock(path, time.Hour)
+	if err != nil {
+		t.Fatalf("expected a stale lock taken over, got %v", err)
+	}
+	// The first holder must not release a lock it no longer owns.
+	release()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the new holder's lock kept, got %v", err)
+	}
+	release2()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the lock removed, got %v", err)
+	}
+}
+
+func TestRefresh_ShouldKeepALongJobsLockFromGoingStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+	release, err := Lock(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path, old, old)
+
+	if err := Refresh(path); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if _, err := Lock(path, time.Hour); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected the refreshed lock kept, got %v", err)
+	}
+}
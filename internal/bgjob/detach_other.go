@@ -0,0 +1,8 @@
+//go:build !unix
+
+package bgjob
+
+import "os/exec"
+
+// detach is a no-op where there are no sessions to leave.
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package bgjob
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts cmd in a new session, so it survives the hook's process
+// group being signalled when the hook exits.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
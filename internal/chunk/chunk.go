@@ -0,0 +1,179 @@
+// Package chunk splits long messages into overlapping windows sized for
+// an embedding model's input limit.
+package chunk
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken is a deliberately low estimate (English prose averages
+// about four) so that code and logs, which tokenize densely, still fit.
+const charsPerToken = 3
+
+// Default window sizes. Small windows keep each vector focused on one
+// topic; the overlap keeps sentences that straddle a boundary findable.
+const (
+	DefaultMaxTokens     = 512
+	DefaultOverlapTokens = 64
+)
+
+// Chunk is a window of a message. Start and End are byte offsets into the
+// original text, so Text == text[Start:End].
+type Chunk struct {
+	Index int
+	Start int
+	End   int
+	Text  string
+}
+
+// Options bounds the size of each chunk and of the overlap between
+// consecutive chunks, both in estimated tokens.
+type Options struct {
+	MaxTokens     int
+	OverlapTokens int
+}
+
+// EstimateTokens approximates how many tokens s costs a typical embedding
+// model's tokenizer.
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + charsPerToken - 1) / charsPerToken
+}
+
+// Split cuts text into chunks of at most opts.MaxTokens. Cuts fall between
+// paragraphs where possible, never inside a fenced code block unless the
+// block alone is too large, and then between lines. Text that fits in one
+// window is returned as a single chunk; blank text yields none.
+func Split(text string, opts Options) []Chunk {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	if opts.OverlapTokens >= opts.MaxTokens {
+		opts.OverlapTokens = opts.MaxTokens / 4
+	}
+	if EstimateTokens(text) <= opts.MaxTokens {
+		return []Chunk{{Start: 0, End: len(text), Text: text}}
+	}
+
+	var units []span
+	for _, b := range blocks(text) {
+		units = append(units, fit(text, b, opts.MaxTokens)...)
+	}
+
+	var chunks []Chunk
+	for i := 0; i < len(units); {
+		j := i + 1
+		for j < len(units) && EstimateTokens(text[units[i].start:units[j].end]) <= opts.MaxTokens {
+			j++
+		}
+		start, end := units[i].start, units[j-1].end
+		chunks = append(chunks, Chunk{Index: len(chunks), Start: start, End: end, Text: text[start:end]})
+		if j == len(units) {
+			break
+		}
+
+		// Back up over trailing units that fit in the overlap budget.
+		k := j
+		for k-1 > i && EstimateTokens(text[units[k-1].start:end]) <= opts.OverlapTokens {
+			k--
+		}
+		i = k
+	}
+	return chunks
+}
+
+// span is a byte range of the text being split.
+type span struct{ start, end int }
+
+// blocks returns the paragraphs of text: runs of lines separated by blank
+// lines, where a fenced code block counts as one paragraph regardless of
+// blank lines inside it.
+func blocks(text string) []span {
+	var out []span
+	cur := span{start: -1}
+	inFence := false
+	for _, l := range lines(text) {
+		line := strings.TrimSpace(text[l.start:l.end])
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inFence = !inFence
+		}
+		if line == "" && !inFence {
+			if cur.start >= 0 {
+				out = append(out, cur)
+				cur.start = -1
+			}
+			continue
+		}
+		if cur.start < 0 {
+			cur.start = l.start
+		}
+		cur.end = l.end
+	}
+	if cur.start >= 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// lines returns the byte range of each line, excluding its newline.
+func lines(text string) []span {
+	var out []span
+	start := 0
+	for start <= len(text) {
+		i := strings.IndexByte(text[start:], '\n')
+		if i < 0 {
+			out = append(out, span{start, len(text)})
+			break
+		}
+		out = append(out, span{start, start + i})
+		start += i + 1
+	}
+	return out
+}
+
+// fit breaks b into pieces of at most maxTokens: whole if it fits, else by
+// line, and lines that are still too long at a word boundary.
+func fit(text string, b span, maxTokens int) []span {
+	if EstimateTokens(text[b.start:b.end]) <= maxTokens {
+		return []span{b}
+	}
+	var out []span
+	for _, l := range lines(text[b.start:b.end]) {
+		l = span{b.start + l.start, b.start + l.end}
+		if l.start == l.end {
+			continue
+		}
+		for EstimateTokens(text[l.start:l.end]) > maxTokens {
+			cut := cutPoint(text[l.start:l.end], maxTokens*charsPerToken)
+			out = append(out, span{l.start, l.start + cut})
+			l.start += cut
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// cutPoint returns a byte offset in s after at most maxRunes runes,
+// preferring the last whitespace in the second half of that range.
+func cutPoint(s string, maxRunes int) int {
+	end, n := 0, 0
+	for i := range s {
+		if n == maxRunes {
+			end = i
+			break
+		}
+		n++
+	}
+	if end == 0 {
+		return len(s)
+	}
+	for i := end - 1; i > end/2; i-- {
+		if s[i] == ' ' || s[i] == '\t' {
+			return i + 1
+		}
+	}
+	return end
+}
@@ -0,0 +1,106 @@
+package chunk
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit_WhenTextFits_ShouldReturnOneChunk(t *testing.T) {
+	chunks := Split("short message", Options{MaxTokens: 100})
+	if len(chunks) != 1 || chunks[0].Start != 0 || chunks[0].End != 13 {
+		t.Errorf("expected one whole chunk, got %+v", chunks)
+	}
+}
+
+func TestSplit_WhenBlank_ShouldReturnNothing(t *testing.T) {
+	if chunks := Split(" \n\n ", Options{MaxTokens: 100}); chunks != nil {
+		t.Errorf("expected no chunks, got %+v", chunks)
+	}
+}
+
+func TestSplit_ShouldKeepOffsetsConsistentWithText(t *testing.T) {
+	text := strings.Repeat("alpha beta gamma delta.\n\n", 40)
+	chunks := Split(text, Options{MaxTokens: 30, OverlapTokens: 10})
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if c.Index != i || text[c.Start:c.End] != c.Text {
+			t.Errorf("chunk %d: offsets do not match text: %+v", i, c)
+		}
+		if EstimateTokens(c.Text) > 30 {
+			t.Errorf("chunk %d exceeds budget: %d tokens", i, EstimateTokens(c.Text))
+		}
+	}
+	if chunks[0].Start != 0 || chunks[len(chunks)-1].End != len(text)-2 {
+		t.Errorf("expected chunks to cover the text, got %d..%d", chunks[0].Start, chunks[len(chunks)-1].End)
+	}
+}
+
+func TestSplit_ShouldOverlapConsecutiveChunks(t *testing.T) {
+	text := strings.Repeat("one short paragraph\n\n", 30)
+	chunks := Split(text, Options{MaxTokens: 30, OverlapTokens: 10})
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].Start >= chunks[i-1].End {
+			t.Errorf("chunk %d starts at %d, after previous end %d", i, chunks[i].Start, chunks[i-1].End)
+		}
+	}
+}
+
+func TestSplit_ShouldCutBetweenParagraphs(t *testing.T) {
+	para := strings.Repeat("word ", 10) // ~17 tokens
+	text := para + "\n\n" + para + "\n\n" + para
+	chunks := Split(text, Options{MaxTokens: 40})
+	for _, c := range chunks {
+		if !strings.HasPrefix(c.Text, "word") || !strings.HasSuffix(c.Text, "word ") {
+			t.Errorf("expected chunk to align to paragraphs, got %q", c.Text)
+		}
+	}
+}
+
+func TestSplit_ShouldNotCutInsideSmallCodeFence(t *testing.T) {
+	fence := "```go\nfunc a() {}\n\nfunc b() {}\n```"
+	text := strings.Repeat("intro text here. ", 8) + "\n\n" + fence + "\n\n" + strings.Repeat("outro text. ", 8)
+	chunks := Split(text, Options{MaxTokens: 50})
+	found := false
+	for _, c := range chunks {
+		if strings.Contains(c.Text, fence) {
+			found = true
+		}
+		if strings.Count(c.Text, "```")%2 != 0 {
+			t.Errorf("chunk splits a code fence: %q", c.Text)
+		}
+	}
+	if !found {
+		t.Errorf("expected the fence to stay whole, got %+v", chunks)
+	}
+}
+
+func TestSplit_WhenSingleLineIsHuge_ShouldHardSplitWithinBudget(t *testing.T) {
+	text := strings.Repeat("x", 1000)
+	chunks := Split(text, Options{MaxTokens: 50})
+	if len(chunks) < 7 {
+		t.Fatalf("expected the line to be split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if EstimateTokens(c.Text) > 50 {
+			t.Errorf("chunk exceeds budget: %d tokens", EstimateTokens(c.Text))
+		}
+	}
+}
+
+func TestSplit_WhenMultibyte_ShouldNotCutRunes(t *testing.T) {
+	text := strings.Repeat("héllo wörld ", 100)
+	for _, c := range Split(text, Options{MaxTokens: 20}) {
+		if !utf8.ValidString(c.Text) {
+			t.Errorf("chunk cuts a rune: %q", c.Text)
+		}
+	}
+}
+
+func TestEstimateTokens_ShouldCountRunesNotBytes(t *testing.T) {
+	if got := EstimateTokens("ééé"); got != 1 {
+		t.Errorf("expected 1 token, got %d", got)
+	}
+}
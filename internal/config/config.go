@@ -4,19 +4,66 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// DefaultAutoEmbedCap limits a background embedding job when
+// CLOG_AUTO_EMBED turns jobs on without giving a duration.
+const DefaultAutoEmbedCap = 2 * time.Minute
+
+// DefaultEmbedCacheMB is the embedding cache's size limit when
+// CLOG_EMBED_CACHE_MB is unset.
+const DefaultEmbedCacheMB = 512
+
 // Config holds base paths used by the logger.
 type Config struct {
 	LogBase string
+	// Backend selects the storage engine: "duckdb" (default) or "sqlite".
+	Backend string
+	// AutoEmbed, when positive, starts a background embedding job after
+	// each harvest and caps how long it may run.
+	AutoEmbed time.Duration
+	// EmbedCacheMB limits the embedding cache shared by all projects;
+	// 0 turns the cache off.
+	EmbedCacheMB int
 }
 
-// Default returns a Config rooted at ~/.claude/logs.
+// Default returns a Config rooted at ~/.claude/logs, with the backend
+// taken from CLOG_BACKEND, background embedding from CLOG_AUTO_EMBED and
+// the embedding cache limit from CLOG_EMBED_CACHE_MB.
 func Default() Config {
 	return Config{
-		LogBase: filepath.Join(os.Getenv("HOME"), ".claude", "logs"),
+		LogBase:      filepath.Join(os.Getenv("HOME"), ".claude", "logs"),
+		Backend:      os.Getenv("CLOG_BACKEND"),
+		AutoEmbed:    parseAutoEmbed(os.Getenv("CLOG_AUTO_EMBED")),
+		EmbedCacheMB: parseCacheMB(os.Getenv("CLOG_EMBED_CACHE_MB")),
+	}
+}
+
+// parseCacheMB reads CLOG_EMBED_CACHE_MB, falling back to
+// DefaultEmbedCacheMB when it is unset or not a number.
+func parseCacheMB(v string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return DefaultEmbedCacheMB
+	}
+	return max(n, 0)
+}
+
+// parseAutoEmbed reads CLOG_AUTO_EMBED: off when empty, "0", "false" or
+// "off", a time cap when a duration such as "5m", and on with
+// DefaultAutoEmbedCap otherwise.
+func parseAutoEmbed(v string) time.Duration {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "0", "false", "off", "no":
+		return 0
 	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return max(d, 0)
+	}
+	return DefaultAutoEmbedCap
 }
 
 // ProjectSlug converts a working directory into a safe directory name.
@@ -30,7 +77,29 @@ func (c Config) LogDir(cwd string) string {
 	return filepath.Join(c.LogBase, c.ProjectSlug(cwd))
 }
 
-// DBPath returns the DuckDB database file path for a project.
+// DBPath returns the database file path for a project. Each backend uses
+// its own file so switching backends never opens a foreign format.
 func (c Config) DBPath(cwd string) string {
-	return filepath.Join(c.LogDir(cwd), "events.duckdb")
+	name := "events.duckdb"
+	if c.Backend == "sqlite" {
+		name = "events.sqlite"
+	}
+	return filepath.Join(c.LogDir(cwd), name)
+}
+
+// EmbedLockPath returns the lock file held by a project's background
+// embedding job.
+func (c Config) EmbedLockPath(cwd string) string {
+	return filepath.Join(c.LogDir(cwd), "embed.lock")
+}
+
+// EmbedLogPath returns the file a project's background embedding job
+// writes its output to.
+func (c Config) EmbedLogPath(cwd string) string {
+	return filepath.Join(c.LogDir(cwd), "embed.log")
+}
+
+// EmbedCachePath returns the embedding cache shared by every project.
+func (c Config) EmbedCachePath() string {
+	return filepath.Join(c.LogBase, "embedding-cache.sqlite")
 }
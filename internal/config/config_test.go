@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // --- ProjectSlug ---
@@ -107,3 +108,56 @@ func TestDefault_ShouldReturnLogBaseUnderHome(t *testing.T) {
 		t.Errorf("expected LogBase to end with .claude/logs, got %q", c.LogBase)
 	}
 }
+
+func TestDBPath_WhenBackendIsSQLite_ShouldUseSQLiteFile(t *testing.T) {
+	c := Config{LogBase: "/tmp/logs", Backend: "sqlite"}
+	got := c.DBPath("/foo/bar")
+	expected := filepath.Join(c.LogDir("/foo/bar"), "events.sqlite")
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestDefault_ShouldReadBackendFromEnv(t *testing.T) {
+	t.Setenv("CLOG_BACKEND", "sqlite")
+	c := Default()
+	if c.Backend != "sqlite" {
+		t.Errorf("expected backend 'sqlite', got %q", c.Backend)
+	}
+}
+
+func TestDefault_ShouldReadAutoEmbedFromEnv(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      0,
+		"off":   0,
+		"0":     0,
+		"1":     DefaultAutoEmbedCap,
+		"true":  DefaultAutoEmbedCap,
+		"30s":   30 * time.Second,
+		"-1m":   0,
+		"bogus": DefaultAutoEmbedCap,
+	}
+	for v, want := range cases {
+		t.Setenv("CLOG_AUTO_EMBED", v)
+		if got := Default().AutoEmbed; got != want {
+			t.Errorf("CLOG_AUTO_EMBED=%q: expected %v, got %v", v, want, got)
+		}
+	}
+}
+
+func TestDefault_ShouldReadEmbedCacheLimitFromEnv(t *testing.T) {
+	cases := map[string]int{"": DefaultEmbedCacheMB, "64": 64, "0": 0, "-5": 0, "lots": DefaultEmbedCacheMB}
+	for v, want := range cases {
+		t.Setenv("CLOG_EMBED_CACHE_MB", v)
+		if got := Default().EmbedCacheMB; got != want {
+			t.Errorf("CLOG_EMBED_CACHE_MB=%q: expected %d, got %d", v, want, got)
+		}
+	}
+}
+
+func TestEmbedCachePath_ShouldLiveUnderLogBase(t *testing.T) {
+	c := Config{LogBase: "/tmp/logs"}
+	if got := c.EmbedCachePath(); got != filepath.Join("/tmp/logs", "embedding-cache.sqlite") {
+		t.Errorf("unexpected cache path %q", got)
+	}
+}
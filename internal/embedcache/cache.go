@@ -0,0 +1,330 @@
+// Package embedcache stores the embeddings of texts already seen, shared
+// by every project, so messages repeated across sessions ("continue",
+// boilerplate prompts) are sent to the provider once.
+//
+// Entries are keyed by model and a hash of the text with its whitespace
+// collapsed, and live in a SQLite file. When the file grows past its size
+// limit the least recently used entries are dropped.
+package embedcache
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"clog/internal/embedding"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS entries (
+    key        TEXT PRIMARY KEY,
+    model      TEXT NOT NULL,
+    vector     BLOB NOT NULL,
+    last_used  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_entries_last_used ON entries(last_used);
+
+CREATE TABLE IF NOT EXISTS counters (
+    name   TEXT PRIMARY KEY,
+    value  INTEGER NOT NULL
+);
+`
+
+// Cache is an open embedding cache.
+type Cache struct {
+	db       *sql.DB
+	maxBytes int64
+	// hits and misses count lookups since Open.
+	hits, misses int
+}
+
+// Stats describes the cache's use.
+type Stats struct {
+	// Hits and Misses count texts looked up since the cache was opened.
+	Hits, Misses int
+	// TotalHits and TotalMisses count every lookup ever made.
+	TotalHits, TotalMisses int64
+	Entries                int64
+	Bytes                  int64
+}
+
+// HitRate returns the fraction of lookups since Open that were hits.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// TotalHitRate returns the fraction of all lookups that were hits.
+func (s Stats) TotalHitRate() float64 {
+	if s.TotalHits+s.TotalMisses == 0 {
+		return 0
+	}
+	return float64(s.TotalHits) / float64(s.TotalHits+s.TotalMisses)
+}
+
+// Open opens or creates the cache at path, holding at most maxBytes of
+// vectors once pruned.
+func Open(path string, maxBytes int64) (*Cache, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("open embedding cache %s: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init embedding cache: %w", err)
+	}
+	return &Cache{db: db, maxBytes: maxBytes}, nil
+}
+
+// Close records this run's counts, prunes the cache to its size limit
+// and closes it.
+func (c *Cache) Close() error {
+	err := c.flush()
+	if perr := c.Prune(); err == nil {
+		err = perr
+	}
+	if cerr := c.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Key returns the cache key of text embedded by model.
+func Key(model, text string) string {
+	h := sha256.Sum256([]byte(model + "\x00" + normalize(text)))
+	return hex.EncodeToString(h[:])
+}
+
+// normalize collapses runs of whitespace, so texts differing only in
+// spacing share an entry.
+func normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Get returns the cached vector for each text, or nil where there is none.
+func (c *Cache) Get(model string, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	if len(texts) == 0 {
+		return out, nil
+	}
+	index := make(map[string][]int, len(texts))
+	args := make([]interface{}, 0, len(texts))
+	for i, t := range texts {
+		k := Key(model, t)
+		if _, ok := index[k]; !ok {
+			args = append(args, k)
+		}
+		index[k] = append(index[k], i)
+	}
+
+	rows, err := c.db.Query(`SELECT key, vector FROM entries WHERE key IN (?`+
+		strings.Repeat(", ?", len(args)-1)+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read embedding cache: %w", err)
+	}
+	defer rows.Close()
+	var found []interface{}
+	for rows.Next() {
+		var key string
+		var blob []byte
+		if err := rows.Scan(&key, &blob); err != nil {
+			return nil, fmt.Errorf("read embedding cache: %w", err)
+		}
+		v := decode(blob)
+		for _, i := range index[key] {
+			out[i] = v
+		}
+		found = append(found, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read embedding cache: %w", err)
+	}
+
+	for _, v := range out {
+		if v != nil {
+			c.hits++
+		} else {
+			c.misses++
+		}
+	}
+	if len(found) > 0 {
+		// Recency is only used to choose what to prune, so a failure
+		// here is not worth failing the lookup for.
+		c.db.Exec(`UPDATE entries SET last_used = ? WHERE key IN (?`+
+			strings.Repeat(", ?", len(found)-1)+`)`, append([]interface{}{time.Now().Unix()}, found...)...)
+	}
+	return out, nil
+}
+
+// Put stores the vector of each text.
+func (c *Cache) Put(model string, texts []string, vecs [][]float32) error {
+	if len(texts) != len(vecs) {
+		return fmt.Errorf("put %d vectors for %d texts", len(vecs), len(texts))
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("write embedding cache: %w", err)
+	}
+	defer tx.Rollback()
+	now := time.Now().Unix()
+	for i, t := range texts {
+		if _, err := tx.Exec(`
+			INSERT INTO entries (key, model, vector, last_used) VALUES (?, ?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET vector = excluded.vector, last_used = excluded.last_used
+		`, Key(model, t), model, encode(vecs[i]), now); err != nil {
+			return fmt.Errorf("write embedding cache: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("write embedding cache: %w", err)
+	}
+	return nil
+}
+
+// Prune drops the least recently used entries until the vectors fit in
+// the size limit. A limit of 0 or less keeps everything.
+func (c *Cache) Prune() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+	_, err := c.db.Exec(`
+		DELETE FROM entries WHERE key IN (
+			SELECT key FROM (
+				SELECT key, SUM(length(vector)) OVER (ORDER BY last_used DESC, key) AS total
+				FROM entries
+			) WHERE total > ?
+		)
+	`, c.maxBytes)
+	if err != nil {
+		return fmt.Errorf("prune embedding cache: %w", err)
+	}
+	return nil
+}
+
+// Stats returns hit counts and the cache's size.
+func (c *Cache) Stats() (Stats, error) {
+	s := Stats{Hits: c.hits, Misses: c.misses}
+	err := c.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(length(vector)), 0),
+		       COALESCE((SELECT value FROM counters WHERE name = 'hits'), 0),
+		       COALESCE((SELECT value FROM counters WHERE name = 'misses'), 0)
+		FROM entries
+	`).Scan(&s.Entries, &s.Bytes, &s.TotalHits, &s.TotalMisses)
+	if err != nil {
+		return Stats{}, fmt.Errorf("embedding cache stats: %w", err)
+	}
+	s.TotalHits += int64(c.hits)
+	s.TotalMisses += int64(c.misses)
+	return s, nil
+}
+
+// flush adds this run's counts to the lifetime counters.
+func (c *Cache) flush() error {
+	for name, n := range map[string]int{"hits": c.hits, "misses": c.misses} {
+		if n == 0 {
+			continue
+		}
+		if _, err := c.db.Exec(`
+			INSERT INTO counters (name, value) VALUES (?, ?)
+			ON CONFLICT (name) DO UPDATE SET value = value + excluded.value
+		`, name, n); err != nil {
+			return fmt.Errorf("record embedding cache stats: %w", err)
+		}
+	}
+	c.hits, c.misses = 0, 0
+	return nil
+}
+
+// Wrap returns an Embedder that answers from the cache where it can and
+// sends only the remaining texts to e. If e doesn't name its model there
+// is no safe key, and e is returned as is. So is the offline embedder,
+// which is cheaper to rerun than to look up.
+func (c *Cache) Wrap(e embedding.Embedder) embedding.Embedder {
+	if _, ok := e.(*embedding.HashEmbedder); ok {
+		return e
+	}
+	model := embedding.ModelID(e)
+	if model == "" {
+		return e
+	}
+	return &cachedEmbedder{cache: c, inner: e, model: model}
+}
+
+type cachedEmbedder struct {
+	cache *Cache
+	inner embedding.Embedder
+	model string
+}
+
+func (e *cachedEmbedder) Dimension() int { return e.inner.Dimension() }
+
+func (e *cachedEmbedder) MaxTokens() int { return embedding.TokenLimit(e.inner) }
+
+func (e *cachedEmbedder) BatchLimits() (int, int) { return embedding.BatchLimits(e.inner) }
+
+func (e *cachedEmbedder) Model() string {
+	if m, ok := e.inner.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+	return ""
+}
+
+// Embed looks texts up in the cache and embeds the misses. A cache that
+// can't be read or written is bypassed rather than failing the embedding.
+func (e *cachedEmbedder) Embed(texts []string) ([][]float32, error) {
+	out, err := e.cache.Get(e.model, texts)
+	if err != nil {
+		return e.inner.Embed(texts)
+	}
+
+	var missing []int
+	var missTexts []string
+	for i, v := range out {
+		if v == nil {
+			missing = append(missing, i)
+			missTexts = append(missTexts, texts[i])
+		}
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	vecs, err := e.inner.Embed(missTexts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != len(missTexts) {
+		return nil, fmt.Errorf("provider returned %d embeddings for %d texts", len(vecs), len(missTexts))
+	}
+	for j, i := range missing {
+		out[i] = vecs[j]
+	}
+	e.cache.Put(e.model, missTexts, vecs)
+	return out, nil
+}
+
+// encode packs v as little-endian float32s, as the SQLite store does.
+func encode(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decode(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,141 @@
+package embedcache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"clog/internal/embedding"
+)
+
+// countingEmbedder returns a vector derived from each text's length and
+// records what it was asked to embed.
+type countingEmbedder struct {
+	model string
+	calls [][]string
+}
+
+func (e *countingEmbedder) Embed(texts []string) ([][]float32, error) {
+	e.calls = append(e.calls, texts)
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = []float32{float32(len(t)), 1}
+	}
+	return out, nil
+}
+
+func (e *countingEmbedder) Dimension() int { return 2 }
+func (e *countingEmbedder) Model() string  { return e.model }
+
+func openCache(t *testing.T, path string, maxBytes int64) *Cache {
+	t.Helper()
+	c, err := Open(path, maxBytes)
+	if err != nil {
+		t.Fatalf("open cache: %v", err)
+	}
+	return c
+}
+
+func TestWrap_WhenTextSeenBefore_ShouldNotEmbedItAgain(t *testing.T) {
+	c := openCache(t, filepath.Join(t.TempDir(), "cache.sqlite"), 0)
+	defer c.Close()
+	inner := &countingEmbedder{model: "m"}
+	emb := c.Wrap(inner)
+
+	if _, err := emb.Embed([]string{"continue", "fix the test"}); err != nil {
+		t.Fatal(err)
+	}
+	vecs, err := emb.Embed([]string{"  continue\n", "new text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.calls) != 2 || len(inner.calls[1]) != 1 || inner.calls[1][0] != "new text" {
+		t.Fatalf("expected only the unseen text embedded, got %v", inner.calls)
+	}
+	if vecs[0][0] != float32(len("continue")) || vecs[1][0] != float32(len("new text")) {
+		t.Errorf("expected vectors in input order, got %v", vecs)
+	}
+
+	s, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Hits != 1 || s.Misses != 3 || s.Entries != 3 {
+		t.Errorf("expected 1 hit, 3 misses and 3 entries, got %+v", s)
+	}
+}
+
+func TestWrap_WhenModelDiffers_ShouldMiss(t *testing.T) {
+	c := openCache(t, filepath.Join(t.TempDir(), "cache.sqlite"), 0)
+	defer c.Close()
+	a, b := &countingEmbedder{model: "a"}, &countingEmbedder{model: "b"}
+	c.Wrap(a).Embed([]string{"hello"})
+	c.Wrap(b).Embed([]string{"hello"})
+	if len(b.calls) != 1 {
+		t.Errorf("expected another model's vector not reused, got %v", b.calls)
+	}
+}
+
+func TestWrap_WhenModelUnknown_ShouldReturnEmbedderUnchanged(t *testing.T) {
+	c := openCache(t, filepath.Join(t.TempDir(), "cache.sqlite"), 0)
+	defer c.Close()
+	inner := &countingEmbedder{}
+	if c.Wrap(inner) != inner {
+		t.Error("expected an embedder without a model id left uncached")
+	}
+}
+
+func TestClose_ShouldKeepLifetimeCounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.sqlite")
+	c := openCache(t, path, 0)
+	emb := c.Wrap(&countingEmbedder{model: "m"})
+	emb.Embed([]string{"a", "a"})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = openCache(t, path, 0)
+	defer c.Close()
+	c.Wrap(&countingEmbedder{model: "m"}).Embed([]string{"a"})
+	s, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Hits != 1 || s.Misses != 0 || s.TotalHits != 1 || s.TotalMisses != 2 {
+		t.Errorf("expected 1/0 this run and 1/2 overall, got %+v", s)
+	}
+	if s.HitRate() != 1 || s.TotalHitRate() != 1.0/3 {
+		t.Errorf("unexpected hit rates %v and %v", s.HitRate(), s.TotalHitRate())
+	}
+}
+
+func TestPrune_WhenOverLimit_ShouldDropLeastRecentlyUsed(t *testing.T) {
+	// Each 2-float vector is 8 bytes; the limit holds two.
+	c := openCache(t, filepath.Join(t.TempDir(), "cache.sqlite"), 16)
+	defer c.Close()
+	inner := &countingEmbedder{model: "m"}
+	emb := c.Wrap(inner)
+	emb.Embed([]string{"old"})
+	c.db.Exec(`UPDATE entries SET last_used = last_used - 100`)
+	emb.Embed([]string{"newer", "newest"})
+
+	if err := c.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := c.Stats()
+	if s.Entries != 2 || s.Bytes != 16 {
+		t.Fatalf("expected 2 entries in 16 bytes, got %+v", s)
+	}
+	emb.Embed([]string{"old"})
+	if len(inner.calls) != 3 {
+		t.Errorf("expected the pruned entry embedded again, got %v", inner.calls)
+	}
+}
+
+func TestWrap_WhenEmbedderIsOffline_ShouldReturnEmbedderUnchanged(t *testing.T) {
+	c := openCache(t, filepath.Join(t.TempDir(), "cache.sqlite"), 0)
+	defer c.Close()
+	inner := embedding.NewHashing()
+	if c.Wrap(inner) != embedding.Embedder(inner) {
+		t.Error("expected the offline embedder left uncached")
+	}
+}
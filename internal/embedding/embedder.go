@@ -3,7 +3,9 @@ package embedding
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -23,6 +25,57 @@ type Provider struct {
 	Model     string
 	Dimension int
 	EnvKey    string
+	// MaxTokens is the longest input the model accepts per text.
+	MaxTokens int
+	// MaxBatchTexts and MaxBatchTokens bound one request, the latter in
+	// estimated tokens summed over its texts.
+	MaxBatchTexts  int
+	MaxBatchTokens int
+	// AuthHeader names the header that carries the API key, sent as is.
+	// When empty the key is sent as a bearer token in Authorization.
+	AuthHeader string
+	// Headers are added to every request.
+	Headers map[string]string
+}
+
+// defaultMaxTokens is assumed when a provider's input limit is unknown.
+// It matches Ollama's default context window.
+const defaultMaxTokens = 2048
+
+// TokenLimit returns the longest input, in tokens, that e accepts per text.
+func TokenLimit(e Embedder) int {
+	if l, ok := e.(interface{ MaxTokens() int }); ok && l.MaxTokens() > 0 {
+		return l.MaxTokens()
+	}
+	return defaultMaxTokens
+}
+
+// Per-request limits assumed when a provider's are unknown. They suit a
+// local Ollama, which embeds a batch sequentially.
+const (
+	defaultBatchTexts  = 64
+	defaultBatchTokens = 16000
+)
+
+// BatchLimits returns the most texts, and estimated tokens over all of
+// them, that e accepts in one request.
+func BatchLimits(e Embedder) (texts, tokens int) {
+	texts, tokens = defaultBatchTexts, defaultBatchTokens
+	if l, ok := e.(interface{ BatchLimits() (int, int) }); ok {
+		if n, t := l.BatchLimits(); n > 0 && t > 0 {
+			texts, tokens = n, t
+		}
+	}
+	return texts, tokens
+}
+
+// ModelID identifies the model behind e, including its dimension, for
+// keying stored vectors. It is "" when e doesn't say.
+func ModelID(e Embedder) string {
+	if m, ok := e.(interface{ Model() string }); ok && m.Model() != "" {
+		return fmt.Sprintf("%s@%d", m.Model(), e.Dimension())
+	}
+	return ""
 }
 
 var (
@@ -32,6 +85,10 @@ var (
 		Model:     "voyage-3-lite",
 		Dimension: 1024,
 		EnvKey:    "VOYAGE_API_KEY",
+		MaxTokens: 32000,
+
+		MaxBatchTexts:  1000,
+		MaxBatchTokens: 120000,
 	}
 
 	OpenAI = Provider{
@@ -40,48 +97,156 @@ var (
 		Model:     "text-embedding-3-small",
 		Dimension: 1536,
 		EnvKey:    "OPENAI_API_KEY",
+		MaxTokens: 8191,
+
+		MaxBatchTexts:  2048,
+		MaxBatchTokens: 300000,
 	}
 )
 
+// Configured reports whether the environment names a provider, rather
+// than leaving NewFromEnv to fall back to the offline HashEmbedder.
+func Configured() bool {
+	for _, v := range []string{"CLOG_EMBED_ENDPOINT", "OLLAMA_EMBED_MODEL", Voyage.EnvKey, OpenAI.EnvKey} {
+		if os.Getenv(v) != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // NewFromEnv detects an embedding provider from the environment.
-// It checks OLLAMA_EMBED_MODEL first, then VOYAGE_API_KEY, then OPENAI_API_KEY.
+// It checks CLOG_EMBED_ENDPOINT first, then OLLAMA_EMBED_MODEL, then
+// VOYAGE_API_KEY, then OPENAI_API_KEY, and falls back to the offline
+// HashEmbedder when none is set.
+// CLOG_EMBED_RPM caps the provider's requests per minute.
 func NewFromEnv() (Embedder, error) {
+	rpm := 0
+	if v := os.Getenv("CLOG_EMBED_RPM"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("CLOG_EMBED_RPM must be a number of requests per minute, got %q", v)
+		}
+		rpm = n
+	}
+
+	if endpoint := os.Getenv("CLOG_EMBED_ENDPOINT"); endpoint != "" {
+		return newCustom(endpoint, rpm)
+	}
 	if model := os.Getenv("OLLAMA_EMBED_MODEL"); model != "" {
-		return newOllama(model)
+		return newOllama(model, rpm)
 	}
 	for _, p := range []Provider{Voyage, OpenAI} {
 		if key := os.Getenv(p.EnvKey); key != "" {
-			return NewHTTP(p, key), nil
+			emb := NewHTTP(p, key)
+			emb.SetRateLimit(rpm)
+			return emb, nil
 		}
 	}
-	return nil, fmt.Errorf("no embedding provider found; set OLLAMA_EMBED_MODEL, VOYAGE_API_KEY, or OPENAI_API_KEY")
+	return NewHashing(), nil
+}
+
+// newCustom creates an Embedder for any OpenAI-compatible endpoint, such
+// as LiteLLM, vLLM or Azure OpenAI, configured by:
+//
+//	CLOG_EMBED_ENDPOINT     URL of the embeddings API; "/embeddings" is
+//	                        appended unless the path already ends in it
+//	CLOG_EMBED_MODEL        model name sent with each request; Azure takes
+//	                        the deployment from the URL instead
+//	CLOG_EMBED_API_KEY      API key, if the endpoint needs one
+//	CLOG_EMBED_API_HEADER   header for the key instead of a bearer token
+//	                        (e.g. "api-key" for Azure)
+//	CLOG_EMBED_API_VERSION  api-version query parameter (Azure)
+//	CLOG_EMBED_HEADERS      extra headers as "Name: value", separated by
+//	                        semicolons or newlines
+//
+// Like newOllama, it probes the endpoint to discover the dimension.
+func newCustom(endpoint string, rpm int) (Embedder, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("CLOG_EMBED_ENDPOINT must be an http(s) URL, got %q", endpoint)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	if !strings.HasSuffix(u.Path, "/embeddings") {
+		u.Path += "/embeddings"
+	}
+	if v := os.Getenv("CLOG_EMBED_API_VERSION"); v != "" {
+		q := u.Query()
+		q.Set("api-version", v)
+		u.RawQuery = q.Encode()
+	}
+	headers, err := parseHeaders(os.Getenv("CLOG_EMBED_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := Provider{
+		Name:       u.Host,
+		Endpoint:   u.String(),
+		Model:      os.Getenv("CLOG_EMBED_MODEL"),
+		MaxTokens:  defaultMaxTokens,
+		AuthHeader: os.Getenv("CLOG_EMBED_API_HEADER"),
+		Headers:    headers,
+	}
+	emb := NewHTTP(p, os.Getenv("CLOG_EMBED_API_KEY"))
+	emb.SetRateLimit(rpm)
+
+	if err := probe(emb); err != nil {
+		return nil, fmt.Errorf("embedding endpoint probe (%s): %w", p.Endpoint, err)
+	}
+	return emb, nil
+}
+
+// parseHeaders reads "Name: value" pairs separated by semicolons or
+// newlines.
+func parseHeaders(v string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, field := range strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == '\n' }) {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("CLOG_EMBED_HEADERS: expected \"Name: value\", got %q", strings.TrimSpace(field))
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
 }
 
 // newOllama creates an Embedder backed by a local Ollama instance.
 // It probes the model with a short string to discover the embedding dimension.
-func newOllama(model string) (Embedder, error) {
+func newOllama(model string, rpm int) (Embedder, error) {
 	host := os.Getenv("OLLAMA_HOST")
 	if host == "" {
 		return nil, fmt.Errorf("OLLAMA_HOST is not set")
 	}
 
 	p := Provider{
-		Name:     "Ollama",
-		Endpoint: strings.TrimRight(host, "/") + "/v1/embeddings",
-		Model:    model,
+		Name:      "Ollama",
+		Endpoint:  strings.TrimRight(host, "/") + "/v1/embeddings",
+		Model:     model,
+		MaxTokens: defaultMaxTokens,
 	}
 
 	emb := NewHTTP(p, "ollama") // Ollama ignores the auth header
+	emb.SetRateLimit(rpm)
+
+	if err := probe(emb); err != nil {
+		return nil, fmt.Errorf("ollama probe (%s): %w", model, err)
+	}
+	return emb, nil
+}
 
-	// Probe to discover the embedding dimension.
+// probe embeds a short string to discover the embedding dimension.
+func probe(emb *HTTPEmbedder) error {
 	vecs, err := emb.Embed([]string{"hello"})
 	if err != nil {
-		return nil, fmt.Errorf("ollama probe (%s): %w", model, err)
+		return err
 	}
 	if len(vecs) == 0 || len(vecs[0]) == 0 {
-		return nil, fmt.Errorf("ollama probe returned empty embedding")
+		return fmt.Errorf("empty embedding returned")
 	}
-
 	emb.provider.Dimension = len(vecs[0])
-	return emb, nil
+	return nil
 }
@@ -2,10 +2,14 @@ package embedding
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // --- truncate ---
@@ -38,6 +42,13 @@ func TestTruncate_WhenEmptyString_ShouldReturnEmpty(t *testing.T) {
 	}
 }
 
+func TestTruncate_WhenCutFallsInsideRune_ShouldBackOffToRuneStart(t *testing.T) {
+	got := truncate("héllo", 2)
+	if got != "h..." {
+		t.Errorf("expected 'h...', got %q", got)
+	}
+}
+
 // --- NewHTTP ---
 
 func TestNewHTTP_ShouldReturnEmbedderWithCorrectDimension(t *testing.T) {
@@ -124,6 +135,7 @@ func TestEmbed_WhenServerReturnsError_ShouldReturnError(t *testing.T) {
 
 	p := Provider{Name: "Test", Endpoint: srv.URL, Model: "m", Dimension: 3}
 	emb := NewHTTP(p, "key")
+	emb.sleep = func(time.Duration) {}
 
 	_, err := emb.Embed([]string{"hello"})
 	if err == nil {
@@ -181,16 +193,19 @@ func TestEmbed_WhenGivenSingleText_ShouldReturnSingleVector(t *testing.T) {
 
 // --- NewFromEnv ---
 
-func TestNewFromEnv_WhenNoProviderConfigured_ShouldReturnError(t *testing.T) {
+func TestNewFromEnv_WhenNoProviderConfigured_ShouldUseOfflineEmbedder(t *testing.T) {
 	// Clear all provider env vars
 	os.Unsetenv("OLLAMA_EMBED_MODEL")
 	os.Unsetenv("OLLAMA_HOST")
 	os.Unsetenv("VOYAGE_API_KEY")
 	os.Unsetenv("OPENAI_API_KEY")
 
-	_, err := NewFromEnv()
-	if err == nil {
-		t.Fatal("expected error when no provider is configured")
+	emb, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := emb.(*HashEmbedder); !ok {
+		t.Fatalf("expected the offline embedder, got %T", emb)
 	}
 }
 
@@ -294,3 +309,281 @@ func TestOpenAIProvider_ShouldHaveCorrectDefaults(t *testing.T) {
 		t.Errorf("expected env key 'OPENAI_API_KEY', got %q", OpenAI.EnvKey)
 	}
 }
+
+// --- TokenLimit ---
+
+func TestTokenLimit_WhenProviderDeclaresLimit_ShouldReturnIt(t *testing.T) {
+	if got := TokenLimit(NewHTTP(OpenAI, "k")); got != 8191 {
+		t.Errorf("expected 8191, got %d", got)
+	}
+}
+
+func TestTokenLimit_WhenLimitUnknown_ShouldReturnDefault(t *testing.T) {
+	if got := TokenLimit(NewHTTP(Provider{Name: "custom"}, "k")); got != defaultMaxTokens {
+		t.Errorf("expected %d, got %d", defaultMaxTokens, got)
+	}
+}
+
+// --- ModelID ---
+
+func TestModelID_ShouldNameProviderModelAndDimension(t *testing.T) {
+	if got := ModelID(NewHTTP(OpenAI, "k")); got != "OpenAI/text-embedding-3-small@1536" {
+		t.Errorf("expected OpenAI/text-embedding-3-small@1536, got %q", got)
+	}
+}
+
+// --- Retries and rate limiting ---
+
+// flakyServer answers with each status in turn, then 200s. headers, if
+// set, are added to the non-200 responses.
+func flakyServer(t *testing.T, headers map[string]string, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if int(n) <= len(statuses) {
+			for k, v := range headers {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(statuses[n-1])
+			w.Write([]byte(`{"error":"nope"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(embeddingResponse{Data: []embeddingData{{Embedding: []float32{1, 2}}}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func testEmbedder(url string) (*HTTPEmbedder, *[]time.Duration) {
+	emb := NewHTTP(Provider{Name: "Test", Endpoint: url, Model: "m", Dimension: 2}, "key")
+	var slept []time.Duration
+	emb.sleep = func(d time.Duration) { slept = append(slept, d) }
+	return emb, &slept
+}
+
+func TestEmbed_WhenServerFailsTransiently_ShouldRetryWithBackoff(t *testing.T) {
+	srv, calls := flakyServer(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway)
+	emb, slept := testEmbedder(srv.URL)
+
+	vecs, err := emb.Embed([]string{"hello"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vecs) != 1 || *calls != 3 {
+		t.Fatalf("expected success on the third attempt, got %d calls", *calls)
+	}
+	if len(*slept) != 2 {
+		t.Fatalf("expected 2 waits, got %v", *slept)
+	}
+	for i, d := range *slept {
+		lo, hi := baseDelay<<i/2, baseDelay<<i
+		if d < lo || d > hi {
+			t.Errorf("wait %d: expected between %v and %v, got %v", i, lo, hi, d)
+		}
+	}
+}
+
+func TestEmbed_WhenRateLimitedWithRetryAfter_ShouldWaitThatLong(t *testing.T) {
+	srv, _ := flakyServer(t, map[string]string{"Retry-After": "7"}, http.StatusTooManyRequests)
+	emb, slept := testEmbedder(srv.URL)
+
+	if _, err := emb.Embed([]string{"hello"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 7*time.Second {
+		t.Errorf("expected one 7s wait, got %v", *slept)
+	}
+}
+
+func TestEmbed_WhenRetryAfterTooLong_ShouldFail(t *testing.T) {
+	srv, calls := flakyServer(t, map[string]string{"Retry-After": "3600"}, http.StatusTooManyRequests)
+	emb, _ := testEmbedder(srv.URL)
+
+	if _, err := emb.Embed([]string{"hello"}); err == nil {
+		t.Fatal("expected an error when asked to wait an hour")
+	}
+	if *calls != 1 {
+		t.Errorf("expected no retry, got %d calls", *calls)
+	}
+}
+
+func TestEmbed_WhenInputRejected_ShouldNotRetry(t *testing.T) {
+	srv, calls := flakyServer(t, nil, http.StatusBadRequest)
+	emb, _ := testEmbedder(srv.URL)
+
+	_, err := emb.Embed([]string{"hello"})
+	var se *StatusError
+	if !errors.As(err, &se) || !se.Rejected() || se.Temporary() {
+		t.Fatalf("expected a rejected StatusError, got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("expected no retry, got %d calls", *calls)
+	}
+}
+
+func TestEmbed_WhenServerKeepsFailing_ShouldGiveUp(t *testing.T) {
+	statuses := make([]int, maxAttempts+1)
+	for i := range statuses {
+		statuses[i] = http.StatusInternalServerError
+	}
+	srv, calls := flakyServer(t, nil, statuses...)
+	emb, _ := testEmbedder(srv.URL)
+
+	if _, err := emb.Embed([]string{"hello"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if *calls != maxAttempts {
+		t.Errorf("expected %d attempts, got %d", maxAttempts, *calls)
+	}
+}
+
+func TestSetRateLimit_ShouldSpaceRequests(t *testing.T) {
+	srv, _ := flakyServer(t, nil)
+	emb, slept := testEmbedder(srv.URL)
+	emb.SetRateLimit(600) // one request per 100ms
+
+	for i := 0; i < 3; i++ {
+		if _, err := emb.Embed([]string{"hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var total time.Duration
+	for _, d := range *slept {
+		total += d
+	}
+	// The sleeps are faked, so the second and third requests owe 100ms
+	// and 200ms, less the time the requests took.
+	if len(*slept) != 2 || total < 250*time.Millisecond || total > 300*time.Millisecond {
+		t.Errorf("expected about 300ms of waits over 2 requests, got %v", *slept)
+	}
+}
+
+func TestRetryAfter_ShouldParseSecondsAndDates(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"5":                             5 * time.Second,
+		"soon":                          0,
+		"Sun, 18 Oct 2026 12:00:30 GMT": 30 * time.Second,
+		"Sun, 18 Oct 2026 11:00:00 GMT": 0,
+	}
+	for v, want := range cases {
+		if got := retryAfter(v, now); got != want {
+			t.Errorf("retryAfter(%q): expected %v, got %v", v, want, got)
+		}
+	}
+}
+
+func TestNewFromEnv_WhenRPMInvalid_ShouldReturnError(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "k")
+	t.Setenv("CLOG_EMBED_RPM", "fast")
+	if _, err := NewFromEnv(); err == nil {
+		t.Fatal("expected error for a non-numeric CLOG_EMBED_RPM")
+	}
+}
+
+// --- BatchLimits ---
+
+func TestBatchLimits_ShouldUseProviderLimitsOrDefaults(t *testing.T) {
+	if n, tok := BatchLimits(NewHTTP(OpenAI, "k")); n != 2048 || tok != 300000 {
+		t.Errorf("expected OpenAI's limits, got %d texts and %d tokens", n, tok)
+	}
+	if n, tok := BatchLimits(NewHTTP(Provider{Name: "custom"}, "k")); n != defaultBatchTexts || tok != defaultBatchTokens {
+		t.Errorf("expected defaults, got %d texts and %d tokens", n, tok)
+	}
+}
+
+func TestNewFromEnv_WhenEndpointSet_ShouldProbeItWithConfiguredHeaders(t *testing.T) {
+	var req *http.Request
+	var body embeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(embeddingResponse{Data: []embeddingData{{Embedding: []float32{0.1, 0.2, 0.3}}}})
+	}))
+	defer server.Close()
+	t.Setenv("CLOG_EMBED_ENDPOINT", server.URL+"/openai/deployments/small")
+	t.Setenv("CLOG_EMBED_MODEL", "")
+	t.Setenv("CLOG_EMBED_API_KEY", "secret")
+	t.Setenv("CLOG_EMBED_API_HEADER", "api-key")
+	t.Setenv("CLOG_EMBED_API_VERSION", "2024-02-01")
+	t.Setenv("CLOG_EMBED_HEADERS", "X-Team: search; X-Trace: on")
+	t.Setenv("OPENAI_API_KEY", "ignored")
+
+	emb, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if emb.Dimension() != 3 {
+		t.Errorf("expected the probed dimension 3, got %d", emb.Dimension())
+	}
+	if req.URL.Path != "/openai/deployments/small/embeddings" || req.URL.Query().Get("api-version") != "2024-02-01" {
+		t.Errorf("unexpected request URL %s", req.URL)
+	}
+	if req.Header.Get("api-key") != "secret" || req.Header.Get("Authorization") != "" {
+		t.Errorf("expected the key in api-key only, got headers %v", req.Header)
+	}
+	if req.Header.Get("X-Team") != "search" || req.Header.Get("X-Trace") != "on" {
+		t.Errorf("expected the extra headers sent, got %v", req.Header)
+	}
+	if body.Model != "" {
+		t.Errorf("expected no model sent, got %q", body.Model)
+	}
+}
+
+func TestNewFromEnv_WhenEndpointHasNoKey_ShouldSendNoAuthorization(t *testing.T) {
+	var auth, path string
+	var body embeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, path = r.Header.Get("Authorization"), r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(embeddingResponse{Data: []embeddingData{{Embedding: []float32{1, 0}}}})
+	}))
+	defer server.Close()
+	t.Setenv("CLOG_EMBED_ENDPOINT", server.URL+"/v1/embeddings/")
+	t.Setenv("CLOG_EMBED_MODEL", "bge-small")
+	t.Setenv("CLOG_EMBED_API_KEY", "")
+	t.Setenv("CLOG_EMBED_API_HEADER", "")
+	t.Setenv("CLOG_EMBED_API_VERSION", "")
+	t.Setenv("CLOG_EMBED_HEADERS", "")
+
+	emb, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "/v1/embeddings" || auth != "" || body.Model != "bge-small" {
+		t.Errorf("unexpected request: path %q, auth %q, model %q", path, auth, body.Model)
+	}
+	if id := ModelID(emb); !strings.HasSuffix(id, "/bge-small@2") {
+		t.Errorf("expected the model id to name the model, got %q", id)
+	}
+}
+
+func TestNewFromEnv_WhenEndpointInvalid_ShouldReturnError(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"CLOG_EMBED_ENDPOINT": "localhost:4000"},
+		{"CLOG_EMBED_ENDPOINT": "http://localhost:1", "CLOG_EMBED_HEADERS": "no-colon"},
+	} {
+		t.Setenv("CLOG_EMBED_HEADERS", "")
+		for k, v := range env {
+			t.Setenv(k, v)
+		}
+		if _, err := NewFromEnv(); err == nil {
+			t.Errorf("expected an error for %v", env)
+		}
+	}
+}
+
+func TestConfigured_ShouldReportWhetherAProviderIsNamed(t *testing.T) {
+	for _, v := range []string{"CLOG_EMBED_ENDPOINT", "OLLAMA_EMBED_MODEL", "VOYAGE_API_KEY", "OPENAI_API_KEY"} {
+		t.Setenv(v, "")
+	}
+	if Configured() {
+		t.Error("expected no provider configured")
+	}
+	t.Setenv("OPENAI_API_KEY", "key")
+	if !Configured() {
+		t.Error("expected OPENAI_API_KEY to configure a provider")
+	}
+}
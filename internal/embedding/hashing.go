@@ -0,0 +1,157 @@
+package embedding
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// HashDimension is the length of HashEmbedder's vectors.
+const HashDimension = 512
+
+// HashEmbedder embeds text offline by feature hashing. Each word, each pair
+// of adjacent words and each character trigram of a word is hashed into
+// one of HashDimension buckets, with a second bit of the hash choosing the
+// sign so that collisions tend to cancel out.
+//
+// Vectors are lexical: texts are similar when they share words or word
+// fragments ("auth" and "authentication"), not when they mean the same
+// thing in other words. Term frequency is damped logarithmically and
+// stopwords are dropped in place of IDF, since a vector must not depend on
+// corpus statistics that change as sessions are added.
+type HashEmbedder struct{}
+
+// NewHashing returns the offline embedder.
+func NewHashing() *HashEmbedder { return &HashEmbedder{} }
+
+func (e *HashEmbedder) Dimension() int { return HashDimension }
+
+// Model names the feature scheme; changing it must change the name.
+func (e *HashEmbedder) Model() string { return "clog/hash-v1" }
+
+// MaxTokens is generous: hashing cost is linear and there is no context
+// window, so this only bounds how much one vector has to summarise.
+func (e *HashEmbedder) MaxTokens() int { return 8192 }
+
+// BatchLimits imposes no practical limit.
+func (e *HashEmbedder) BatchLimits() (texts, tokens int) { return 1 << 20, 1 << 30 }
+
+// Feature weights. Word pairs capture phrases; a word's trigrams share one
+// weight so long words don't outweigh short ones.
+const (
+	wordWeight     = 1.0
+	pairWeight     = 0.5
+	fragmentWeight = 0.5
+)
+
+// Embed returns one unit-length vector per text.
+func (e *HashEmbedder) Embed(texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = hashVector(t)
+	}
+	return out, nil
+}
+
+func hashVector(text string) []float32 {
+	weights := map[string]float64{}
+	counts := map[string]int{}
+	add := func(feature string, w float64) {
+		weights[feature] += w
+		counts[feature]++
+	}
+
+	words := hashWords(text)
+	prev := ""
+	for _, w := range words {
+		add("w:"+w, wordWeight)
+		if prev != "" {
+			add("p:"+prev+" "+w, pairWeight)
+		}
+		prev = w
+		grams := trigrams(w)
+		for _, g := range grams {
+			add("t:"+g, fragmentWeight/float64(len(grams)))
+		}
+	}
+
+	v := make([]float64, HashDimension)
+	for f, w := range weights {
+		// Average weight per occurrence, damped by log frequency.
+		n := float64(counts[f])
+		x := w / n * (1 + math.Log(n))
+		h := fnv.New64a()
+		h.Write([]byte(f))
+		sum := h.Sum64()
+		if sum>>63 == 1 {
+			x = -x
+		}
+		v[sum%HashDimension] += x
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	out := make([]float32, HashDimension)
+	if norm == 0 {
+		// Nothing to hash; any fixed unit vector keeps cosine similarity
+		// defined.
+		out[0] = 1
+		return out
+	}
+	norm = math.Sqrt(norm)
+	for i, x := range v {
+		out[i] = float32(x / norm)
+	}
+	return out
+}
+
+// hashWords splits text into lower-case words, dropping stopwords and
+// single characters. If that leaves nothing, the stopwords are kept.
+func hashWords(text string) []string {
+	all := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	var words []string
+	for _, w := range all {
+		if len([]rune(w)) > 1 && !hashStopWords[w] {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return all
+	}
+	return words
+}
+
+// trigrams returns the character trigrams of w with its boundaries marked,
+// so "<au" and "th>" differ from the same letters mid-word.
+func trigrams(w string) []string {
+	r := []rune("<" + w + ">")
+	if len(r) < 3 {
+		return []string{string(r)}
+	}
+	out := make([]string, 0, len(r)-2)
+	for i := 0; i+3 <= len(r); i++ {
+		out = append(out, string(r[i:i+3]))
+	}
+	return out
+}
+
+// hashStopWords are too common to say what a text is about.
+var hashStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "but": true,
+	"not": true, "you": true, "with": true, "this": true, "that": true, "from": true,
+	"how": true, "did": true, "what": true, "why": true, "when": true, "where": true,
+	"have": true, "has": true, "had": true, "does": true, "into": true, "our": true,
+	"an": true, "as": true, "at": true, "be": true, "by": true, "do": true, "if": true,
+	"in": true, "is": true, "it": true, "me": true, "my": true, "of": true, "on": true,
+	"or": true, "so": true, "to": true, "up": true, "we": true, "can": true, "will": true,
+	"its": true, "then": true, "than": true, "there": true, "they": true, "them": true,
+	"these": true, "those": true, "would": true, "should": true, "could": true,
+	"just": true, "also": true, "some": true, "any": true, "all": true, "now": true,
+	"let": true, "lets": true, "here": true, "which": true, "who": true, "been": true,
+	"were": true, "your": true, "i": true, "a": true,
+}
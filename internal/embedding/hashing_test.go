@@ -0,0 +1,62 @@
+package embedding
+
+import (
+	"math"
+	"testing"
+)
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func TestHashEmbedder_ShouldRankSharedWordsAboveUnrelatedText(t *testing.T) {
+	vecs, err := NewHashing().Embed([]string{
+		"fix the flaky authentication test",
+		"the auth test is flaky again",
+		"deploy the frontend to staging",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	related, unrelated := cosine(vecs[0], vecs[1]), cosine(vecs[0], vecs[2])
+	if related <= unrelated {
+		t.Errorf("expected related text closer: %.3f vs %.3f", related, unrelated)
+	}
+	if unrelated > 0.2 {
+		t.Errorf("expected unrelated text near orthogonal, got %.3f", unrelated)
+	}
+}
+
+func TestHashEmbedder_ShouldReturnDeterministicUnitVectors(t *testing.T) {
+	e := NewHashing()
+	for _, text := range []string{"Refactor the store package", "", "the and of", "!!!"} {
+		a, _ := e.Embed([]string{text})
+		b, _ := e.Embed([]string{text})
+		if len(a[0]) != e.Dimension() {
+			t.Fatalf("%q: expected %d dimensions, got %d", text, e.Dimension(), len(a[0]))
+		}
+		var norm float64
+		for i, x := range a[0] {
+			if x != b[0][i] {
+				t.Fatalf("%q: expected the same vector twice", text)
+			}
+			norm += float64(x) * float64(x)
+		}
+		if math.Abs(norm-1) > 1e-5 {
+			t.Errorf("%q: expected unit length, got %v", text, math.Sqrt(norm))
+		}
+	}
+}
+
+func TestHashEmbedder_ShouldIgnoreCaseAndPunctuation(t *testing.T) {
+	vecs, _ := NewHashing().Embed([]string{"Store.Open failed!", "store open failed"})
+	if c := cosine(vecs[0], vecs[1]); c < 0.999 {
+		t.Errorf("expected identical vectors, got cosine %.4f", c)
+	}
+}
@@ -3,10 +3,28 @@ package embedding
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+)
+
+// Retry policy for transient failures: 429 and 5xx responses, and
+// requests that time out.
+const (
+	maxAttempts = 5
+	baseDelay   = time.Second
+	maxDelay    = 30 * time.Second
+	// maxRetryAfter is the longest Retry-After honoured; a provider asking
+	// for a longer wait is treated as having failed.
+	maxRetryAfter = 2 * time.Minute
 )
 
 // HTTPEmbedder calls an OpenAI-compatible embeddings API.
@@ -14,6 +32,10 @@ type HTTPEmbedder struct {
 	provider Provider
 	apiKey   string
 	client   *http.Client
+	// limit spaces requests out when a rate limit is set.
+	limit *limiter
+	// sleep waits between attempts; tests replace it.
+	sleep func(time.Duration)
 }
 
 // NewHTTP creates an embedder for the given provider and API key.
@@ -22,12 +44,57 @@ func NewHTTP(provider Provider, apiKey string) *HTTPEmbedder {
 		provider: provider,
 		apiKey:   apiKey,
 		client:   &http.Client{Timeout: 60 * time.Second},
+		sleep:    time.Sleep,
 	}
 }
 
 func (e *HTTPEmbedder) Dimension() int { return e.provider.Dimension }
 
+// Model returns the provider and model name, e.g. "OpenAI/text-embedding-3-small".
+func (e *HTTPEmbedder) Model() string { return e.provider.Name + "/" + e.provider.Model }
+
+// MaxTokens returns the provider's per-text input limit.
+func (e *HTTPEmbedder) MaxTokens() int { return e.provider.MaxTokens }
+
+// BatchLimits returns the provider's per-request limits.
+func (e *HTTPEmbedder) BatchLimits() (texts, tokens int) {
+	return e.provider.MaxBatchTexts, e.provider.MaxBatchTokens
+}
+
+// SetRateLimit caps requests, retries included, at rpm per minute.
+// Zero removes the cap.
+func (e *HTTPEmbedder) SetRateLimit(rpm int) {
+	e.limit = nil
+	if rpm > 0 {
+		e.limit = &limiter{interval: time.Minute / time.Duration(rpm)}
+	}
+}
+
+// StatusError is an error response from an embeddings API.
+type StatusError struct {
+	Provider string
+	Code     int
+	Body     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s API returned %d: %s", e.Provider, e.Code, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// Rejected reports whether the provider refused the input itself, for
+// example as too long, rather than the request.
+func (e *StatusError) Rejected() bool {
+	return e.Code == http.StatusBadRequest || e.Code == http.StatusRequestEntityTooLarge ||
+		e.Code == http.StatusUnprocessableEntity
+}
+
 // Embed sends texts to the embedding API and returns the resulting vectors.
+// Transient failures are retried with exponential backoff and jitter.
 func (e *HTTPEmbedder) Embed(texts []string) ([][]float32, error) {
 	reqBody, err := json.Marshal(embeddingRequest{
 		Input: texts,
@@ -37,43 +104,134 @@ func (e *HTTPEmbedder) Embed(texts []string) ([][]float32, error) {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
+	for attempt := 1; ; attempt++ {
+		vecs, wait, err := e.post(reqBody)
+		if err == nil {
+			return vecs, nil
+		}
+		if wait < 0 || attempt == maxAttempts {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = backoff(attempt)
+		}
+		e.sleep(wait)
+	}
+}
+
+// post makes one request. On failure it also returns how long to wait
+// before retrying: 0 for the usual backoff, or -1 if retrying can't help.
+func (e *HTTPEmbedder) post(reqBody []byte) ([][]float32, time.Duration, error) {
+	if e.limit != nil {
+		e.limit.wait(e.sleep)
+	}
+
 	req, err := http.NewRequest(http.MethodPost, e.provider.Endpoint, bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, -1, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	for name, value := range e.provider.Headers {
+		req.Header.Set(name, value)
+	}
+	switch {
+	case e.apiKey == "":
+	case e.provider.AuthHeader != "":
+		req.Header.Set(e.provider.AuthHeader, e.apiKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
 
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request to %s: %w", e.provider.Name, err)
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return nil, 0, fmt.Errorf("request to %s: %w", e.provider.Name, err)
+		}
+		return nil, -1, fmt.Errorf("request to %s: %w", e.provider.Name, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+		return nil, 0, fmt.Errorf("read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s API returned %d: %s", e.provider.Name, resp.StatusCode, truncate(string(body), 200))
+		se := &StatusError{Provider: e.provider.Name, Code: resp.StatusCode, Body: truncate(string(body), 200)}
+		if !se.Temporary() {
+			return nil, -1, se
+		}
+		wait := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if wait > maxRetryAfter {
+			return nil, -1, se
+		}
+		return nil, wait, se
 	}
 
 	var result embeddingResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("unmarshal response: %w", err)
+		return nil, -1, fmt.Errorf("unmarshal response: %w", err)
 	}
 
 	embeddings := make([][]float32, len(result.Data))
 	for i, d := range result.Data {
 		embeddings[i] = d.Embedding
 	}
-	return embeddings, nil
+	return embeddings, 0, nil
+}
+
+// backoff returns the wait before retry number attempt: doubling from
+// baseDelay up to maxDelay, with jitter over the upper half so clients
+// that failed together don't retry together.
+func backoff(attempt int) time.Duration {
+	d := maxDelay
+	if attempt < 16 {
+		d = min(baseDelay<<(attempt-1), maxDelay)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date. It returns 0 when the header is absent or unreadable.
+func retryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// limiter spaces requests evenly to stay under a requests-per-minute cap.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next request may be sent.
+func (l *limiter) wait(sleep func(time.Duration)) {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	if d := at.Sub(now); d > 0 {
+		sleep(d)
+	}
 }
 
 type embeddingRequest struct {
 	Input []string `json:"input"`
-	Model string   `json:"model"`
+	Model string   `json:"model,omitempty"`
 }
 
 type embeddingResponse struct {
@@ -84,9 +242,13 @@ type embeddingData struct {
 	Embedding []float32 `json:"embedding"`
 }
 
+// truncate cuts s to at most max bytes without splitting a rune.
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
 	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
 	return s[:max] + "..."
 }
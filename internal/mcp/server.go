@@ -0,0 +1,213 @@
+// Package mcp serves clog's search over the Model Context Protocol, so an
+// agent can query its own history as tools instead of parsing CLI text.
+//
+// The transport is stdio: one JSON-RPC 2.0 message per line in each
+// direction. The project searched is the client's first root with a clog
+// database, falling back to the directory the server was started in.
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"clog/internal/config"
+	"clog/internal/embedding"
+	"clog/internal/store"
+)
+
+// protocolVersions are the MCP revisions understood, newest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// rootsRequestID identifies the server's roots/list requests.
+const rootsRequestID = "clog-roots"
+
+// Server answers MCP requests for one client.
+type Server struct {
+	// dir is the project searched, updated when the client lists roots.
+	dir string
+	// open opens the store for a project directory.
+	open func(dir string) (store.Store, error)
+	// embedder returns the provider used by search_semantic.
+	embedder func() (embedding.Embedder, error)
+
+	out         *json.Encoder
+	clientRoots bool
+}
+
+// New returns a server for the project in dir, opening its database
+// read-only for each call so hooks can keep writing between them.
+func New(dir string) *Server {
+	return &Server{dir: dir, open: openProject, embedder: embedding.NewFromEnv}
+}
+
+func openProject(dir string) (store.Store, error) {
+	cfg := config.Default()
+	return store.OpenProjectReadOnly(cfg.Backend, cfg.DBPath(dir))
+}
+
+// message is any JSON-RPC message: a request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// Serve handles messages from r until it ends, writing replies to w.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.out = json.NewEncoder(w)
+	in := bufio.NewScanner(r)
+	in.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for in.Scan() {
+		line := in.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := s.handle(line); err != nil {
+			return err
+		}
+	}
+	if err := in.Err(); err != nil {
+		return fmt.Errorf("read request: %w", err)
+	}
+	return nil
+}
+
+// handle dispatches one message. Only write errors are returned; protocol
+// errors are reported to the client.
+func (s *Server) handle(line []byte) error {
+	var m message
+	if err := json.Unmarshal(line, &m); err != nil {
+		return s.reply(json.RawMessage("null"), nil, &rpcError{codeParseError, "parse error: " + err.Error()})
+	}
+	if m.Method == "" {
+		// A response to one of our requests.
+		if string(m.ID) == `"`+rootsRequestID+`"` && m.Error == nil {
+			s.useRoots(m.Result)
+		}
+		return nil
+	}
+	if m.ID == nil {
+		return s.notify(m)
+	}
+	if m.JSONRPC != "2.0" {
+		return s.reply(m.ID, nil, &rpcError{codeInvalidRequest, `jsonrpc must be "2.0"`})
+	}
+
+	switch m.Method {
+	case "initialize":
+		return s.reply(m.ID, s.initialize(m.Params), nil)
+	case "ping":
+		return s.reply(m.ID, struct{}{}, nil)
+	case "tools/list":
+		return s.reply(m.ID, map[string]interface{}{"tools": toolList()}, nil)
+	case "tools/call":
+		result, rpcErr := s.callTool(m.Params)
+		return s.reply(m.ID, result, rpcErr)
+	}
+	return s.reply(m.ID, nil, &rpcError{codeMethodNotFound, "method not found: " + m.Method})
+}
+
+// notify handles a notification, which gets no reply.
+func (s *Server) notify(m message) error {
+	switch m.Method {
+	case "notifications/initialized", "notifications/roots/list_changed":
+		if s.clientRoots {
+			return s.out.Encode(map[string]interface{}{
+				"jsonrpc": "2.0", "id": rootsRequestID, "method": "roots/list",
+			})
+		}
+	}
+	return nil
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, err *rpcError) error {
+	if err == nil && result == nil {
+		result = struct{}{}
+	}
+	return s.out.Encode(response{JSONRPC: "2.0", ID: id, Result: result, Error: err})
+}
+
+// initialize agrees on a protocol version and notes whether the client
+// can tell us its roots.
+func (s *Server) initialize(params json.RawMessage) interface{} {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		Capabilities    struct {
+			Roots *json.RawMessage `json:"roots"`
+		} `json:"capabilities"`
+	}
+	json.Unmarshal(params, &p)
+	s.clientRoots = p.Capabilities.Roots != nil
+
+	version := protocolVersions[0]
+	for _, v := range protocolVersions {
+		if v == p.ProtocolVersion {
+			version = v
+		}
+	}
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+		"serverInfo":      map[string]string{"name": "clog", "version": "1"},
+		"instructions": "Search this project's Claude Code history: past prompts, answers, " +
+			"tool calls and session summaries. Results are clog's versioned JSON output.",
+	}
+}
+
+// useRoots scopes the server to the first file root that has a database,
+// or the first file root if none has one yet.
+func (s *Server) useRoots(result json.RawMessage) {
+	var r struct {
+		Roots []struct {
+			URI string `json:"uri"`
+		} `json:"roots"`
+	}
+	if json.Unmarshal(result, &r) != nil {
+		return
+	}
+	var dirs []string
+	for _, root := range r.Roots {
+		u, err := url.Parse(root.URI)
+		if err == nil && u.Scheme == "file" && u.Path != "" {
+			dirs = append(dirs, u.Path)
+		}
+	}
+	if len(dirs) == 0 {
+		return
+	}
+	cfg := config.Default()
+	for _, d := range dirs {
+		if _, err := os.Stat(cfg.DBPath(d)); err == nil {
+			s.dir = d
+			return
+		}
+	}
+	s.dir = dirs[0]
+}
@@ -0,0 +1,290 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"clog/internal/config"
+	"clog/internal/embedding"
+	"clog/internal/model"
+	"clog/internal/store"
+)
+
+// newTestServer returns a server over a seeded SQLite store.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.sqlite")
+	st, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	if err := st.InitCoreSchema(); err != nil {
+		t.Fatalf("init core schema: %v", err)
+	}
+	if err := st.InitEmbeddingSchema(2); err != nil {
+		t.Fatalf("init embedding schema: %v", err)
+	}
+	now := time.Now()
+	st.UpsertSession(model.Session{ID: "sess-1", CWD: "/proj", CreatedAt: now})
+	st.SaveHarvestedMessages([]model.Message{
+		{SessionID: "sess-1", UUID: "m1", Role: "user", Content: "please fix the flaky auth test", Timestamp: now},
+		{SessionID: "sess-1", UUID: "m2", Role: "assistant", Content: "the cache is warm now", Timestamp: now},
+	}, "/t.jsonl", 1)
+	msgs, _ := st.UnembeddedMessages(10)
+	for _, m := range msgs {
+		vec := []float32{1, 0}
+		if strings.Contains(m.Content, "cache") {
+			vec = []float32{0, 1}
+		}
+		st.SaveEmbedding(m.ID, vec)
+	}
+
+	s := New("/proj")
+	s.open = func(string) (store.Store, error) { return store.OpenSQLite(path) }
+	s.embedder = func() (embedding.Embedder, error) { return fakeEmbedder{}, nil }
+	return s
+}
+
+// fakeEmbedder maps text mentioning caches to one axis, the rest to the other.
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) Embed(texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = []float32{1, 0}
+		if strings.Contains(t, "cache") {
+			out[i] = []float32{0, 1}
+		}
+	}
+	return out, nil
+}
+
+func (fakeEmbedder) Dimension() int { return 2 }
+
+// exchange sends each request line and returns the decoded replies.
+func exchange(t *testing.T, s *Server, lines ...string) []message {
+	t.Helper()
+	var out strings.Builder
+	if err := s.Serve(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	var replies []message
+	dec := json.NewDecoder(strings.NewReader(out.String()))
+	for dec.More() {
+		var m message
+		if err := dec.Decode(&m); err != nil {
+			t.Fatalf("decode reply: %v\n%s", err, out.String())
+		}
+		replies = append(replies, m)
+	}
+	return replies
+}
+
+// callResult decodes a tools/call result.
+func callResult(t *testing.T, m message) (text string, isError bool) {
+	t.Helper()
+	if m.Error != nil {
+		t.Fatalf("expected a result, got error %+v", m.Error)
+	}
+	var r struct {
+		Content []struct{ Type, Text string }
+		IsError bool
+	}
+	if err := json.Unmarshal(m.Result, &r); err != nil || len(r.Content) != 1 {
+		t.Fatalf("malformed tool result %s: %v", m.Result, err)
+	}
+	return r.Content[0].Text, r.IsError
+}
+
+func call(id int, name, arguments string) string {
+	return `{"jsonrpc":"2.0","id":` + strconv.Itoa(id) + `,"method":"tools/call","params":{"name":"` + name + `","arguments":` + arguments + `}}`
+}
+
+func TestServe_WhenInitialized_ShouldNegotiateVersionAndIgnoreNotifications(t *testing.T) {
+	replies := exchange(t, newTestServer(t),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+	)
+	if len(replies) != 2 {
+		t.Fatalf("expected replies to initialize and ping only, got %+v", replies)
+	}
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		Capabilities    struct {
+			Tools *struct{} `json:"tools"`
+		} `json:"capabilities"`
+		ServerInfo struct{ Name string } `json:"serverInfo"`
+	}
+	json.Unmarshal(replies[0].Result, &init)
+	if init.ProtocolVersion != "2025-03-26" || init.Capabilities.Tools == nil || init.ServerInfo.Name != "clog" {
+		t.Errorf("unexpected initialize result %s", replies[0].Result)
+	}
+	if string(replies[1].ID) != "2" || replies[1].Error != nil {
+		t.Errorf("expected ping result, got %+v", replies[1])
+	}
+}
+
+func TestServe_WhenVersionUnknown_ShouldOfferLatest(t *testing.T) {
+	replies := exchange(t, newTestServer(t),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01","capabilities":{}}}`)
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(replies[0].Result, &init)
+	if init.ProtocolVersion != protocolVersions[0] {
+		t.Errorf("expected %s, got %s", protocolVersions[0], init.ProtocolVersion)
+	}
+}
+
+func TestServe_WhenRequestInvalid_ShouldReturnJSONRPCErrors(t *testing.T) {
+	replies := exchange(t, newTestServer(t),
+		`not json`,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`,
+		call(2, "no_such_tool", `{}`),
+	)
+	want := []int{codeParseError, codeMethodNotFound, codeInvalidParams}
+	if len(replies) != len(want) {
+		t.Fatalf("expected %d replies, got %+v", len(want), replies)
+	}
+	for i, code := range want {
+		if replies[i].Error == nil || replies[i].Error.Code != code {
+			t.Errorf("reply %d: expected error %d, got %+v", i, code, replies[i])
+		}
+	}
+}
+
+func TestServe_ToolsList_ShouldDescribeEveryToolWithSchema(t *testing.T) {
+	replies := exchange(t, newTestServer(t), `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	var r struct {
+		Tools []struct {
+			Name        string
+			Description string
+			InputSchema struct {
+				Type       string
+				Properties map[string]interface{}
+				Required   []string
+			}
+		}
+	}
+	if err := json.Unmarshal(replies[0].Result, &r); err != nil {
+		t.Fatalf("decode tools: %v", err)
+	}
+	got := map[string]bool{}
+	for _, tl := range r.Tools {
+		got[tl.Name] = true
+		if tl.Description == "" || tl.InputSchema.Type != "object" || len(tl.InputSchema.Properties) == 0 {
+			t.Errorf("%s: incomplete description or schema: %+v", tl.Name, tl)
+		}
+		for _, req := range tl.InputSchema.Required {
+			if tl.InputSchema.Properties[req] == nil {
+				t.Errorf("%s: required %q has no schema", tl.Name, req)
+			}
+		}
+	}
+	for _, name := range []string{"search_text", "search_semantic", "search_tool_calls", "list_sessions", "get_session", "changelog"} {
+		if !got[name] {
+			t.Errorf("missing tool %s", name)
+		}
+	}
+}
+
+func TestServe_ToolsCall_ShouldReturnVersionedResults(t *testing.T) {
+	replies := exchange(t, newTestServer(t),
+		call(1, "search_text", `{"query":"flaky role:user"}`),
+		call(2, "search_semantic", `{"query":"cache","limit":1}`),
+		call(3, "get_session", `{"session":"sess"}`),
+		call(4, "list_sessions", `{}`),
+	)
+	want := []struct{ kind, contains string }{
+		{"message", "flaky auth test"},
+		{"message", "cache is warm"},
+		{"timeline_entry", "flaky auth test"},
+		{"session", "sess-1"},
+	}
+	for i, w := range want {
+		text, isError := callResult(t, replies[i])
+		if isError {
+			t.Errorf("call %d: unexpected tool error %q", i+1, text)
+			continue
+		}
+		if !strings.Contains(text, `"kind": "`+w.kind+`"`) || !strings.Contains(text, w.contains) {
+			t.Errorf("call %d: expected %s containing %q, got %s", i+1, w.kind, w.contains, text)
+		}
+	}
+	if text, _ := callResult(t, replies[1]); strings.Contains(text, "flaky") {
+		t.Errorf("expected only the nearest message from search_semantic, got %s", text)
+	}
+}
+
+func TestServe_GetSession_ShouldReturnSessionInsideJSON(t *testing.T) {
+	replies := exchange(t, newTestServer(t), call(1, "get_session", `{"session":"sess","limit":1}`))
+	text, isError := callResult(t, replies[0])
+	if isError {
+		t.Fatalf("unexpected tool error %q", text)
+	}
+	var page struct {
+		Kind    string `json:"kind"`
+		Session struct {
+			ID string `json:"session_id"`
+		} `json:"session"`
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(text), &page); err != nil {
+		t.Fatalf("expected the whole result to be JSON: %v\n%s", err, text)
+	}
+	if page.Kind != "timeline_entry" || page.Session.ID != "sess-1" || page.Count != 1 {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestServe_WhenToolFails_ShouldReturnErrorResult(t *testing.T) {
+	replies := exchange(t, newTestServer(t),
+		call(1, "search_text", `{}`),
+		call(2, "search_text", `{"query":"colour:red"}`),
+		call(3, "search_text", `{"query":"x","bogus":1}`),
+		call(4, "search_semantic", `{"query":"role:user"}`),
+		call(5, "get_session", `{"session":"sess","offset":-1}`),
+	)
+	for i, want := range []string{"query is required", "unknown field", "bogus", "plain words", "must not be negative"} {
+		text, isError := callResult(t, replies[i])
+		if !isError || !strings.Contains(text, want) {
+			t.Errorf("call %d: expected error mentioning %q, got %q (isError=%v)", i+1, want, text, isError)
+		}
+	}
+}
+
+func TestServe_WhenClientHasRoots_ShouldScopeToRootWithDatabase(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLOG_BACKEND", "sqlite")
+	dbPath := config.Default().DBPath("/work/app")
+	os.MkdirAll(filepath.Dir(dbPath), 0o755)
+	os.WriteFile(dbPath, nil, 0o644)
+
+	s := newTestServer(t)
+	open := s.open
+	var opened string
+	s.open = func(dir string) (store.Store, error) {
+		opened = dir
+		return open(dir)
+	}
+	replies := exchange(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"roots":{}}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":"`+rootsRequestID+`","result":{"roots":[{"uri":"file:///work/other"},{"uri":"file:///work/app"}]}}`,
+		call(2, "changelog", `{}`),
+	)
+	if len(replies) != 3 || replies[1].Method != "roots/list" {
+		t.Fatalf("expected a roots/list request after initialized, got %+v", replies)
+	}
+	if opened != "/work/app" {
+		t.Errorf("expected the root with a database, got %q", opened)
+	}
+}
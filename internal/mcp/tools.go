@@ -0,0 +1,334 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"clog/internal/embedding"
+	"clog/internal/model"
+	"clog/internal/output"
+	"clog/internal/search"
+	"clog/internal/store"
+)
+
+// tool is one MCP tool: its schema and the function answering it.
+type tool struct {
+	name        string
+	description string
+	// required lists the properties that must be given.
+	required   []string
+	properties map[string]interface{}
+	call       func(s *Server, st store.Store, a args) (string, error)
+}
+
+// args holds every tool's arguments; each tool reads its own.
+type args struct {
+	Query   string `json:"query"`
+	Session string `json:"session"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+	Sort    string `json:"sort"`
+	Since   string `json:"since"`
+	Until   string `json:"until"`
+}
+
+func (a args) limit(def int) int {
+	if a.Limit > 0 {
+		return a.Limit
+	}
+	return def
+}
+
+func (a args) timeFilter() (*model.TimeFilter, error) {
+	return model.ParseTimeFilter(a.Since, a.Until)
+}
+
+// Property schemas shared between tools.
+var (
+	queryProp = prop("string", "Search query: words and \"phrases\" must all match; "+
+		"field:value restricts (role:user|assistant, session:PREFIX, model:NAME, agent:ID|TYPE, "+
+		"tool:NAME, file:PATH, after:TIME, before:TIME); -term excludes.")
+	limitProp = prop("integer", "Maximum results.")
+	sinceProp = prop("string", "Only results after this time: 30m, 2h, 1d, 1w or a date such as 2024-01-15.")
+	untilProp = prop("string", "Only results before this time, in the same forms as since.")
+)
+
+func prop(typ, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
+
+var tools = []tool{
+	{
+		name:        "search_text",
+		description: "Find past messages containing the query's words, newest first.",
+		required:    []string{"query"},
+		properties:  map[string]interface{}{"query": queryProp, "limit": limitProp, "since": sinceProp, "until": untilProp},
+		call:        searchText,
+	},
+	{
+		name: "search_semantic",
+		description: "Find past messages by meaning: plain words are embedded and ranked by similarity, " +
+			"fields and phrases pre-filter. Needs embeddings from 'clog -e'.",
+		required:   []string{"query"},
+		properties: map[string]interface{}{"query": queryProp, "limit": limitProp, "since": sinceProp, "until": untilProp},
+		call:       searchSemantic,
+	},
+	{
+		name:        "search_tool_calls",
+		description: "Find past tool calls by name, input and output, newest first. Use \"*\" for all.",
+		required:    []string{"query"},
+		properties:  map[string]interface{}{"query": queryProp, "limit": limitProp, "since": sinceProp, "until": untilProp},
+		call:        searchToolCalls,
+	},
+	{
+		name:        "list_sessions",
+		description: "List sessions with message and tool counts, models and first prompt.",
+		properties: map[string]interface{}{
+			"limit": limitProp,
+			"sort":  map[string]interface{}{"type": "string", "enum": []string{"start", "end", "duration", "messages", "tools"}},
+			"since": sinceProp,
+			"until": untilProp,
+		},
+		call: listSessions,
+	},
+	{
+		name:        "get_session",
+		description: "Read one session's messages and tool calls in order, with its summary.",
+		required:    []string{"session"},
+		properties: map[string]interface{}{
+			"session": prop("string", "Session id or a unique prefix of it."),
+			"limit":   limitProp,
+			"offset":  prop("integer", "Skip this many entries."),
+		},
+		call: getSession,
+	},
+	{
+		name:        "changelog",
+		description: "List session summaries, newest first: what was done in this project and when.",
+		properties:  map[string]interface{}{"limit": limitProp, "since": sinceProp, "until": untilProp},
+		call:        changelog,
+	},
+}
+
+// toolList describes the tools for tools/list.
+func toolList() []interface{} {
+	out := make([]interface{}, len(tools))
+	for i, t := range tools {
+		schema := map[string]interface{}{"type": "object", "properties": t.properties, "additionalProperties": false}
+		if len(t.required) > 0 {
+			schema["required"] = t.required
+		}
+		out[i] = map[string]interface{}{"name": t.name, "description": t.description, "inputSchema": schema}
+	}
+	return out
+}
+
+// callTool runs a tools/call request. Failures of the tool itself are
+// results with isError set, so the model can see and correct them.
+func (s *Server) callTool(params json.RawMessage) (interface{}, *rpcError) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{codeInvalidParams, "invalid params: " + err.Error()}
+	}
+	var t *tool
+	for i := range tools {
+		if tools[i].name == p.Name {
+			t = &tools[i]
+		}
+	}
+	if t == nil {
+		return nil, &rpcError{codeInvalidParams, "unknown tool: " + p.Name}
+	}
+
+	var a args
+	if len(p.Arguments) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(p.Arguments))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&a); err != nil {
+			return toolError(fmt.Errorf("arguments: %w", err)), nil
+		}
+	}
+	for _, r := range t.required {
+		if (r == "query" && a.Query == "") || (r == "session" && a.Session == "") {
+			return toolError(fmt.Errorf("%s is required", r)), nil
+		}
+	}
+
+	st, err := s.open(s.dir)
+	if err != nil {
+		return toolError(err), nil
+	}
+	defer st.Close()
+	text, err := t.call(s, st, a)
+	if err != nil {
+		return toolError(err), nil
+	}
+	return map[string]interface{}{"content": []interface{}{textContent(text)}}, nil
+}
+
+func textContent(text string) map[string]string {
+	return map[string]string{"type": "text", "text": text}
+}
+
+func toolError(err error) map[string]interface{} {
+	return map[string]interface{}{"content": []interface{}{textContent(err.Error())}, "isError": true}
+}
+
+// encode renders results as clog's JSON output.
+func encode(set output.Set) (string, error) {
+	var b bytes.Buffer
+	if err := output.Write(&b, output.JSON, set); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func searchText(_ *Server, st store.Store, a args) (string, error) {
+	q, err := search.Parse(a.Query)
+	if err != nil {
+		return "", err
+	}
+	tf, err := a.timeFilter()
+	if err != nil {
+		return "", err
+	}
+	results, err := st.TextSearch(q, a.limit(20), false, tf, nil)
+	if err != nil {
+		return "", fmt.Errorf("text search: %w", err)
+	}
+	return encode(output.Messages(results))
+}
+
+func searchSemantic(s *Server, st store.Store, a args) (string, error) {
+	q, err := search.Parse(a.Query)
+	if err != nil {
+		return "", err
+	}
+	text := q.Text()
+	if text == "" {
+		return "", fmt.Errorf("semantic search needs some plain words to embed; only filters were given")
+	}
+	tf, err := a.timeFilter()
+	if err != nil {
+		return "", err
+	}
+	emb, err := s.embedder()
+	if err != nil {
+		return "", err
+	}
+	if err := store.CheckEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension()); err != nil {
+		return "", err
+	}
+	if err := st.LoadVSS(); err != nil {
+		return "", fmt.Errorf("load vss: %w", err)
+	}
+	vecs, err := emb.Embed([]string{text})
+	if err != nil {
+		return "", fmt.Errorf("embed query: %w", err)
+	}
+	results, err := st.SearchSimilar(vecs[0], q.Filters(), a.limit(10), false, tf, nil)
+	if err != nil {
+		return "", fmt.Errorf("search: %w", err)
+	}
+	return encode(output.Messages(results))
+}
+
+func searchToolCalls(_ *Server, st store.Store, a args) (string, error) {
+	q, err := search.Parse(a.Query)
+	if err != nil {
+		return "", err
+	}
+	tf, err := a.timeFilter()
+	if err != nil {
+		return "", err
+	}
+	results, err := st.ToolSearch(q, a.limit(20), tf, nil)
+	if err != nil {
+		return "", fmt.Errorf("tool search: %w", err)
+	}
+	return encode(output.ToolCalls(results))
+}
+
+func listSessions(_ *Server, st store.Store, a args) (string, error) {
+	tf, err := a.timeFilter()
+	if err != nil {
+		return "", err
+	}
+	sortBy := a.Sort
+	if sortBy == "" {
+		sortBy = "start"
+	}
+	results, err := st.ListSessions(a.limit(20), sortBy, tf)
+	if err != nil {
+		return "", fmt.Errorf("list sessions: %w", err)
+	}
+	return encode(output.Sessions(results))
+}
+
+// getSession returns the session's summary, if any, before its timeline.
+// sessionPage is get_session's result: the timeline envelope of the other
+// tools, with the session and its summary alongside the entries.
+type sessionPage struct {
+	Version int                   `json:"version"`
+	Kind    string                `json:"kind"`
+	Session sessionInfo           `json:"session"`
+	Summary *model.SummaryResult  `json:"summary"`
+	Count   int                   `json:"count"`
+	Results []model.TimelineEntry `json:"results"`
+}
+
+type sessionInfo struct {
+	ID        string    `json:"session_id"`
+	CWD       string    `json:"cwd"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func getSession(_ *Server, st store.Store, a args) (string, error) {
+	if a.Offset < 0 || a.Limit < 0 {
+		return "", fmt.Errorf("offset and limit must not be negative")
+	}
+	sess, err := st.ResolveSession(a.Session)
+	if err != nil {
+		return "", err
+	}
+	entries, err := st.SessionTimeline(sess.ID, a.limit(200), a.Offset)
+	if err != nil {
+		return "", fmt.Errorf("session timeline: %w", err)
+	}
+	sum, err := st.SessionSummary(sess.ID)
+	if err != nil {
+		return "", fmt.Errorf("session summary: %w", err)
+	}
+	if entries == nil {
+		entries = []model.TimelineEntry{}
+	}
+	out, err := json.MarshalIndent(sessionPage{
+		Version: output.Version,
+		Kind:    output.KindTimelineEntry,
+		Session: sessionInfo{ID: sess.ID, CWD: sess.CWD, StartedAt: sess.CreatedAt},
+		Summary: sum,
+		Count:   len(entries),
+		Results: entries,
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode session: %w", err)
+	}
+	return string(out) + "\n", nil
+}
+
+func changelog(_ *Server, st store.Store, a args) (string, error) {
+	tf, err := a.timeFilter()
+	if err != nil {
+		return "", err
+	}
+	results, err := st.ListSummaries(a.limit(20), tf)
+	if err != nil {
+		return "", fmt.Errorf("list summaries: %w", err)
+	}
+	return encode(output.Summaries(results))
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// BashExecution is one Bash tool call parsed into structured fields.
+type BashExecution struct {
+	SessionID   string     `json:"session_id"`
+	ToolUseID   string     `json:"tool_use_id"`
+	Command     string     `json:"command"`
+	Description string     `json:"description"`
+	Stdout      string     `json:"stdout"`
+	Stderr      string     `json:"stderr"`
+	Error       string     `json:"error"`     // tool failure message, if any
+	ExitCode    *int       `json:"exit_code"` // nil when unknown (e.g. interrupted)
+	Interrupted bool       `json:"interrupted"`
+	StartedAt   *time.Time `json:"started_at"` // from the matching PreToolUse event, if recorded
+	Timestamp   time.Time  `json:"timestamp"`  // when the call finished
+}
+
+// Failed reports whether the command exited non-zero, errored or was interrupted.
+func (b BashExecution) Failed() bool {
+	return b.Interrupted || b.Error != "" || (b.ExitCode != nil && *b.ExitCode != 0)
+}
+
+// Duration returns the command's wall time, or 0 when its start is unknown.
+func (b BashExecution) Duration() time.Duration {
+	if b.StartedAt == nil || b.Timestamp.Before(*b.StartedAt) {
+		return 0
+	}
+	return b.Timestamp.Sub(*b.StartedAt)
+}
+
+// exitCodeRe matches the "Exit code N" prefix Claude Code puts on failed
+// Bash calls.
+var exitCodeRe = regexp.MustCompile(`^Exit code (-?\d+)`)
+
+// ParseBashExecution extracts a Bash call from a PostToolUse or
+// PostToolUseFailure event. It reports false for any other event.
+func ParseBashExecution(e Event) (BashExecution, bool) {
+	if e.ToolName == nil || *e.ToolName != "Bash" ||
+		(e.EventType != "PostToolUse" && e.EventType != "PostToolUseFailure") {
+		return BashExecution{}, false
+	}
+
+	var input struct {
+		Command     string `json:"command"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(e.ToolInput, &input); err != nil || input.Command == "" {
+		return BashExecution{}, false
+	}
+	var response struct {
+		Stdout      string `json:"stdout"`
+		Stderr      string `json:"stderr"`
+		Interrupted bool   `json:"interrupted"`
+	}
+	// Failures may carry no response at all; a missing one is not an error.
+	json.Unmarshal(e.ToolResponse, &response)
+
+	b := BashExecution{
+		SessionID:   e.SessionID,
+		Command:     input.Command,
+		Description: input.Description,
+		Stdout:      response.Stdout,
+		Stderr:      response.Stderr,
+		Interrupted: response.Interrupted || (e.IsInterrupt != nil && *e.IsInterrupt),
+		Timestamp:   e.Timestamp,
+	}
+	if e.ToolUseID != nil {
+		b.ToolUseID = *e.ToolUseID
+	}
+	if e.Error != nil {
+		b.Error = *e.Error
+	}
+
+	switch {
+	case b.Interrupted:
+	case e.EventType == "PostToolUse" && b.Error == "":
+		code := 0
+		b.ExitCode = &code
+	default:
+		if m := exitCodeRe.FindStringSubmatch(b.Error); m != nil {
+			if code, err := strconv.Atoi(m[1]); err == nil {
+				b.ExitCode = &code
+			}
+		}
+	}
+	return b, true
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func bashEvent(eventType, input, response string) Event {
+	tool, id := "Bash", "tu-1"
+	return Event{
+		SessionID: "s1", EventType: eventType, ToolName: &tool, ToolUseID: &id,
+		ToolInput: json.RawMessage(input), ToolResponse: json.RawMessage(response),
+	}
+}
+
+func TestParseBashExecution_WhenSucceeded_ShouldReportExitZero(t *testing.T) {
+	got, ok := ParseBashExecution(bashEvent("PostToolUse",
+		`{"command":"ls","description":"list"}`, `{"stdout":"a.go","stderr":"","interrupted":false}`))
+	if !ok {
+		t.Fatal("expected a bash execution")
+	}
+	if got.Command != "ls" || got.Description != "list" || got.Stdout != "a.go" || got.ToolUseID != "tu-1" {
+		t.Errorf("unexpected fields %+v", got)
+	}
+	if got.ExitCode == nil || *got.ExitCode != 0 || got.Failed() {
+		t.Errorf("expected exit 0 and not failed, got %+v", got)
+	}
+}
+
+func TestParseBashExecution_WhenFailed_ShouldParseExitCodeFromError(t *testing.T) {
+	e := bashEvent("PostToolUseFailure", `{"command":"go test"}`, ``)
+	msg := "Exit code 2\nFAIL clog"
+	e.Error = &msg
+	got, ok := ParseBashExecution(e)
+	if !ok {
+		t.Fatal("expected a bash execution")
+	}
+	if got.ExitCode == nil || *got.ExitCode != 2 || !got.Failed() {
+		t.Errorf("expected exit 2 and failed, got %+v", got)
+	}
+}
+
+func TestParseBashExecution_WhenInterrupted_ShouldLeaveExitCodeUnknown(t *testing.T) {
+	got, _ := ParseBashExecution(bashEvent("PostToolUse", `{"command":"sleep 99"}`, `{"interrupted":true}`))
+	if !got.Interrupted || got.ExitCode != nil || !got.Failed() {
+		t.Errorf("expected interrupted with unknown exit, got %+v", got)
+	}
+}
+
+func TestParseBashExecution_WhenNotBash_ShouldReturnFalse(t *testing.T) {
+	e := bashEvent("PostToolUse", `{"command":"ls"}`, `{}`)
+	read := "Read"
+	e.ToolName = &read
+	if _, ok := ParseBashExecution(e); ok {
+		t.Error("expected no bash execution for Read")
+	}
+}
+
+func TestParseBashExecution_WhenPreToolUse_ShouldReturnFalse(t *testing.T) {
+	if _, ok := ParseBashExecution(bashEvent("PreToolUse", `{"command":"ls"}`, ``)); ok {
+		t.Error("expected no bash execution before the tool ran")
+	}
+}
+
+func TestBashExecution_Duration_WhenStartUnknown_ShouldBeZero(t *testing.T) {
+	if d := (BashExecution{Timestamp: time.Now()}).Duration(); d != 0 {
+		t.Errorf("expected 0, got %v", d)
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File operations recorded in the file_activity table.
+const (
+	FileRead  = "read"
+	FileEdit  = "edit"
+	FileWrite = "write"
+)
+
+// FileActivity records one tool call that touched a file.
+type FileActivity struct {
+	SessionID string    `json:"session_id"`
+	Path      string    `json:"path"`      // relative to the project root when inside it
+	Operation string    `json:"operation"` // FileRead, FileEdit or FileWrite
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// fileTools maps file tools to their operation and the input field
+// holding the path.
+var fileTools = map[string]struct{ op, field string }{
+	"Read":         {FileRead, "file_path"},
+	"Edit":         {FileEdit, "file_path"},
+	"MultiEdit":    {FileEdit, "file_path"},
+	"NotebookEdit": {FileEdit, "notebook_path"},
+	"Write":        {FileWrite, "file_path"},
+}
+
+// ParseFileActivity extracts the file touched by a successful PostToolUse
+// event, with its path normalised against root. It reports false for any
+// other event.
+func ParseFileActivity(e Event, root string) (FileActivity, bool) {
+	if e.EventType != "PostToolUse" || e.ToolName == nil {
+		return FileActivity{}, false
+	}
+	tool, ok := fileTools[*e.ToolName]
+	if !ok {
+		return FileActivity{}, false
+	}
+
+	var input map[string]json.RawMessage
+	if err := json.Unmarshal(e.ToolInput, &input); err != nil {
+		return FileActivity{}, false
+	}
+	var path string
+	if err := json.Unmarshal(input[tool.field], &path); err != nil || path == "" {
+		return FileActivity{}, false
+	}
+
+	return FileActivity{
+		SessionID: e.SessionID,
+		Path:      NormalizePath(path, root),
+		Operation: tool.op,
+		Timestamp: e.Timestamp,
+	}, true
+}
+
+// NormalizePath cleans path and makes it relative to root when it lies
+// inside it; paths outside root stay absolute. Relative paths are taken
+// to be relative to root already. The root itself becomes ".".
+func NormalizePath(path, root string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	path = filepath.Clean(path)
+	if root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(filepath.Clean(root), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return path
+	}
+	return rel
+}
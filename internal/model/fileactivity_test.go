@@ -0,0 +1,69 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func toolEvent(eventType, tool, input string) Event {
+	return Event{SessionID: "s1", EventType: eventType, ToolName: &tool, ToolInput: json.RawMessage(input)}
+}
+
+func TestParseFileActivity_WhenGivenEdit_ShouldReturnRelativePath(t *testing.T) {
+	got, ok := ParseFileActivity(toolEvent("PostToolUse", "Edit", `{"file_path":"/proj/internal/a.go"}`), "/proj")
+	if !ok {
+		t.Fatal("expected file activity")
+	}
+	if got.Path != "internal/a.go" || got.Operation != FileEdit || got.SessionID != "s1" {
+		t.Errorf("unexpected activity %+v", got)
+	}
+}
+
+func TestParseFileActivity_WhenGivenNotebookEdit_ShouldUseNotebookPath(t *testing.T) {
+	got, ok := ParseFileActivity(toolEvent("PostToolUse", "NotebookEdit", `{"notebook_path":"/proj/n.ipynb"}`), "/proj")
+	if !ok || got.Path != "n.ipynb" {
+		t.Errorf("expected n.ipynb, got %+v (ok=%v)", got, ok)
+	}
+}
+
+func TestParseFileActivity_WhenToolDoesNotTouchFiles_ShouldReturnFalse(t *testing.T) {
+	if _, ok := ParseFileActivity(toolEvent("PostToolUse", "Bash", `{"command":"ls"}`), "/proj"); ok {
+		t.Error("expected no activity for Bash")
+	}
+}
+
+func TestParseFileActivity_WhenToolFailed_ShouldReturnFalse(t *testing.T) {
+	if _, ok := ParseFileActivity(toolEvent("PostToolUseFailure", "Write", `{"file_path":"/proj/a"}`), "/proj"); ok {
+		t.Error("expected no activity for a failed tool call")
+	}
+}
+
+func TestParseFileActivity_WhenPathMissing_ShouldReturnFalse(t *testing.T) {
+	if _, ok := ParseFileActivity(toolEvent("PostToolUse", "Read", `{"offset":1}`), "/proj"); ok {
+		t.Error("expected no activity without a file_path")
+	}
+}
+
+func TestNormalizePath_WhenOutsideRoot_ShouldStayAbsolute(t *testing.T) {
+	if got := NormalizePath("/etc/hosts", "/proj"); got != "/etc/hosts" {
+		t.Errorf("expected /etc/hosts, got %q", got)
+	}
+}
+
+func TestNormalizePath_WhenSiblingSharesPrefix_ShouldStayAbsolute(t *testing.T) {
+	if got := NormalizePath("/project2/a.go", "/proj"); got != "/project2/a.go" {
+		t.Errorf("expected /project2/a.go, got %q", got)
+	}
+}
+
+func TestNormalizePath_WhenGivenRoot_ShouldReturnDot(t *testing.T) {
+	if got := NormalizePath("/proj/", "/proj"); got != "." {
+		t.Errorf("expected '.', got %q", got)
+	}
+}
+
+func TestNormalizePath_WhenRelative_ShouldClean(t *testing.T) {
+	if got := NormalizePath("./a/../b.go", "/proj"); got != "b.go" {
+		t.Errorf("expected b.go, got %q", got)
+	}
+}
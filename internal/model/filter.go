@@ -0,0 +1,24 @@
+package model
+
+import "fmt"
+
+// Filter narrows searches beyond their time range. Empty fields match
+// everything.
+type Filter struct {
+	Role      string // "user" or "assistant"
+	SessionID string // session id or prefix
+	Model     string // case-insensitive substring of the model name
+	Agent     string // subagent id or type (e.g. "Explore")
+}
+
+// NewFilter validates the given fields into a Filter. It returns nil if
+// all are empty.
+func NewFilter(role, sessionID, modelName, agent string) (*Filter, error) {
+	if role == "" && sessionID == "" && modelName == "" && agent == "" {
+		return nil, nil
+	}
+	if role != "" && role != "user" && role != "assistant" {
+		return nil, fmt.Errorf("invalid role %q (want user or assistant)", role)
+	}
+	return &Filter{Role: role, SessionID: sessionID, Model: modelName, Agent: agent}, nil
+}
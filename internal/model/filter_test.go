@@ -0,0 +1,26 @@
+package model
+
+import "testing"
+
+func TestNewFilter_WhenAllEmpty_ShouldReturnNil(t *testing.T) {
+	f, err := NewFilter("", "", "", "")
+	if err != nil || f != nil {
+		t.Errorf("expected nil filter, got %+v (err=%v)", f, err)
+	}
+}
+
+func TestNewFilter_WhenGivenFields_ShouldSetThem(t *testing.T) {
+	f, err := NewFilter("user", "abc", "opus", "Explore")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Role != "user" || f.SessionID != "abc" || f.Model != "opus" || f.Agent != "Explore" {
+		t.Errorf("unexpected filter %+v", f)
+	}
+}
+
+func TestNewFilter_WhenRoleUnknown_ShouldReturnError(t *testing.T) {
+	if _, err := NewFilter("system", "", "", ""); err == nil {
+		t.Error("expected error for unknown role")
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import "encoding/json"
+
+// MarshalJSON embeds the tool input and response as JSON values rather
+// than strings holding JSON.
+func (r ToolResult) MarshalJSON() ([]byte, error) {
+	type plain ToolResult
+	return json.Marshal(struct {
+		plain
+		ToolInput    json.RawMessage `json:"tool_input"`
+		ToolResponse json.RawMessage `json:"tool_response"`
+	}{plain(r), RawJSON(r.ToolInput), RawJSON(r.ToolResponse)})
+}
+
+// MarshalJSON embeds the tool input and response as JSON values rather
+// than strings holding JSON.
+func (e TimelineEntry) MarshalJSON() ([]byte, error) {
+	type plain TimelineEntry
+	return json.Marshal(struct {
+		plain
+		ToolInput    json.RawMessage `json:"tool_input,omitempty"`
+		ToolResponse json.RawMessage `json:"tool_response,omitempty"`
+	}{plain(e), RawJSON(e.ToolInput), RawJSON(e.ToolResponse)})
+}
+
+// RawJSON returns s as a JSON value: itself if it is valid JSON, null if
+// empty, and a JSON string otherwise.
+func RawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	quoted, _ := json.Marshal(s)
+	return quoted
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRawJSON_ShouldEmbedValidJSONAndQuoteTheRest(t *testing.T) {
+	cases := map[string]string{
+		"":                 `null`,
+		`{"command":"ls"}`: `{"command":"ls"}`,
+		"not json":         `"not json"`,
+	}
+	for in, want := range cases {
+		got, err := json.Marshal(RawJSON(in))
+		if err != nil {
+			t.Fatalf("marshal %q: %v", in, err)
+		}
+		if string(got) != want {
+			t.Errorf("RawJSON(%q): expected %s, got %s", in, want, got)
+		}
+	}
+}
+
+func TestTimelineEntry_MarshalJSON_WhenMessage_ShouldOmitToolFields(t *testing.T) {
+	got, err := json.Marshal(TimelineEntry{Kind: "message", Role: "user", Content: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"message","role":"user","content":"hi","timestamp":"0001-01-01T00:00:00Z"}`
+	if string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
@@ -24,7 +24,7 @@ type Event struct {
 
 	// SessionStart
 	Source    *string
-	Model    *string
+	Model     *string
 	AgentType *string
 
 	// UserPromptSubmit
@@ -66,6 +66,7 @@ type Message struct {
 	Content    string
 	RawContent string
 	Model      string
+	AgentID    string // set for subagent (sidechain) messages
 	Timestamp  time.Time
 }
 
@@ -80,30 +81,143 @@ type StoredMessage struct {
 
 // SearchResult pairs a message with a similarity score.
 type SearchResult struct {
-	ID        int64
-	SessionID string
-	Role      string
-	Content   string
-	Score     float64
-	Timestamp time.Time
+	ID        int64     `json:"id"`
+	SessionID string    `json:"session_id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Score     float64   `json:"score"`
+	Timestamp time.Time `json:"timestamp"`
+	// MatchStart and MatchEnd are the byte range of Content that matched,
+	// e.g. the best-scoring chunk. Both are zero when the whole message did.
+	MatchStart int `json:"match_start"`
+	MatchEnd   int `json:"match_end"`
+	// Collapsed counts further hits from the same session folded into
+	// this one when results are grouped by session.
+	Collapsed int `json:"collapsed"`
+}
+
+// MessageChunk locates an embedded window of a message's content by byte
+// offsets.
+type MessageChunk struct {
+	MessageID int64
+	Index     int
+	Start     int
+	End       int
+}
+
+// ContextMessage is a message from the conversation thread around a search hit.
+type ContextMessage struct {
+	ID        int64     `json:"id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	Hit       bool      `json:"hit"`
+	// HitID is the search hit whose thread this message belongs to, so
+	// the threads of several hits can share one list.
+	HitID int64 `json:"hit_id"`
+}
+
+// TimelineEntry is a message or tool call in a session's chronological view.
+type TimelineEntry struct {
+	Kind         string    `json:"type"`           // "message" or "tool"
+	Role         string    `json:"role,omitempty"` // message role; empty for tool calls
+	Content      string    `json:"content,omitempty"`
+	ToolName     string    `json:"tool_name,omitempty"`
+	ToolInput    string    `json:"-"` // raw JSON
+	ToolResponse string    `json:"-"` // raw JSON
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// SessionStats summarises a session for listing.
+type SessionStats struct {
+	SessionID    string    `json:"session_id"`
+	CWD          string    `json:"cwd"`
+	StartedAt    time.Time `json:"started_at"`
+	EndedAt      time.Time `json:"ended_at"`
+	MessageCount int       `json:"message_count"`
+	ToolCount    int       `json:"tool_count"`
+	Models       []string  `json:"models"`
+	EndReason    string    `json:"end_reason"` // from SessionEnd; empty while the session is open
+	FirstPrompt  string    `json:"first_prompt"`
+}
+
+// Duration returns the time between the first and last recorded activity.
+func (s SessionStats) Duration() time.Duration {
+	return s.EndedAt.Sub(s.StartedAt)
+}
+
+// UsageStats aggregates a project's activity over a time range.
+type UsageStats struct {
+	Sessions             int           `json:"sessions"`
+	Prompts              int           `json:"prompts"`
+	AvgPromptsPerSession float64       `json:"avg_prompts_per_session"`
+	AvgSessionLength     time.Duration `json:"avg_session_length_ns"`
+	ToolCalls            int           `json:"tool_calls"`
+	ToolErrors           int           `json:"tool_errors"`
+	SessionsPerDay       []DayCount    `json:"sessions_per_day"`
+	Tools                []ToolUsage   `json:"tools"`
+	Hours                []HourCount   `json:"hours"`
+}
+
+// DayCount is the number of sessions started on a UTC day (YYYY-MM-DD).
+type DayCount struct {
+	Day      string `json:"day"`
+	Sessions int    `json:"sessions"`
+}
+
+// ToolUsage counts calls and failures for one tool.
+type ToolUsage struct {
+	Name   string `json:"name"`
+	Calls  int    `json:"calls"`
+	Errors int    `json:"errors"`
+}
+
+// ErrorRate returns the fraction of calls that failed or were interrupted.
+func (t ToolUsage) ErrorRate() float64 {
+	if t.Calls == 0 {
+		return 0
+	}
+	return float64(t.Errors) / float64(t.Calls)
+}
+
+// HourCount is the number of events in a UTC hour of the day (0-23).
+type HourCount struct {
+	Hour   int `json:"hour"`
+	Events int `json:"events"`
+}
+
+// QueryResult holds the rows of an ad-hoc SQL query. Values are nil,
+// bool, int64, float64, string or time.Time.
+type QueryResult struct {
+	Columns []string
+	Rows    [][]interface{}
 }
 
 // ToolResult represents a tool call event from the events table.
 type ToolResult struct {
-	SessionID    string
-	ToolName     string
-	ToolInput    string // raw JSON
-	ToolResponse string // raw JSON
-	Timestamp    time.Time
+	SessionID    string    `json:"session_id"`
+	ToolName     string    `json:"tool_name"`
+	ToolInput    string    `json:"-"` // raw JSON
+	ToolResponse string    `json:"-"` // raw JSON
+	Timestamp    time.Time `json:"timestamp"`
 }
 
 // SummaryResult represents a session summary joined with session metadata.
 type SummaryResult struct {
-	SessionID   string
-	Summary     string
-	Model       string
-	GeneratedAt time.Time
-	CWD         string
+	SessionID   string    `json:"session_id"`
+	Summary     string    `json:"summary"`
+	Model       string    `json:"model"`
+	GeneratedAt time.Time `json:"generated_at"`
+	CWD         string    `json:"cwd"`
+}
+
+// SessionMatch is a session ranked by the similarity of its summary to a query.
+type SessionMatch struct {
+	SessionID    string    `json:"session_id"`
+	Summary      string    `json:"summary"`
+	Score        float64   `json:"score"`
+	StartedAt    time.Time `json:"started_at"`
+	MessageCount int       `json:"message_count"`
 }
 
 // HarvestResult holds parsed messages and the new file read offset.
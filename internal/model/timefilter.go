@@ -41,6 +41,12 @@ func ParseTimeFilter(sinceStr, untilStr string) (*TimeFilter, error) {
 	return tf, nil
 }
 
+// ParseTime parses a relative duration (e.g. "2h", meaning 2 hours ago) or
+// an absolute timestamp, as accepted by --since and --until.
+func ParseTime(s string) (time.Time, error) {
+	return parseTimeArg(s)
+}
+
 // parseTimeArg tries to parse a time argument as a relative duration (e.g. "2h", "1d"),
 // then falls back to absolute timestamp formats.
 func parseTimeArg(s string) (time.Time, error) {
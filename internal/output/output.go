@@ -0,0 +1,162 @@
+// Package output renders query results for scripts and tools: JSON,
+// NDJSON, Markdown, TSV and CSV. The human-readable table format is
+// printed by each command itself.
+//
+// JSON output is an envelope,
+//
+//	{"version": 1, "kind": "message", "count": 2, "results": [...]}
+//
+// and NDJSON is one result per line with "version" and "kind" added to
+// each. Version changes only when a field is removed or changes meaning;
+// new fields may appear at any time. Timestamps are RFC 3339 and ids are
+// never shortened.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Version is the schema version of JSON and NDJSON output.
+const Version = 1
+
+// Format selects how results are written.
+type Format string
+
+const (
+	Table    Format = "table"
+	JSON     Format = "json"
+	NDJSON   Format = "ndjson"
+	Markdown Format = "markdown"
+	TSV      Format = "tsv"
+	CSV      Format = "csv"
+)
+
+// ParseFormat validates a --format value.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Table, JSON, NDJSON, Markdown, TSV, CSV:
+		return f, nil
+	case "md":
+		return Markdown, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want table, json, ndjson, markdown, tsv or csv)", s)
+}
+
+// Set is a list of results of one kind.
+type Set struct {
+	// Kind names the record type, e.g. "message" or "tool_call".
+	Kind string
+	// Columns head the Markdown, TSV and CSV output.
+	Columns []string
+	// Records are marshalled as-is for JSON and NDJSON. They must not have
+	// "version" or "kind" keys of their own.
+	Records []interface{}
+	// Row returns the cells of record i, one per column.
+	Row func(i int) []string
+}
+
+// Write renders s in format f, which must not be Table.
+func Write(w io.Writer, f Format, s Set) error {
+	switch f {
+	case JSON:
+		return writeJSON(w, s)
+	case NDJSON:
+		return writeNDJSON(w, s)
+	case Markdown:
+		return writeMarkdown(w, s)
+	case TSV:
+		return writeTSV(w, s)
+	case CSV:
+		return writeCSV(w, s)
+	}
+	return fmt.Errorf("output: cannot write format %q", f)
+}
+
+func writeJSON(w io.Writer, s Set) error {
+	records := s.Records
+	if records == nil {
+		records = []interface{}{}
+	}
+	out, err := json.MarshalIndent(struct {
+		Version int           `json:"version"`
+		Kind    string        `json:"kind"`
+		Count   int           `json:"count"`
+		Results []interface{} `json:"results"`
+	}{Version, s.Kind, len(records), records}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s results: %w", s.Kind, err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+func writeNDJSON(w io.Writer, s Set) error {
+	header := fmt.Sprintf(`{"version":%d,"kind":%q`, Version, s.Kind)
+	for _, r := range s.Records {
+		obj, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("encode %s result: %w", s.Kind, err)
+		}
+		if len(obj) < 2 || obj[0] != '{' {
+			return fmt.Errorf("encode %s result: not a JSON object", s.Kind)
+		}
+		line := header + "}"
+		if body := bytes.TrimSpace(obj[1:]); len(body) > 1 {
+			line = header + "," + string(body)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownCell keeps a value on one table row.
+var markdownCell = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func writeMarkdown(w io.Writer, s Set) error {
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(s.Columns, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat("---|", len(s.Columns)) + "\n")
+	for i := range s.Records {
+		cells := s.Row(i)
+		for j, c := range cells {
+			cells[j] = markdownCell.Replace(c)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tsvCell escapes the separators as in PostgreSQL's text format.
+var tsvCell = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func writeTSV(w io.Writer, s Set) error {
+	var b strings.Builder
+	b.WriteString(strings.Join(s.Columns, "\t") + "\n")
+	for i := range s.Records {
+		cells := s.Row(i)
+		for j, c := range cells {
+			cells[j] = tsvCell.Replace(c)
+		}
+		b.WriteString(strings.Join(cells, "\t") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeCSV(w io.Writer, s Set) error {
+	cw := csv.NewWriter(w)
+	cw.Write(s.Columns)
+	for i := range s.Records {
+		cw.Write(s.Row(i))
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,140 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"clog/internal/model"
+)
+
+var testResults = []model.SearchResult{
+	{ID: 7, SessionID: "0123456789abcdef", Role: "user", Content: "fix | the\ttest\nplease", Score: 0.5,
+		Timestamp: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)},
+}
+
+func TestParseFormat_WhenUnknown_ShouldListFormats(t *testing.T) {
+	if _, err := ParseFormat("yaml"); err == nil || !strings.Contains(err.Error(), "ndjson") {
+		t.Errorf("expected error listing formats, got %v", err)
+	}
+	if f, err := ParseFormat("md"); err != nil || f != Markdown {
+		t.Errorf("expected md to mean markdown, got %q, %v", f, err)
+	}
+}
+
+func TestWrite_WhenJSON_ShouldWrapResultsInVersionedEnvelope(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, JSON, Messages(testResults)); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Version int                      `json:"version"`
+		Kind    string                   `json:"kind"`
+		Count   int                      `json:"count"`
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if got.Version != Version || got.Kind != KindMessage || got.Count != 1 {
+		t.Errorf("unexpected envelope %+v", got)
+	}
+	r := got.Results[0]
+	if r["session_id"] != "0123456789abcdef" || r["timestamp"] != "2026-10-17T10:00:00Z" || r["content"] != testResults[0].Content {
+		t.Errorf("expected the full record, got %v", r)
+	}
+}
+
+func TestWrite_WhenJSONAndNoResults_ShouldWriteEmptyArray(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, JSON, Messages(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"results": []`) {
+		t.Errorf("expected an empty results array, got %s", buf.String())
+	}
+}
+
+func TestWrite_WhenNDJSON_ShouldTagEachLine(t *testing.T) {
+	var buf bytes.Buffer
+	calls := []model.ToolResult{
+		{SessionID: "s1", ToolName: "Bash", ToolInput: `{"command":"ls"}`},
+		{SessionID: "s2", ToolName: "Read"},
+	}
+	if err := Write(&buf, NDJSON, ToolCalls(calls)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("invalid line %q: %v", lines[0], err)
+	}
+	if first["version"] != float64(Version) || first["kind"] != KindToolCall || first["tool_name"] != "Bash" {
+		t.Errorf("unexpected line %v", first)
+	}
+	if input, ok := first["tool_input"].(map[string]interface{}); !ok || input["command"] != "ls" {
+		t.Errorf("expected tool_input as a JSON object, got %v", first["tool_input"])
+	}
+}
+
+func TestWrite_WhenMarkdown_ShouldEscapePipesAndNewlines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Markdown, Messages(testResults)); err != nil {
+		t.Fatal(err)
+	}
+	want := "| id | session_id | timestamp | role | score | content |\n" +
+		"|---|---|---|---|---|---|\n" +
+		"| 7 | 0123456789abcdef | 2026-10-17T10:00:00Z | user | 0.5000 | fix \\| the\ttest<br>please |\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWrite_WhenTSV_ShouldEscapeSeparators(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, TSV, Messages(testResults)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 || lines[2] != "" {
+		t.Fatalf("expected a header and one row, got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[1], "\tfix | the\\ttest\\nplease") {
+		t.Errorf("expected escaped content, got %q", lines[1])
+	}
+}
+
+func TestWrite_WhenCSVRows_ShouldEscapeAndBlankNulls(t *testing.T) {
+	var buf bytes.Buffer
+	result := &model.QueryResult{
+		Columns: []string{"tool", "n", "note"},
+		Rows: [][]interface{}{
+			{"Bash", int64(3), nil},
+			{"Edit", int64(1), "a, \"quoted\" note"},
+		},
+	}
+	if err := Write(&buf, CSV, Rows(result)); err != nil {
+		t.Fatal(err)
+	}
+	want := "tool,n,note\nBash,3,\nEdit,1,\"a, \"\"quoted\"\" note\"\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWrite_WhenNDJSONRows_ShouldKeepColumnOrder(t *testing.T) {
+	var buf bytes.Buffer
+	result := &model.QueryResult{Columns: []string{"z", "a"}, Rows: [][]interface{}{{int64(1), "x"}}}
+	if err := Write(&buf, NDJSON, Rows(result)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"version":1,"kind":"row","z":1,"a":"x"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
@@ -0,0 +1,250 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"clog/internal/model"
+)
+
+// Record kinds.
+const (
+	KindMessage       = "message"
+	KindContext       = "context_message"
+	KindToolCall      = "tool_call"
+	KindSummary       = "summary"
+	KindSessionMatch  = "session_match"
+	KindSession       = "session"
+	KindTimelineEntry = "timeline_entry"
+	KindFileActivity  = "file_activity"
+	KindBashExecution = "bash_execution"
+	KindUsageStats    = "usage_stats"
+	KindRow           = "row"
+)
+
+// Messages wraps message search hits.
+func Messages(rs []model.SearchResult) Set {
+	return Set{
+		Kind:    KindMessage,
+		Columns: []string{"id", "session_id", "timestamp", "role", "score", "content"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{strconv.FormatInt(r.ID, 10), r.SessionID, timestamp(r.Timestamp), r.Role, score(r.Score), r.Content}
+		},
+	}
+}
+
+// Context wraps the threads around search hits.
+func Context(rs []model.ContextMessage) Set {
+	return Set{
+		Kind:    KindContext,
+		Columns: []string{"hit_id", "id", "timestamp", "role", "hit", "content"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{strconv.FormatInt(r.HitID, 10), strconv.FormatInt(r.ID, 10), timestamp(r.Timestamp),
+				r.Role, strconv.FormatBool(r.Hit), r.Content}
+		},
+	}
+}
+
+// ToolCalls wraps tool call events.
+func ToolCalls(rs []model.ToolResult) Set {
+	return Set{
+		Kind:    KindToolCall,
+		Columns: []string{"session_id", "timestamp", "tool_name", "tool_input", "tool_response"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{r.SessionID, timestamp(r.Timestamp), r.ToolName, r.ToolInput, r.ToolResponse}
+		},
+	}
+}
+
+// Summaries wraps session summaries.
+func Summaries(rs []model.SummaryResult) Set {
+	return Set{
+		Kind:    KindSummary,
+		Columns: []string{"session_id", "generated_at", "model", "cwd", "summary"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{r.SessionID, timestamp(r.GeneratedAt), r.Model, r.CWD, r.Summary}
+		},
+	}
+}
+
+// SessionMatches wraps sessions ranked by summary similarity.
+func SessionMatches(rs []model.SessionMatch) Set {
+	return Set{
+		Kind:    KindSessionMatch,
+		Columns: []string{"session_id", "score", "started_at", "message_count", "summary"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{r.SessionID, score(r.Score), timestamp(r.StartedAt), strconv.Itoa(r.MessageCount), r.Summary}
+		},
+	}
+}
+
+// Sessions wraps session listings.
+func Sessions(rs []model.SessionStats) Set {
+	return Set{
+		Kind: KindSession,
+		Columns: []string{"session_id", "started_at", "ended_at", "message_count", "tool_count",
+			"models", "end_reason", "cwd", "first_prompt"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{r.SessionID, timestamp(r.StartedAt), timestamp(r.EndedAt),
+				strconv.Itoa(r.MessageCount), strconv.Itoa(r.ToolCount), strings.Join(r.Models, ","),
+				r.EndReason, r.CWD, r.FirstPrompt}
+		},
+	}
+}
+
+// Timeline wraps a session's messages and tool calls.
+func Timeline(rs []model.TimelineEntry) Set {
+	return Set{
+		Kind:    KindTimelineEntry,
+		Columns: []string{"timestamp", "type", "role", "tool_name", "content", "tool_input", "tool_response"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{timestamp(r.Timestamp), r.Kind, r.Role, r.ToolName, r.Content, r.ToolInput, r.ToolResponse}
+		},
+	}
+}
+
+// FileActivity wraps file history.
+func FileActivity(rs []model.FileActivity) Set {
+	return Set{
+		Kind:    KindFileActivity,
+		Columns: []string{"session_id", "timestamp", "operation", "path"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			return []string{r.SessionID, timestamp(r.Timestamp), r.Operation, r.Path}
+		},
+	}
+}
+
+// BashExecutions wraps Bash history.
+func BashExecutions(rs []model.BashExecution) Set {
+	return Set{
+		Kind: KindBashExecution,
+		Columns: []string{"session_id", "timestamp", "started_at", "exit_code", "interrupted",
+			"command", "stdout", "stderr", "error"},
+		Records: records(len(rs), func(i int) interface{} { return rs[i] }),
+		Row: func(i int) []string {
+			r := rs[i]
+			started, exit := "", ""
+			if r.StartedAt != nil {
+				started = timestamp(*r.StartedAt)
+			}
+			if r.ExitCode != nil {
+				exit = strconv.Itoa(*r.ExitCode)
+			}
+			return []string{r.SessionID, timestamp(r.Timestamp), started, exit, strconv.FormatBool(r.Interrupted),
+				r.Command, r.Stdout, r.Stderr, r.Error}
+		},
+	}
+}
+
+// UsageStats wraps a usage report as a single record. Markdown, TSV and
+// CSV show only its totals.
+func UsageStats(s *model.UsageStats) Set {
+	return Set{
+		Kind: KindUsageStats,
+		Columns: []string{"sessions", "prompts", "avg_prompts_per_session", "avg_session_length_ns",
+			"tool_calls", "tool_errors"},
+		Records: []interface{}{s},
+		Row: func(int) []string {
+			return []string{strconv.Itoa(s.Sessions), strconv.Itoa(s.Prompts),
+				strconv.FormatFloat(s.AvgPromptsPerSession, 'f', 1, 64),
+				strconv.FormatInt(int64(s.AvgSessionLength), 10),
+				strconv.Itoa(s.ToolCalls), strconv.Itoa(s.ToolErrors)}
+		},
+	}
+}
+
+// Rows wraps the result of an ad-hoc SQL query. Each record is an object
+// keyed by column, in column order.
+func Rows(result *model.QueryResult) Set {
+	return Set{
+		Kind:    KindRow,
+		Columns: result.Columns,
+		Records: records(len(result.Rows), func(i int) interface{} {
+			return orderedRow{result.Columns, result.Rows[i]}
+		}),
+		Row: func(i int) []string {
+			cells := make([]string, len(result.Rows[i]))
+			for j, v := range result.Rows[i] {
+				cells[j] = Cell(v)
+			}
+			return cells
+		},
+	}
+}
+
+// Cell renders one query value as text; NULL becomes empty.
+func Cell(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return timestamp(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func records(n int, at func(i int) interface{}) []interface{} {
+	out := make([]interface{}, n)
+	for i := range out {
+		out[i] = at(i)
+	}
+	return out
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func score(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+// orderedRow marshals a query row as an object whose keys keep the
+// query's column order.
+type orderedRow struct {
+	columns []string
+	values  []interface{}
+}
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, v := range r.values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, _ := json.Marshal(r.columns[i])
+		val, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("encode column %s: %w", r.columns[i], err)
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(val)
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,149 @@
+// Package rerank post-processes search results, diversifying them by
+// maximal marginal relevance (MMR). Grouping by session happens in the
+// store's query, before the limit.
+package rerank
+
+import (
+	"strings"
+	"unicode"
+
+	"clog/internal/model"
+)
+
+// candidateFactor is how many candidates per wanted result are fetched
+// when reranking, so that reordering still fills the limit.
+const candidateFactor = 5
+
+// Options selects the reranking steps.
+type Options struct {
+	// Diversity in [0, 1] trades relevance for novelty: 0 keeps the
+	// original order, 1 ignores relevance after the first pick.
+	Diversity float64
+}
+
+// Enabled reports whether any reranking step is selected.
+func (o Options) Enabled() bool {
+	return o.Diversity > 0
+}
+
+// Candidates returns how many results to fetch to return limit after
+// reranking.
+func (o Options) Candidates(limit int) int {
+	if !o.Enabled() {
+		return limit
+	}
+	return limit * candidateFactor
+}
+
+// Apply diversifies results as selected and returns at most limit of
+// them. Results must arrive in relevance order.
+func Apply(results []model.SearchResult, opts Options, limit int) []model.SearchResult {
+	if opts.Diversity > 0 {
+		results = Diversify(results, opts.Diversity, limit)
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Diversify picks up to limit results by maximal marginal relevance:
+// each pick maximises (1-diversity)·relevance - diversity·redundancy,
+// where redundancy is the highest word overlap with any earlier pick.
+// Relevance is the score, or the rank when results are unscored (text
+// search).
+func Diversify(results []model.SearchResult, diversity float64, limit int) []model.SearchResult {
+	if len(results) < 2 || diversity <= 0 {
+		return results
+	}
+	if diversity > 1 {
+		diversity = 1
+	}
+
+	rel := relevance(results)
+	words := make([]map[string]bool, len(results))
+	for i, r := range results {
+		words[i] = wordSet(matchText(r))
+	}
+
+	picked := make([]bool, len(results))
+	redundancy := make([]float64, len(results))
+	var out []model.SearchResult
+	for len(out) < limit && len(out) < len(results) {
+		best, bestScore := -1, 0.0
+		for i := range results {
+			if picked[i] {
+				continue
+			}
+			score := (1-diversity)*rel[i] - diversity*redundancy[i]
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		picked[best] = true
+		out = append(out, results[best])
+		for i := range results {
+			if !picked[i] {
+				if sim := jaccard(words[i], words[best]); sim > redundancy[i] {
+					redundancy[i] = sim
+				}
+			}
+		}
+	}
+	return out
+}
+
+// relevance returns the results' scores (cosine similarities, on the same
+// scale as word overlap), or descending rank-based values when results
+// are unscored.
+func relevance(results []model.SearchResult) []float64 {
+	scored := false
+	for _, r := range results {
+		if r.Score != 0 {
+			scored = true
+			break
+		}
+	}
+	out := make([]float64, len(results))
+	for i, r := range results {
+		if scored {
+			out[i] = r.Score
+		} else {
+			out[i] = 1 - float64(i)/float64(len(results))
+		}
+	}
+	return out
+}
+
+// matchText returns the part of r that matched, e.g. its best chunk.
+func matchText(r model.SearchResult) string {
+	if r.MatchEnd > r.MatchStart && r.MatchEnd <= len(r.Content) {
+		return r.Content[r.MatchStart:r.MatchEnd]
+	}
+	return r.Content
+}
+
+// wordSet returns the distinct lower-cased words of s.
+func wordSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		set[w] = true
+	}
+	return set
+}
+
+// jaccard returns |a∩b| / |a∪b|.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range a {
+		if b[w] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
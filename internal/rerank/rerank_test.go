@@ -0,0 +1,64 @@
+package rerank
+
+import (
+	"testing"
+
+	"clog/internal/model"
+)
+
+func hit(session, content string, score float64) model.SearchResult {
+	return model.SearchResult{SessionID: session, Content: content, Score: score}
+}
+
+func TestDiversify_ShouldDemoteNearDuplicates(t *testing.T) {
+	results := []model.SearchResult{
+		hit("a", "fix the auth token refresh bug", 0.95),
+		hit("a", "fix the auth token refresh bug again", 0.94),
+		hit("b", "deploy pipeline for staging", 0.80),
+	}
+	got := Diversify(results, 0.5, 3)
+	if got[0].Content != results[0].Content || got[1].Content != results[2].Content {
+		t.Errorf("expected the distinct hit second, got %q then %q", got[0].Content, got[1].Content)
+	}
+}
+
+func TestDiversify_WhenDiversityZero_ShouldKeepOrder(t *testing.T) {
+	results := []model.SearchResult{hit("a", "same words", 0.9), hit("a", "same words", 0.8)}
+	got := Diversify(results, 0, 2)
+	if got[0].Score != 0.9 || got[1].Score != 0.8 {
+		t.Errorf("expected original order, got %+v", got)
+	}
+}
+
+func TestDiversify_WhenUnscored_ShouldUseRankAsRelevance(t *testing.T) {
+	results := []model.SearchResult{
+		hit("a", "alpha beta", 0), hit("a", "alpha beta", 0), hit("b", "gamma delta", 0),
+	}
+	got := Diversify(results, 0.7, 2)
+	if len(got) != 2 || got[0].Content != "alpha beta" || got[1].Content != "gamma delta" {
+		t.Errorf("expected first hit then the distinct one, got %+v", got)
+	}
+}
+
+func TestApply_ShouldDiversifyThenLimit(t *testing.T) {
+	results := []model.SearchResult{hit("a", "x y", 0.9), hit("a", "x y", 0.8), hit("b", "z", 0.7), hit("c", "w", 0.6)}
+	got := Apply(results, Options{Diversity: 0.5}, 2)
+	if len(got) != 2 || got[0].SessionID != "a" || got[1].SessionID != "b" {
+		t.Errorf("expected a's first hit then b's, got %+v", got)
+	}
+}
+
+func TestOptions_Candidates_WhenReranking_ShouldOverFetch(t *testing.T) {
+	if got := (Options{}).Candidates(10); got != 10 {
+		t.Errorf("expected 10 without reranking, got %d", got)
+	}
+	if got := (Options{Diversity: 0.3}).Candidates(10); got != 10*candidateFactor {
+		t.Errorf("expected %d with reranking, got %d", 10*candidateFactor, got)
+	}
+}
+
+func TestJaccard_WhenBothEmpty_ShouldBeZero(t *testing.T) {
+	if got := jaccard(map[string]bool{}, map[string]bool{}); got != 0 {
+		t.Errorf("expected 0, got %v", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Package search parses clog's query syntax, shared by the search modes:
+//
+//	role:user tool:Bash "exact phrase" -vendor after:2w file:store.go
+//
+// A query is a list of terms that must all match. A bare word or quoted
+// phrase matches text, field:value restricts one attribute, and a leading
+// "-" negates a term. The store compiles queries to parameterised SQL.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"clog/internal/model"
+)
+
+// Query fields.
+const (
+	Role    = "role"    // user or assistant
+	Session = "session" // session id prefix
+	Model   = "model"   // model name substring
+	Agent   = "agent"   // subagent id or type
+	Tool    = "tool"    // tool name, case-insensitive
+	File    = "file"    // file path substring
+	After   = "after"   // relative duration or timestamp
+	Before  = "before"  // relative duration or timestamp
+)
+
+// Fields lists the known fields in the order shown in errors.
+var Fields = []string{Role, Session, Model, Agent, Tool, File, After, Before}
+
+// Kind distinguishes the three forms of term.
+type Kind int
+
+const (
+	Word   Kind = iota // bare word
+	Phrase             // "quoted phrase"
+	Field              // field:value
+)
+
+// Term is one condition of a query.
+type Term struct {
+	Kind    Kind
+	Field   string // set for Field terms
+	Value   string
+	Negated bool
+	// Time is the parsed value of after: and before: terms.
+	Time time.Time
+	// Pos is the term's byte offset in the source, for errors.
+	Pos int
+}
+
+// Query is a parsed query: the conjunction of its terms.
+type Query struct {
+	Terms []Term
+}
+
+// Error is a syntax or validation error at a position in the source.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("query: %s (at column %d)", e.Msg, e.Pos+1)
+}
+
+// Parse parses and validates s. A lone "*" matches everything, so "*" and
+// "" both parse to an empty query.
+func Parse(s string) (*Query, error) {
+	q := &Query{}
+	i := 0
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i == len(s) {
+			return q, nil
+		}
+
+		t := Term{Pos: i}
+		if s[i] == '-' {
+			t.Negated = true
+			i++
+			if i == len(s) || isSpace(s[i]) {
+				return nil, &Error{t.Pos, `"-" must be followed by a term`}
+			}
+		}
+
+		if s[i] == '"' {
+			value, next, err := quoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			t.Kind, t.Value, i = Phrase, value, next
+		} else {
+			start := i
+			for i < len(s) && !isSpace(s[i]) && s[i] != '"' {
+				i++
+			}
+			word := s[start:i]
+			if name, ok := fieldName(word); ok {
+				t.Kind, t.Field = Field, name
+				if i < len(s) && s[i] == '"' && len(word) == len(name)+1 {
+					value, next, err := quoted(s, i)
+					if err != nil {
+						return nil, err
+					}
+					t.Value, i = value, next
+				} else {
+					t.Value = word[len(name)+1:]
+				}
+			} else {
+				t.Kind, t.Value = Word, word
+			}
+		}
+
+		if t.Kind == Word && t.Value == "*" && !t.Negated {
+			continue
+		}
+		if err := validate(&t); err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, t)
+	}
+}
+
+// quoted reads the phrase opening at s[i] and returns it with the offset
+// just past its closing quote.
+func quoted(s string, i int) (string, int, error) {
+	end := strings.IndexByte(s[i+1:], '"')
+	if end < 0 {
+		return "", 0, &Error{i, "unterminated quote"}
+	}
+	return s[i+1 : i+1+end], i + end + 2, nil
+}
+
+// fieldName returns the field of a field:value word. Words whose prefix
+// isn't a plain identifier, such as URLs, are left as text.
+func fieldName(word string) (string, bool) {
+	colon := strings.IndexByte(word, ':')
+	if colon <= 0 {
+		return "", false
+	}
+	for _, c := range word[:colon] {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return "", false
+		}
+	}
+	if strings.HasPrefix(word[colon+1:], "//") {
+		return "", false
+	}
+	return strings.ToLower(word[:colon]), true
+}
+
+// validate checks a term's field and value, parsing times.
+func validate(t *Term) error {
+	if t.Kind != Field {
+		if t.Value == "" {
+			return &Error{t.Pos, "empty phrase"}
+		}
+		return nil
+	}
+	if !known(t.Field) {
+		msg := fmt.Sprintf("unknown field %q", t.Field)
+		if s := suggest(t.Field); s != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", s)
+		}
+		return &Error{t.Pos, msg + "; fields are " + strings.Join(Fields, ", ") + ", or quote the term to search for it"}
+	}
+	if t.Value == "" {
+		return &Error{t.Pos, t.Field + ": needs a value"}
+	}
+
+	switch t.Field {
+	case Role:
+		if t.Value != "user" && t.Value != "assistant" {
+			return &Error{t.Pos, fmt.Sprintf("role: must be user or assistant, not %q", t.Value)}
+		}
+	case After, Before:
+		if t.Negated {
+			other := Before
+			if t.Field == Before {
+				other = After
+			}
+			return &Error{t.Pos, fmt.Sprintf("%s: cannot be negated; use %s: instead", t.Field, other)}
+		}
+		ts, err := model.ParseTime(t.Value)
+		if err != nil {
+			return &Error{t.Pos, fmt.Sprintf("%s: %v", t.Field, err)}
+		}
+		t.Time = ts
+	}
+	return nil
+}
+
+func known(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the known field closest to a misspelt one, if any is
+// within two edits.
+func suggest(field string) string {
+	best, bestDist := "", 3
+	for _, f := range Fields {
+		if d := distance(field, f); d < bestDist {
+			best, bestDist = f, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// Empty reports whether q has no terms and so matches everything.
+func (q *Query) Empty() bool {
+	return q == nil || len(q.Terms) == 0
+}
+
+// Text returns the query's bare words, the part semantic search embeds.
+func (q *Query) Text() string {
+	if q == nil {
+		return ""
+	}
+	var words []string
+	for _, t := range q.Terms {
+		if t.Kind == Word && !t.Negated {
+			words = append(words, t.Value)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Highlights returns the words and phrases a match should contain, for
+// marking them in result snippets.
+func (q *Query) Highlights() []string {
+	if q == nil {
+		return nil
+	}
+	var out []string
+	for _, t := range q.Terms {
+		if t.Kind != Field && !t.Negated {
+			out = append(out, t.Value)
+		}
+	}
+	return out
+}
+
+// Filters returns q without its bare words: the part semantic search
+// applies as a pre-filter.
+func (q *Query) Filters() *Query {
+	if q == nil {
+		return nil
+	}
+	out := &Query{}
+	for _, t := range q.Terms {
+		if t.Kind != Word || t.Negated {
+			out.Terms = append(out.Terms, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse_WhenGivenMixedTerms_ShouldBuildTermsInOrder(t *testing.T) {
+	q, err := Parse(`role:user tool:Bash "exact phrase" -vendor after:2w file:store.go`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	want := []Term{
+		{Kind: Field, Field: Role, Value: "user"},
+		{Kind: Field, Field: Tool, Value: "Bash"},
+		{Kind: Phrase, Value: "exact phrase"},
+		{Kind: Word, Value: "vendor", Negated: true},
+		{Kind: Field, Field: After, Value: "2w"},
+		{Kind: Field, Field: File, Value: "store.go"},
+	}
+	if len(q.Terms) != len(want) {
+		t.Fatalf("expected %d terms, got %+v", len(want), q.Terms)
+	}
+	for i, w := range want {
+		got := q.Terms[i]
+		if got.Kind != w.Kind || got.Field != w.Field || got.Value != w.Value || got.Negated != w.Negated {
+			t.Errorf("term %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+}
+
+func TestParse_WhenGivenAfter_ShouldParseTime(t *testing.T) {
+	q, err := Parse("after:2w")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ago := time.Since(q.Terms[0].Time)
+	if ago < 13*24*time.Hour || ago > 15*24*time.Hour {
+		t.Errorf("expected about two weeks ago, got %v", ago)
+	}
+}
+
+func TestParse_WhenFieldValueQuoted_ShouldKeepSpaces(t *testing.T) {
+	q, err := Parse(`file:"my notes.md" -model:"claude haiku"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if q.Terms[0].Value != "my notes.md" {
+		t.Errorf("expected quoted file value, got %q", q.Terms[0].Value)
+	}
+	if !q.Terms[1].Negated || q.Terms[1].Field != Model || q.Terms[1].Value != "claude haiku" {
+		t.Errorf("unexpected second term %+v", q.Terms[1])
+	}
+}
+
+func TestParse_WhenGivenWildcardOrEmpty_ShouldMatchEverything(t *testing.T) {
+	for _, s := range []string{"", "*", "  "} {
+		q, err := Parse(s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		if !q.Empty() {
+			t.Errorf("expected %q to parse to an empty query, got %+v", s, q.Terms)
+		}
+	}
+}
+
+func TestParse_WhenWordLooksLikeURL_ShouldTreatAsText(t *testing.T) {
+	q, err := Parse("https://example.com")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if q.Terms[0].Kind != Word {
+		t.Errorf("expected a word, got %+v", q.Terms[0])
+	}
+}
+
+func TestParse_WhenInvalid_ShouldReturnHelpfulError(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+		col   int
+	}{
+		{`auth "unterminated`, "unterminated quote", 6},
+		{"rol:user", `did you mean "role"?`, 1},
+		{"color:red", `unknown field "color"`, 1},
+		{"role:bot", "must be user or assistant", 1},
+		{"x session:", "session: needs a value", 3},
+		{"after:yesterday", "after:", 1},
+		{"-after:2d", "use before: instead", 1},
+		{"auth -", `"-" must be followed by a term`, 6},
+		{`""`, "empty phrase", 1},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.query)
+		if err == nil {
+			t.Errorf("%q: expected error", c.query)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: expected error containing %q, got %v", c.query, c.want, err)
+		}
+		if e, ok := err.(*Error); !ok || e.Pos+1 != c.col {
+			t.Errorf("%q: expected error at column %d, got %v", c.query, c.col, err)
+		}
+	}
+}
+
+func TestQuery_TextAndFilters_ShouldSplitWordsFromConditions(t *testing.T) {
+	q, err := Parse(`how did we fix auth role:user -vendor "flaky test"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := q.Text(); got != "how did we fix auth" {
+		t.Errorf("expected embedded text of bare words, got %q", got)
+	}
+	filters := q.Filters()
+	if len(filters.Terms) != 3 {
+		t.Fatalf("expected role, negation and phrase as filters, got %+v", filters.Terms)
+	}
+	for _, term := range filters.Terms {
+		if term.Kind == Word && !term.Negated {
+			t.Errorf("bare word %q leaked into filters", term.Value)
+		}
+	}
+}
+
+func TestQuery_Highlights_ShouldSkipFieldsAndNegations(t *testing.T) {
+	q, err := Parse(`fix auth role:user -vendor "flaky test"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := q.Highlights()
+	want := []string{"fix", "auth", "flaky test"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,295 @@
+// Package snippet cuts short excerpts of long messages around what a
+// search matched, optionally highlighting the matched terms.
+package snippet
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI escapes used by Options.Highlight.
+const (
+	highlightOn  = "\x1b[1;33m"
+	highlightOff = "\x1b[0m"
+)
+
+// Options controls an excerpt.
+type Options struct {
+	// Max is the excerpt's length in runes, shared between fragments and
+	// not counting the ellipses that join them.
+	Max int
+	// Fragments is the most places a long message is excerpted from.
+	Fragments int
+	// BestSentence centres the excerpt on the sentence sharing most words
+	// with the terms, rather than on literal matches. Semantic hits rarely
+	// contain the query verbatim.
+	BestSentence bool
+	// Highlight wraps matched terms in ANSI bold yellow.
+	Highlight bool
+}
+
+// span is a byte range of the content.
+type span struct{ start, end int }
+
+// Excerpt returns up to opts.Max runes of content around what matched,
+// with ellipses where it was cut. start and end bound the part of content
+// that matched, e.g. the best chunk of a semantic hit; an empty or invalid
+// range means the whole message. terms are the words and phrases searched
+// for. Whitespace is collapsed so the excerpt fits on one line.
+func Excerpt(content string, start, end int, terms []string, opts Options) string {
+	region := span{0, len(content)}
+	if start < end && start >= 0 && end <= len(content) {
+		region = span{start, end}
+	}
+	if opts.Max <= 0 {
+		opts.Max = region.end - region.start
+	}
+	if opts.Fragments <= 0 {
+		opts.Fragments = 1
+	}
+
+	var windows []span
+	if opts.BestSentence {
+		words := significantWords(terms)
+		if runes(content, region) <= opts.Max {
+			windows = []span{region}
+		} else if s, ok := bestSentence(content, region, words); ok {
+			windows = []span{around(content, s, region, opts.Max)}
+		}
+		terms = words
+	} else if matches := find(content, region, terms); len(matches) > 0 {
+		windows = fragments(content, region, matches, opts)
+	}
+	if windows == nil {
+		windows = []span{{region.start, advance(content, region.start, opts.Max, region.end)}}
+	}
+	return render(content, windows, terms, opts.Highlight)
+}
+
+// find returns the case-insensitive occurrences of terms within region,
+// in order of position.
+func find(content string, region span, terms []string) []span {
+	var out []span
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		for i := region.start; i+len(term) <= region.end; {
+			if strings.EqualFold(content[i:i+len(term)], term) {
+				out = append(out, span{i, i + len(term)})
+				i += len(term)
+				continue
+			}
+			_, size := utf8.DecodeRuneInString(content[i:])
+			i += size
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].start < out[b].start })
+	return out
+}
+
+// fragments picks up to opts.Fragments windows around matches, skipping
+// matches already shown by an earlier window.
+func fragments(content string, region span, matches []span, opts Options) []span {
+	n := min(opts.Fragments, len(matches))
+	var out []span
+	for _, m := range matches {
+		if len(out) > 0 && m.start < out[len(out)-1].end {
+			continue
+		}
+		w := around(content, m, region, opts.Max/n)
+		if len(out) > 0 && w.start < out[len(out)-1].end {
+			w.start = out[len(out)-1].end
+		}
+		out = append(out, w)
+		if len(out) == n {
+			break
+		}
+	}
+	return out
+}
+
+// around widens s to budget runes within region, evenly on both sides.
+func around(content string, s, region span, budget int) span {
+	length := runes(content, s)
+	if length >= budget {
+		return span{s.start, advance(content, s.start, budget, s.end)}
+	}
+	pad := (budget - length) / 2
+	w := span{retreat(content, s.start, pad, region.start), advance(content, s.end, pad, region.end)}
+	// Give what one side couldn't use to the other.
+	if spare := budget - runes(content, w); spare > 0 {
+		w.start = retreat(content, w.start, spare, region.start)
+		w.end = advance(content, w.end, budget-runes(content, w), region.end)
+	}
+	return trimWords(content, w, s, region)
+}
+
+// trimWords moves the window's edges inwards to word boundaries so that
+// fragments don't start or end mid-word, without cutting into the match.
+func trimWords(content string, w, match, region span) span {
+	if w.start > region.start {
+		if i := strings.IndexAny(content[w.start:match.start], " \t\n"); i >= 0 {
+			w.start += i + 1
+		}
+	}
+	if w.end < region.end {
+		if i := strings.LastIndexAny(content[match.end:w.end], " \t\n"); i >= 0 {
+			w.end = match.end + i
+		}
+	}
+	return w
+}
+
+// bestSentence returns the sentence in region containing the most
+// distinct words, if any contains one.
+func bestSentence(content string, region span, words []string) (span, bool) {
+	best, bestScore := span{}, 0
+	for _, s := range sentences(content, region) {
+		lower := strings.ToLower(content[s.start:s.end])
+		score := 0
+		for _, w := range words {
+			if strings.Contains(lower, w) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	return best, bestScore > 0
+}
+
+// sentences splits region after sentence punctuation and at line breaks.
+func sentences(content string, region span) []span {
+	var out []span
+	start := region.start
+	for i := region.start; i < region.end; i++ {
+		c := content[i]
+		end := -1
+		switch {
+		case c == '\n':
+			end = i
+		case (c == '.' || c == '!' || c == '?') && (i+1 == region.end || content[i+1] == ' ' || content[i+1] == '\n'):
+			end = i + 1
+		}
+		if end < 0 {
+			continue
+		}
+		if strings.TrimSpace(content[start:end]) != "" {
+			out = append(out, span{start, end})
+		}
+		start = i + 1
+	}
+	if strings.TrimSpace(content[start:region.end]) != "" {
+		out = append(out, span{start, region.end})
+	}
+	return out
+}
+
+// stopWords are too common to locate a semantic hit by.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "but": true,
+	"not": true, "you": true, "with": true, "this": true, "that": true, "from": true,
+	"how": true, "did": true, "what": true, "why": true, "when": true, "where": true,
+	"have": true, "has": true, "had": true, "does": true, "into": true, "our": true,
+}
+
+// significantWords splits terms into lower-case words worth matching.
+func significantWords(terms []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range terms {
+		for _, w := range strings.FieldsFunc(strings.ToLower(t), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+		}) {
+			if utf8.RuneCountInString(w) < 3 || stopWords[w] || seen[w] {
+				continue
+			}
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// render joins the windows with ellipses, collapsing whitespace and
+// highlighting terms.
+func render(content string, windows []span, terms []string, highlight bool) string {
+	var b strings.Builder
+	if windows[0].start > 0 {
+		b.WriteString("...")
+	}
+	for i, w := range windows {
+		if i > 0 {
+			b.WriteString(" ... ")
+		}
+		text := strings.Join(strings.Fields(content[w.start:w.end]), " ")
+		if highlight {
+			text = Highlight(text, terms)
+		}
+		b.WriteString(text)
+	}
+	if windows[len(windows)-1].end < len(content) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// Highlight wraps case-insensitive occurrences of terms in s with ANSI
+// bold yellow.
+func Highlight(s string, terms []string) string {
+	matches := find(s, span{0, len(s)}, terms)
+	if len(matches) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m.start < last {
+			continue // overlaps a longer or earlier term
+		}
+		b.WriteString(s[last:m.start])
+		b.WriteString(highlightOn + s[m.start:m.end] + highlightOff)
+		last = m.end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Truncate cuts s to max runes, adding "..." if anything was cut.
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	end := advance(s, 0, max, len(s))
+	if end == len(s) {
+		return s
+	}
+	return s[:end] + "..."
+}
+
+// runes counts the runes in content[s.start:s.end].
+func runes(content string, s span) int {
+	return utf8.RuneCountInString(content[s.start:s.end])
+}
+
+// advance moves forward n runes from i, stopping at limit.
+func advance(content string, i, n, limit int) int {
+	for ; n > 0 && i < limit; n-- {
+		_, size := utf8.DecodeRuneInString(content[i:])
+		i += size
+	}
+	return min(i, limit)
+}
+
+// retreat moves back n runes from i, stopping at limit.
+func retreat(content string, i, n, limit int) int {
+	for ; n > 0 && i > limit; n-- {
+		_, size := utf8.DecodeLastRuneInString(content[:i])
+		i -= size
+	}
+	return max(i, limit)
+}
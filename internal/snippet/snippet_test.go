@@ -0,0 +1,69 @@
+package snippet
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExcerpt_WhenMatchFarIntoMessage_ShouldCentreOnIt(t *testing.T) {
+	content := strings.Repeat("filler text ", 500) + "the deadlock in the scheduler " + strings.Repeat("more text ", 100)
+	got := Excerpt(content, 0, 0, []string{"deadlock"}, Options{Max: 60})
+	if !strings.Contains(got, "deadlock") {
+		t.Fatalf("expected excerpt around the match, got %q", got)
+	}
+	if !strings.HasPrefix(got, "...") || !strings.HasSuffix(got, "...") {
+		t.Errorf("expected ellipses on both sides, got %q", got)
+	}
+	if n := utf8.RuneCountInString(strings.Trim(got, ".")); n > 60 {
+		t.Errorf("expected at most 60 runes, got %d", n)
+	}
+}
+
+func TestExcerpt_WhenContentMultiByte_ShouldNotSplitRunes(t *testing.T) {
+	content := strings.Repeat("日本語のテキスト", 50)
+	for _, opts := range []Options{{Max: 7}, {Max: 33}} {
+		got := Excerpt(content, 0, 0, []string{"テキ"}, opts)
+		if !utf8.ValidString(got) {
+			t.Errorf("Max %d: expected valid UTF-8, got %q", opts.Max, got)
+		}
+	}
+	if got := Truncate("héllo wörld", 2); got != "hé..." {
+		t.Errorf("expected 'hé...', got %q", got)
+	}
+}
+
+func TestExcerpt_WhenTermsFarApart_ShouldJoinFragments(t *testing.T) {
+	content := "alpha " + strings.Repeat("x ", 300) + "beta " + strings.Repeat("y ", 300)
+	got := Excerpt(content, 0, 0, []string{"alpha", "beta"}, Options{Max: 40, Fragments: 2})
+	if !strings.Contains(got, "alpha") || !strings.Contains(got, "beta") {
+		t.Fatalf("expected both terms, got %q", got)
+	}
+	if !strings.Contains(got, " ... ") {
+		t.Errorf("expected fragments joined by an ellipsis, got %q", got)
+	}
+}
+
+func TestExcerpt_WhenHighlighting_ShouldWrapTermsIgnoringCase(t *testing.T) {
+	got := Excerpt("Fix the Auth bug", 0, 0, []string{"auth"}, Options{Max: 100, Highlight: true})
+	if got != "Fix the \x1b[1;33mAuth\x1b[0m bug" {
+		t.Errorf("expected highlighted term, got %q", got)
+	}
+}
+
+func TestExcerpt_WhenSemantic_ShouldCentreOnBestSentence(t *testing.T) {
+	content := strings.Repeat("Unrelated chatter about lunch. ", 40) +
+		"We fixed the login timeout by raising the pool size. " +
+		strings.Repeat("More unrelated chatter. ", 40)
+	got := Excerpt(content, 0, 0, []string{"how did we fix the login timeout"}, Options{Max: 80, BestSentence: true})
+	if !strings.Contains(got, "We fixed the login timeout by raising the pool size.") {
+		t.Errorf("expected the best sentence, got %q", got)
+	}
+}
+
+func TestExcerpt_WhenNothingMatches_ShouldShowStartOfRegion(t *testing.T) {
+	got := Excerpt("intro. the matching part. outro", 7, 25, []string{"absent"}, Options{Max: 100})
+	if got != "...the matching part...." {
+		t.Errorf("expected the region with ellipses, got %q", got)
+	}
+}
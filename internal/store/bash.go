@@ -0,0 +1,166 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"clog/internal/model"
+)
+
+// InsertBashExecution records a Bash call parsed from the PostToolUse or
+// PostToolUseFailure event stored last for its session and time; the
+// command and output stay in that event. The start time is filled from
+// the matching PreToolUse event when the caller doesn't know it.
+func (s *sqlStore) InsertBashExecution(b model.BashExecution) error {
+	return insertBashExecution(s.db, s.args, b)
+}
+
+// execer is the part of *sql.DB and *sql.Tx that insertBashExecution needs.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertBashExecution(db execer, bind func([]interface{}) []interface{}, b model.BashExecution) error {
+	var startedAt interface{}
+	if b.StartedAt != nil {
+		startedAt = *b.StartedAt
+	}
+	res, err := db.Exec(`
+		INSERT INTO bash_executions (event_id, exit_code, interrupted, started_at)
+		SELECT e.id, ?, ?,
+		       COALESCE(?, (SELECT MIN(p.timestamp) FROM events p
+		                    WHERE p.event_type = 'PreToolUse' AND p.tool_use_id = e.tool_use_id))
+		FROM events e
+		WHERE e.id = (SELECT MAX(id) FROM events
+		              WHERE session_id = ? AND timestamp = ? AND tool_name = 'Bash'
+		                AND event_type IN ('PostToolUse', 'PostToolUseFailure'))`,
+		bind([]interface{}{b.ExitCode, b.Interrupted, startedAt, b.SessionID, b.Timestamp})...,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	return fmt.Errorf("no Bash event recorded for session %s at %s", b.SessionID, b.Timestamp)
+}
+
+// backfillBashExecutions parses the Bash events stored before
+// bash_executions existed, and drops bash_history, which copied their
+// command and output.
+func (s *sqlStore) backfillBashExecutions(tx *sql.Tx) error {
+	// Only the fields ParseBashExecution derives from are read; the
+	// output can be large.
+	rows, err := tx.Query(`
+		SELECT e.session_id, e.event_type, e.timestamp, e.error, e.is_interrupt,
+		       e.tool_input->>'$.command', e.tool_response->>'$.interrupted'
+		FROM events e
+		WHERE e.tool_name = 'Bash' AND e.event_type IN ('PostToolUse', 'PostToolUseFailure')
+		  AND NOT EXISTS (SELECT 1 FROM bash_executions b WHERE b.event_id = e.id)`)
+	if err != nil {
+		return err
+	}
+	var found []model.BashExecution
+	for rows.Next() {
+		var e model.Event
+		var errMsg, command, interrupted sql.NullString
+		var isInterrupt sql.NullBool
+		if err := rows.Scan(&e.SessionID, &e.EventType, &e.Timestamp, &errMsg, &isInterrupt,
+			&command, &interrupted); err != nil {
+			rows.Close()
+			return err
+		}
+		tool := "Bash"
+		e.ToolName = &tool
+		if errMsg.Valid {
+			e.Error = &errMsg.String
+		}
+		if isInterrupt.Valid {
+			e.IsInterrupt = &isInterrupt.Bool
+		}
+		e.ToolInput, _ = json.Marshal(map[string]string{"command": command.String})
+		// SQLite's ->> turns a JSON true into 1.
+		e.ToolResponse, _ = json.Marshal(map[string]bool{"interrupted": interrupted.String == "true" || interrupted.String == "1"})
+		if b, ok := model.ParseBashExecution(e); ok {
+			found = append(found, b)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, b := range found {
+		if err := insertBashExecution(tx, s.args, b); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`DROP TABLE IF EXISTS bash_history`)
+	return err
+}
+
+// BashHistory lists Bash calls matching pattern in their command, stdout
+// or stderr, newest first. failedOnly keeps non-zero exits, errors and
+// interruptions.
+func (s *sqlStore) BashHistory(pattern string, failedOnly bool, limit int, tf *model.TimeFilter) ([]model.BashExecution, error) {
+	query := `
+		SELECT e.session_id, e.tool_use_id,
+		       e.tool_input->>'$.command', e.tool_input->>'$.description',
+		       e.tool_response->>'$.stdout', e.tool_response->>'$.stderr', e.error,
+		       b.exit_code, b.interrupted, b.started_at, e.timestamp
+		FROM bash_executions b
+		JOIN events e ON e.id = b.event_id
+		WHERE 1 = 1`
+	var params []interface{}
+	if pattern != "" && pattern != "*" {
+		query += fmt.Sprintf(`
+		  AND ((e.tool_input->>'$.command') %[1]s '%%' || ? || '%%'
+		       OR (e.tool_response->>'$.stdout') %[1]s '%%' || ? || '%%'
+		       OR (e.tool_response->>'$.stderr') %[1]s '%%' || ? || '%%')`, s.dialect.ilike)
+		params = append(params, pattern, pattern, pattern)
+	}
+	if failedOnly {
+		query += `
+		  AND (b.interrupted OR e.error IS NOT NULL OR b.exit_code <> 0)`
+	}
+	timeClause, params := appendTimeClauses(tf, "e.timestamp", true, params)
+	query += timeClause + `
+		ORDER BY e.timestamp DESC, e.id DESC
+		LIMIT ?`
+	params = append(params, limit)
+
+	rows, err := s.query(query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("bash history: %w", err)
+	}
+	defer rows.Close()
+
+	var out []model.BashExecution
+	for rows.Next() {
+		var b model.BashExecution
+		var toolUseID, command, description, stdout, stderr, errMsg sql.NullString
+		var exitCode sql.NullInt64
+		var startedAt nullTime
+		if err := rows.Scan(&b.SessionID, &toolUseID, &command, &description,
+			&stdout, &stderr, &errMsg, &exitCode, &b.Interrupted,
+			&startedAt, &b.Timestamp); err != nil {
+			return nil, err
+		}
+		b.ToolUseID = toolUseID.String
+		b.Command = command.String
+		b.Description = description.String
+		b.Stdout = stdout.String
+		b.Stderr = stderr.String
+		b.Error = errMsg.String
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			b.ExitCode = &code
+		}
+		if startedAt.Valid {
+			b.StartedAt = &startedAt.Time
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"clog/internal/model"
+)
+
+const (
+	benchMessages  = 512
+	benchDimension = 768
+	benchBatch     = 64
+)
+
+// openBenchStore returns a store seeded with benchMessages messages and an
+// empty embeddings table, plus the ids of the seeded messages.
+func openBenchStore(b *testing.B, backend string) (Store, []int64) {
+	b.Helper()
+	st, err := Open(backend, filepath.Join(b.TempDir(), "bench."+backend))
+	if err != nil {
+		b.Fatalf("open: %v", err)
+	}
+	b.Cleanup(func() { st.Close() })
+	if err := st.InitCoreSchema(); err != nil {
+		b.Fatalf("init core schema: %v", err)
+	}
+	if d, ok := st.(*DuckDB); ok {
+		_, err = d.db.Exec(embeddingSchema(benchDimension))
+	} else {
+		err = st.InitEmbeddingSchema(benchDimension)
+	}
+	if err != nil {
+		b.Fatalf("init embedding schema: %v", err)
+	}
+
+	msgs := make([]model.Message, benchMessages)
+	for i := range msgs {
+		msgs[i] = model.Message{
+			SessionID: "bench",
+			UUID:      fmt.Sprintf("m%d", i),
+			Role:      "user",
+			Content:   fmt.Sprintf("message %d", i),
+			Timestamp: time.Now(),
+		}
+	}
+	if err := st.SaveHarvestedMessages(msgs, "/bench.jsonl", 1); err != nil {
+		b.Fatalf("seed messages: %v", err)
+	}
+
+	pending, err := st.UnembeddedMessages(benchMessages)
+	if err != nil {
+		b.Fatalf("unembedded: %v", err)
+	}
+	ids := make([]int64, len(pending))
+	for i, m := range pending {
+		ids[i] = m.ID
+	}
+	return st, ids
+}
+
+func benchVectors(n int) [][]float32 {
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		v := make([]float32, benchDimension)
+		for j := range v {
+			v[j] = float32(i+j) / benchDimension
+		}
+		vecs[i] = v
+	}
+	return vecs
+}
+
+func clearEmbeddings(b *testing.B, st Store) {
+	b.Helper()
+	var err error
+	switch s := st.(type) {
+	case *DuckDB:
+		_, err = s.db.Exec("DELETE FROM message_embeddings")
+	case *SQLite:
+		_, err = s.db.Exec("DELETE FROM message_embeddings")
+	}
+	if err != nil {
+		b.Fatalf("clear embeddings: %v", err)
+	}
+}
+
+// BenchmarkSaveEmbedding_PerRow stores each vector in its own statement,
+// as runEmbed did before batching.
+func BenchmarkSaveEmbedding_PerRow(b *testing.B) {
+	for _, backend := range backends {
+		b.Run(backend, func(b *testing.B) {
+			st, ids := openBenchStore(b, backend)
+			vecs := benchVectors(len(ids))
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				clearEmbeddings(b, st)
+				b.StartTimer()
+				for i, id := range ids {
+					if err := st.SaveEmbedding(id, vecs[i]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSaveEmbeddings_Batched stores vectors in runEmbed-sized transactions.
+func BenchmarkSaveEmbeddings_Batched(b *testing.B) {
+	for _, backend := range backends {
+		b.Run(backend, func(b *testing.B) {
+			st, ids := openBenchStore(b, backend)
+			vecs := benchVectors(len(ids))
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				clearEmbeddings(b, st)
+				b.StartTimer()
+				for i := 0; i < len(ids); i += benchBatch {
+					end := min(i+benchBatch, len(ids))
+					if err := st.SaveEmbeddings(ids[i:end], vecs[i:end]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
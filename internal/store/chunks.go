@@ -0,0 +1,59 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"clog/internal/model"
+)
+
+// SaveChunkEmbeddings persists embeddings for message chunks in one
+// transaction. Callers should pass every chunk of a message together:
+// a message with any stored chunk no longer counts as unembedded.
+func (s *sqlStore) SaveChunkEmbeddings(chunks []model.MessageChunk, embeddings [][]float32) error {
+	if len(chunks) != len(embeddings) {
+		return fmt.Errorf("save chunk embeddings: %d chunks for %d embeddings", len(chunks), len(embeddings))
+	}
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO chunk_embeddings (message_id, chunk_index, start_offset, end_offset, embedding)
+		VALUES (?, ?, ?, ?, %s)
+		ON CONFLICT DO NOTHING
+	`, s.dialect.vectorParam(len(embeddings[0]))))
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, c := range chunks {
+		args := s.args([]interface{}{c.MessageID, c.Index, c.Start, c.End, embeddings[i]})
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("insert embedding for message %d chunk %d: %w", c.MessageID, c.Index, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordEmbeddingFailure marks a message the provider rejected, so later
+// runs skip it instead of failing on it again.
+func (s *sqlStore) RecordEmbeddingFailure(messageID int64, reason string) error {
+	_, err := s.exec(`
+		INSERT INTO embedding_failures (message_id, error, failed_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (message_id) DO UPDATE SET error = excluded.error, failed_at = excluded.failed_at
+	`, messageID, reason, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("record embedding failure for message %d: %w", messageID, err)
+	}
+	return nil
+}
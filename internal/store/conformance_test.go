@@ -0,0 +1,1367 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"clog/internal/model"
+	"clog/internal/search"
+)
+
+// The conformance suite runs every test against each backend so that
+// DuckDB and SQLite stay behaviourally identical behind the Store interface.
+
+var backends = []string{BackendDuckDB, BackendSQLite}
+
+// forEachBackend runs fn as a subtest against a fresh store per backend.
+func forEachBackend(t *testing.T, fn func(t *testing.T, st Store)) {
+	t.Helper()
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			st, err := Open(backend, filepath.Join(t.TempDir(), "test."+backend))
+			if err != nil {
+				t.Fatalf("open %s store: %v", backend, err)
+			}
+			t.Cleanup(func() { st.Close() })
+			if err := st.InitCoreSchema(); err != nil {
+				t.Fatalf("init core schema: %v", err)
+			}
+			fn(t, st)
+		})
+	}
+}
+
+// initEmbeddings creates the embeddings table. DuckDB's vss install needs
+// network access, so its table is created directly.
+func initEmbeddings(t *testing.T, st Store, dimension int) {
+	t.Helper()
+	if d, ok := st.(*DuckDB); ok {
+		initTestEmbeddings(t, d, dimension)
+		return
+	}
+	if err := st.InitEmbeddingSchema(dimension); err != nil {
+		t.Fatalf("init embedding schema: %v", err)
+	}
+}
+
+// rawDB returns the store's connection, for setting up states the Store
+// interface can't reach.
+func rawDB(st Store) *sql.DB {
+	switch s := st.(type) {
+	case *DuckDB:
+		return s.db
+	case *SQLite:
+		return s.db
+	}
+	return nil
+}
+
+// mustParse parses a search query or fails the test.
+func mustParse(t *testing.T, s string) *search.Query {
+	t.Helper()
+	q, err := search.Parse(s)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return q
+}
+
+func TestOpen_WhenBackendUnknown_ShouldReturnError(t *testing.T) {
+	if _, err := Open("postgres", filepath.Join(t.TempDir(), "x")); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+}
+
+func TestConformance_InitCoreSchema_ShouldBeIdempotent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		if err := st.InitCoreSchema(); err != nil {
+			t.Fatalf("second init: %v", err)
+		}
+	})
+}
+
+func TestConformance_SaveHarvestedMessages_ShouldDedupeAndTrackOffset(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		st.UpsertSession(model.Session{ID: "sess-1", CWD: "/tmp", CreatedAt: time.Now()})
+		msg := model.Message{SessionID: "sess-1", UUID: "m1", Role: "user", Content: "hello", Timestamp: time.Now()}
+
+		if err := st.SaveHarvestedMessages([]model.Message{msg}, "/t.jsonl", 10); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		if err := st.SaveHarvestedMessages([]model.Message{msg}, "/t.jsonl", 20); err != nil {
+			t.Fatalf("save again: %v", err)
+		}
+
+		messages, err := st.SessionMessages("sess-1", 10)
+		if err != nil {
+			t.Fatalf("session messages: %v", err)
+		}
+		if len(messages) != 1 {
+			t.Errorf("expected 1 message after duplicate insert, got %d", len(messages))
+		}
+
+		offset, err := st.GetOffset("/t.jsonl")
+		if err != nil {
+			t.Fatalf("get offset: %v", err)
+		}
+		if offset != 20 {
+			t.Errorf("expected offset 20, got %d", offset)
+		}
+	})
+}
+
+func TestConformance_GetOffset_WhenPathUnknown_ShouldReturnZero(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		offset, err := st.GetOffset("/missing.jsonl")
+		if err != nil {
+			t.Fatalf("get offset: %v", err)
+		}
+		if offset != 0 {
+			t.Errorf("expected 0, got %d", offset)
+		}
+	})
+}
+
+func TestConformance_SessionMessages_ShouldReturnChronologicalOrder(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now()
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "m3", Role: "user", Content: "third", Timestamp: now},
+			{SessionID: "sess-1", UUID: "m1", Role: "user", Content: "first", Timestamp: now.Add(-2 * time.Hour)},
+			{SessionID: "sess-1", UUID: "m2", Role: "assistant", Content: "second", Timestamp: now.Add(-1 * time.Hour)},
+		}, "/t.jsonl", 1)
+
+		messages, err := st.SessionMessages("sess-1", 10)
+		if err != nil {
+			t.Fatalf("session messages: %v", err)
+		}
+		if len(messages) != 3 {
+			t.Fatalf("expected 3 messages, got %d", len(messages))
+		}
+		if messages[0].Content != "first" || messages[2].Content != "third" {
+			t.Errorf("unexpected order: %q, %q, %q", messages[0].Content, messages[1].Content, messages[2].Content)
+		}
+	})
+}
+
+func TestConformance_TextSearch_ShouldMatchCaseInsensitively(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedMessages(t, st)
+
+		results, err := st.TextSearch(mustParse(t, "AUTH"), 10, false, nil, nil)
+		if err != nil {
+			t.Fatalf("text search: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("expected 2 results, got %d", len(results))
+		}
+	})
+}
+
+func TestConformance_TextSearch_ShouldApplyTimeFilter(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedMessages(t, st)
+
+		since := time.Now().Add(-6 * time.Hour)
+		results, err := st.TextSearch(mustParse(t, "auth"), 10, false, &model.TimeFilter{Since: &since}, nil)
+		if err != nil {
+			t.Fatalf("text search: %v", err)
+		}
+		if len(results) != 1 || results[0].Content != "recent message about auth" {
+			t.Errorf("expected only the recent message, got %+v", results)
+		}
+	})
+}
+
+func TestConformance_ToolSearch_ShouldFilterByNameAndTime(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedEvents(t, st)
+
+		all, err := st.ToolSearch(mustParse(t, "*"), 10, nil, nil)
+		if err != nil {
+			t.Fatalf("tool search: %v", err)
+		}
+		if len(all) != 3 {
+			t.Errorf("expected 3 events, got %d", len(all))
+		}
+
+		since := time.Now().Add(-6 * time.Hour)
+		bash, err := st.ToolSearch(mustParse(t, "bash"), 10, &model.TimeFilter{Since: &since}, nil)
+		if err != nil {
+			t.Fatalf("tool search: %v", err)
+		}
+		if len(bash) != 1 {
+			t.Fatalf("expected 1 recent Bash event, got %d", len(bash))
+		}
+		var input map[string]string
+		if err := json.Unmarshal([]byte(bash[0].ToolInput), &input); err != nil {
+			t.Fatalf("tool input is not JSON: %v", err)
+		}
+		if input["command"] != "echo recent" {
+			t.Errorf("expected 'echo recent', got %q", input["command"])
+		}
+	})
+}
+
+func TestConformance_TextSearch_WhenGrouped_ShouldLimitSessionsAndCountAllHits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now()
+		var msgs []model.Message
+		// Session a's many hits are all newer than b's and c's.
+		for i := 0; i < 8; i++ {
+			msgs = append(msgs, model.Message{SessionID: "a", UUID: fmt.Sprintf("a%d", i), Role: "user",
+				Content: fmt.Sprintf("deploy %d", i), Timestamp: now.Add(-time.Duration(i) * time.Minute)})
+		}
+		msgs = append(msgs,
+			model.Message{SessionID: "b", UUID: "b0", Role: "user", Content: "deploy b", Timestamp: now.Add(-time.Hour)},
+			model.Message{SessionID: "b", UUID: "b1", Role: "user", Content: "deploy b again", Timestamp: now.Add(-2 * time.Hour)},
+			model.Message{SessionID: "c", UUID: "c0", Role: "user", Content: "deploy c", Timestamp: now.Add(-3 * time.Hour)},
+		)
+		st.SaveHarvestedMessages(msgs, "/t.jsonl", 1)
+
+		results, err := st.TextSearch(mustParse(t, "deploy"), 2, true, nil, nil)
+		if err != nil {
+			t.Fatalf("text search: %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("expected 2 sessions, got %+v", results)
+		}
+		if results[0].Content != "deploy 0" || results[0].Collapsed != 7 {
+			t.Errorf("expected a's newest hit with 7 others, got %+v", results[0])
+		}
+		if results[1].Content != "deploy b" || results[1].Collapsed != 1 {
+			t.Errorf("expected b's newest hit with 1 other, got %+v", results[1])
+		}
+	})
+}
+
+func TestConformance_TextSearch_ShouldApplyFilter(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now()
+		agentType, agentID := "Explore", "agent-1"
+		st.InsertEvent(model.Event{SessionID: "sess-b", EventType: "SubagentStop", Timestamp: now, AgentType: &agentType, AgentID: &agentID})
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-a1", UUID: "m1", Role: "user", Content: "fix the login", Timestamp: now},
+			{SessionID: "sess-a1", UUID: "m2", Role: "assistant", Content: "login fixed", Model: "claude-opus-4", Timestamp: now},
+			{SessionID: "sess-b", UUID: "m3", Role: "assistant", Content: "login flow found", Model: "claude-haiku-4", AgentID: "agent-1", Timestamp: now},
+		}, "/t.jsonl", 1)
+
+		cases := []struct {
+			name   string
+			filter model.Filter
+			want   []string
+		}{
+			{"role", model.Filter{Role: "user"}, []string{"fix the login"}},
+			{"session prefix", model.Filter{SessionID: "sess-a"}, []string{"fix the login", "login fixed"}},
+			{"model substring", model.Filter{Model: "OPUS"}, []string{"login fixed"}},
+			{"agent id", model.Filter{Agent: "agent-1"}, []string{"login flow found"}},
+			{"agent type", model.Filter{Agent: "Explore"}, []string{"login flow found"}},
+			{"combined", model.Filter{Role: "assistant", SessionID: "sess-b"}, []string{"login flow found"}},
+		}
+		for _, c := range cases {
+			results, err := st.TextSearch(mustParse(t, "login"), 10, false, nil, &c.filter)
+			if err != nil {
+				t.Fatalf("%s: text search: %v", c.name, err)
+			}
+			got := map[string]bool{}
+			for _, r := range results {
+				got[r.Content] = true
+			}
+			if len(got) != len(c.want) {
+				t.Errorf("%s: expected %v, got %+v", c.name, c.want, results)
+				continue
+			}
+			for _, w := range c.want {
+				if !got[w] {
+					t.Errorf("%s: missing %q in %+v", c.name, w, results)
+				}
+			}
+		}
+	})
+}
+
+func TestConformance_ToolSearch_ShouldApplyFilter(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedEvents(t, st)
+
+		own, err := st.ToolSearch(mustParse(t, "*"), 10, nil, &model.Filter{SessionID: "test-"})
+		if err != nil {
+			t.Fatalf("tool search: %v", err)
+		}
+		if len(own) != 3 {
+			t.Errorf("expected 3 events for the session prefix, got %d", len(own))
+		}
+
+		none, err := st.ToolSearch(mustParse(t, "*"), 10, nil, &model.Filter{SessionID: "other"})
+		if err != nil {
+			t.Fatalf("tool search: %v", err)
+		}
+		if len(none) != 0 {
+			t.Errorf("expected no events for another session, got %d", len(none))
+		}
+
+		if _, err := st.ToolSearch(mustParse(t, "*"), 10, nil, &model.Filter{Role: "user"}); err == nil {
+			t.Error("expected error for role filter on tool events")
+		}
+	})
+}
+
+func TestConformance_TextSearch_WhenQueryHasNoText_ShouldSkipToolOnlyMessages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now()
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "m1", Role: "assistant",
+				RawContent: `[{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]`, Timestamp: now},
+			{SessionID: "sess-1", UUID: "m2", Role: "assistant", Content: "running ls",
+				RawContent: `[{"type":"text","text":"running ls"},{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]`, Timestamp: now},
+		}, "/t.jsonl", 1)
+
+		for _, query := range []string{"*", "tool:Bash"} {
+			results, err := st.TextSearch(mustParse(t, query), 10, false, nil, nil)
+			if err != nil {
+				t.Fatalf("%q: text search: %v", query, err)
+			}
+			if len(results) != 1 || results[0].Content != "running ls" {
+				t.Errorf("%q: expected only the message with text, got %+v", query, results)
+			}
+		}
+	})
+}
+
+func TestConformance_TextSearch_ShouldCompileQueries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now()
+		const m1, m2, m3, m4 = "the flaky auth test fails again", "auth fixed in vendor code", "running the auth tests", "auth is next"
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "m1", Role: "user", Content: m1, Timestamp: now.Add(-30 * 24 * time.Hour)},
+			{SessionID: "sess-1", UUID: "m2", Role: "assistant", Content: m2, Timestamp: now},
+			{SessionID: "sess-1", UUID: "m3", Role: "assistant", Content: m3,
+				RawContent: `[{"type":"text","text":"running the auth tests"},{"type":"tool_use","name":"Bash","input":{"command":"go test ./internal/store/..."}}]`, Timestamp: now},
+			{SessionID: "sess-1", UUID: "m4", Role: "assistant", Content: m4,
+				RawContent: `[{"type":"tool_use","name":"Edit","input":{"file_path":"/src/store.go"}}]`, Timestamp: now},
+		}, "/t.jsonl", 1)
+
+		cases := []struct {
+			query string
+			want  []string
+		}{
+			{`"flaky auth"`, []string{m1}},
+			{"auth -vendor -tool:Bash -file:store.go", []string{m1}},
+			{"auth role:assistant -vendor", []string{m3, m4}},
+			{"tool:bash", []string{m3}},
+			{"file:store.go", []string{m4}},
+			{"auth after:1w", []string{m2, m3, m4}},
+			{"auth before:1w", []string{m1}},
+			{"-model:opus auth fails", []string{m1}},
+		}
+		for _, c := range cases {
+			results, err := st.TextSearch(mustParse(t, c.query), 10, false, nil, nil)
+			if err != nil {
+				t.Fatalf("%q: text search: %v", c.query, err)
+			}
+			got := map[string]bool{}
+			for _, r := range results {
+				got[r.Content] = true
+			}
+			if len(results) != len(c.want) {
+				t.Errorf("%q: expected %v, got %+v", c.query, c.want, results)
+				continue
+			}
+			for _, w := range c.want {
+				if !got[w] {
+					t.Errorf("%q: missing %q in %+v", c.query, w, results)
+				}
+			}
+		}
+	})
+}
+
+func TestConformance_ToolSearch_ShouldCompileQueries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedEvents(t, st)
+
+		cases := []struct {
+			query string
+			want  int
+		}{
+			{"tool:bash", 2},
+			{"echo -recent", 1},
+			{`"/tmp/now.txt"`, 1},
+			{"file:now.txt", 1},
+			{"-tool:Read after:1w", 2},
+		}
+		for _, c := range cases {
+			results, err := st.ToolSearch(mustParse(t, c.query), 10, nil, nil)
+			if err != nil {
+				t.Fatalf("%q: tool search: %v", c.query, err)
+			}
+			if len(results) != c.want {
+				t.Errorf("%q: expected %d events, got %d", c.query, c.want, len(results))
+			}
+		}
+
+		if _, err := st.ToolSearch(mustParse(t, "model:opus"), 10, nil, nil); err == nil {
+			t.Error("expected error for model: on tool events")
+		}
+	})
+}
+
+func TestConformance_SearchSimilar_ShouldApplyQueryBeforeRanking(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		initEmbeddings(t, st, 3)
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "m1", Role: "user", Content: "deploy to staging", Timestamp: time.Now()},
+			{SessionID: "sess-1", UUID: "m2", Role: "assistant", Content: "deployed to staging", Timestamp: time.Now()},
+		}, "/t.jsonl", 1)
+		msgs, _ := st.UnembeddedMessages(10)
+		ids := make([]int64, len(msgs))
+		vecs := make([][]float32, len(msgs))
+		for i, m := range msgs {
+			ids[i] = m.ID
+			vecs[i] = []float32{1, 0, 0}
+			if m.Role == "user" {
+				vecs[i] = []float32{0, 1, 0}
+			}
+		}
+		if err := st.SaveEmbeddings(ids, vecs); err != nil {
+			t.Fatalf("save embeddings: %v", err)
+		}
+
+		results, err := st.SearchSimilar([]float32{1, 0, 0}, mustParse(t, "role:user"), 10, false, nil, nil)
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		if len(results) != 1 || results[0].Role != "user" {
+			t.Errorf("expected only the user message despite lower similarity, got %+v", results)
+		}
+	})
+}
+
+func TestConformance_RecordEmbeddingFailure_ShouldSkipMessage(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedMessages(t, st)
+		initEmbeddings(t, st, 3)
+
+		pending, err := st.UnembeddedMessages(10)
+		if err != nil {
+			t.Fatalf("unembedded: %v", err)
+		}
+		if err := st.RecordEmbeddingFailure(pending[0].ID, "400: input too long"); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+		if err := st.RecordEmbeddingFailure(pending[0].ID, "400: again"); err != nil {
+			t.Fatalf("record failure twice: %v", err)
+		}
+
+		after, err := st.UnembeddedMessages(10)
+		if err != nil {
+			t.Fatalf("unembedded: %v", err)
+		}
+		if len(after) != len(pending)-1 {
+			t.Fatalf("expected %d unembedded messages, got %d", len(pending)-1, len(after))
+		}
+		for _, m := range after {
+			if m.ID == pending[0].ID {
+				t.Errorf("expected the rejected message skipped")
+			}
+		}
+	})
+}
+
+func TestConformance_UnembeddedMessages_ShouldSkipBlankContent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		initEmbeddings(t, st, 3)
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "s1", UUID: "a", Role: "assistant", Content: "   ", Timestamp: time.Now()},
+			{SessionID: "s1", UUID: "b", Role: "assistant", Content: "text", Timestamp: time.Now()},
+		}, "/t.jsonl", 1)
+
+		pending, err := st.UnembeddedMessages(10)
+		if err != nil {
+			t.Fatalf("unembedded: %v", err)
+		}
+		if len(pending) != 1 || pending[0].Content != "text" {
+			t.Errorf("expected only the message with text, got %+v", pending)
+		}
+	})
+}
+
+func TestConformance_Embeddings_ShouldRankBySimilarity(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedMessages(t, st)
+		initEmbeddings(t, st, 3)
+
+		pending, err := st.UnembeddedMessages(10)
+		if err != nil {
+			t.Fatalf("unembedded: %v", err)
+		}
+		if len(pending) != 3 {
+			t.Fatalf("expected 3 unembedded messages, got %d", len(pending))
+		}
+
+		vectors := [][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+		for i, m := range pending {
+			if err := st.SaveEmbedding(m.ID, vectors[i]); err != nil {
+				t.Fatalf("save embedding: %v", err)
+			}
+		}
+
+		pending, err = st.UnembeddedMessages(10)
+		if err != nil {
+			t.Fatalf("unembedded: %v", err)
+		}
+		if len(pending) != 0 {
+			t.Errorf("expected no unembedded messages, got %d", len(pending))
+		}
+
+		results, err := st.SearchSimilar([]float32{0, 0.9, 0.1}, nil, 2, false, nil, nil)
+		if err != nil {
+			t.Fatalf("search similar: %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results, got %d", len(results))
+		}
+		if results[0].Content != "recent message about auth" {
+			t.Errorf("expected closest match first, got %q", results[0].Content)
+		}
+		if results[0].Score <= results[1].Score {
+			t.Errorf("expected descending scores, got %v then %v", results[0].Score, results[1].Score)
+		}
+
+		grouped, err := st.SearchSimilar([]float32{0, 0.9, 0.1}, nil, 2, true, nil, nil)
+		if err != nil {
+			t.Fatalf("search similar: %v", err)
+		}
+		if len(grouped) != 1 || grouped[0].Content != "recent message about auth" || grouped[0].Collapsed != 2 {
+			t.Errorf("expected the session's best hit with 2 others, got %+v", grouped)
+		}
+	})
+}
+
+func TestConformance_Summaries_ShouldUpsertAndJoinSession(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		st.UpsertSession(model.Session{ID: "sess-1", CWD: "/home/user/app", CreatedAt: time.Now()})
+		st.SaveSummary("sess-1", "First.", "m1")
+		st.SaveSummary("sess-1", "Second.", "m2")
+
+		results, err := st.ListSummaries(10, nil)
+		if err != nil {
+			t.Fatalf("list summaries: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expected 1 summary, got %d", len(results))
+		}
+		if results[0].Summary != "Second." || results[0].CWD != "/home/user/app" {
+			t.Errorf("unexpected summary %+v", results[0])
+		}
+
+		past := time.Now().Add(-time.Hour)
+		results, err = st.ListSummaries(10, &model.TimeFilter{Until: &past})
+		if err != nil {
+			t.Fatalf("list summaries: %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("expected 0 summaries before cutoff, got %d", len(results))
+		}
+	})
+}
+
+func TestConformance_MessageContext_ShouldReturnThreadAroundHit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now()
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "a", Role: "user", Content: "how do I deploy", Timestamp: now.Add(-3 * time.Minute)},
+			{SessionID: "sess-1", UUID: "b", ParentUUID: "a", Role: "assistant", Content: "run make deploy", Timestamp: now.Add(-2 * time.Minute)},
+			{SessionID: "sess-1", UUID: "c", ParentUUID: "b", Role: "user", Content: "thanks", Timestamp: now.Add(-1 * time.Minute)},
+			{SessionID: "sess-2", UUID: "z", Role: "user", Content: "unrelated", Timestamp: now.Add(-2 * time.Minute)},
+		}, "/t.jsonl", 1)
+
+		hits, err := st.TextSearch(mustParse(t, "make deploy"), 1, false, nil, nil)
+		if err != nil || len(hits) != 1 {
+			t.Fatalf("text search: %v (%d hits)", err, len(hits))
+		}
+
+		thread, err := st.MessageContext(hits[0].ID, 5)
+		if err != nil {
+			t.Fatalf("message context: %v", err)
+		}
+		if len(thread) != 3 {
+			t.Fatalf("expected 3 thread messages, got %d", len(thread))
+		}
+		if thread[0].Content != "how do I deploy" || !thread[1].Hit || thread[2].Content != "thanks" {
+			t.Errorf("unexpected thread %+v", thread)
+		}
+		for _, m := range thread {
+			if m.HitID != hits[0].ID {
+				t.Errorf("expected every message tagged with hit %d, got %+v", hits[0].ID, m)
+			}
+		}
+	})
+}
+
+func TestConformance_MessageContext_WhenThreadIsLong_ShouldWidenPastToolTurns(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		// Tool-only turns aren't counted, so the first load around the hit
+		// falls short of the messages with text and must widen.
+		now := time.Now()
+		var msgs []model.Message
+		parent := ""
+		for i := 0; i < 60; i++ {
+			content := ""
+			if i == 0 || i == 30 || i == 59 {
+				content = fmt.Sprintf("turn %d", i)
+			}
+			uuid := fmt.Sprintf("u%d", i)
+			msgs = append(msgs, model.Message{SessionID: "sess-1", UUID: uuid, ParentUUID: parent, Role: "assistant",
+				Content: content, Timestamp: now.Add(time.Duration(i-60) * time.Minute)})
+			parent = uuid
+		}
+		st.SaveHarvestedMessages(msgs, "/t.jsonl", 1)
+
+		hits, err := st.TextSearch(mustParse(t, `"turn 30"`), 1, false, nil, nil)
+		if err != nil || len(hits) != 1 {
+			t.Fatalf("text search: %v (%d hits)", err, len(hits))
+		}
+		thread, err := st.MessageContext(hits[0].ID, 1)
+		if err != nil {
+			t.Fatalf("message context: %v", err)
+		}
+		if len(thread) != 3 || thread[0].Content != "turn 0" || !thread[1].Hit || thread[2].Content != "turn 59" {
+			t.Errorf("unexpected thread %+v", thread)
+		}
+	})
+}
+
+func TestConformance_ResolveSession_ShouldMatchUniquePrefix(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		st.UpsertSession(model.Session{ID: "abc123-one", CWD: "/p", TranscriptPath: "/t.jsonl", CreatedAt: time.Now()})
+		st.UpsertSession(model.Session{ID: "abc456-two", CWD: "/p", CreatedAt: time.Now()})
+
+		sess, err := st.ResolveSession("abc1")
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if sess.ID != "abc123-one" || sess.TranscriptPath != "/t.jsonl" {
+			t.Errorf("unexpected session %+v", sess)
+		}
+
+		if _, err := st.ResolveSession("abc"); err == nil {
+			t.Error("expected error for ambiguous prefix")
+		}
+		if _, err := st.ResolveSession("zzz"); err == nil {
+			t.Error("expected error for unknown prefix")
+		}
+		if _, err := st.ResolveSession("abc_"); err == nil {
+			t.Error("expected '_' to match literally, not as a wildcard")
+		}
+	})
+}
+
+func TestConformance_SessionTimeline_ShouldInterleaveMessagesAndToolCalls(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now().UTC()
+		tool := "Bash"
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "a", Role: "user", Content: "list files", Timestamp: now.Add(-3 * time.Minute)},
+			{SessionID: "sess-1", UUID: "b", Role: "assistant", Content: "", Timestamp: now.Add(-150 * time.Second)},
+			{SessionID: "sess-1", UUID: "c", Role: "assistant", Content: "done", Timestamp: now.Add(-1 * time.Minute)},
+		}, "/t.jsonl", 1)
+		st.InsertEvent(model.Event{
+			SessionID: "sess-1", EventType: "PostToolUse", Timestamp: now.Add(-2 * time.Minute),
+			ToolName: &tool, ToolInput: json.RawMessage(`{"command":"ls"}`),
+		})
+		st.InsertEvent(model.Event{SessionID: "sess-1", EventType: "Stop", Timestamp: now})
+
+		entries, err := st.SessionTimeline("sess-1", 10, 0)
+		if err != nil {
+			t.Fatalf("timeline: %v", err)
+		}
+		if len(entries) != 3 {
+			t.Fatalf("expected 3 entries, got %d: %+v", len(entries), entries)
+		}
+		if entries[0].Content != "list files" || entries[1].Kind != "tool" || entries[2].Content != "done" {
+			t.Errorf("unexpected order %+v", entries)
+		}
+		if entries[1].ToolName != "Bash" || entries[1].ToolInput == "" {
+			t.Errorf("expected Bash tool call with input, got %+v", entries[1])
+		}
+
+		page, err := st.SessionTimeline("sess-1", 1, 2)
+		if err != nil {
+			t.Fatalf("timeline page: %v", err)
+		}
+		if len(page) != 1 || page[0].Content != "done" {
+			t.Errorf("expected last entry on offset page, got %+v", page)
+		}
+	})
+}
+
+func TestConformance_SessionSummary_ShouldReturnNilWhenMissing(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		st.UpsertSession(model.Session{ID: "sess-1", CWD: "/p", CreatedAt: time.Now()})
+
+		sum, err := st.SessionSummary("sess-1")
+		if err != nil {
+			t.Fatalf("summary: %v", err)
+		}
+		if sum != nil {
+			t.Fatalf("expected nil summary, got %+v", sum)
+		}
+
+		st.SaveSummary("sess-1", "Fixed the build.", "m")
+		sum, err = st.SessionSummary("sess-1")
+		if err != nil {
+			t.Fatalf("summary: %v", err)
+		}
+		if sum == nil || sum.Summary != "Fixed the build." {
+			t.Errorf("unexpected summary %+v", sum)
+		}
+	})
+}
+
+func TestConformance_ActiveSessions_ShouldSkipEndedAndQuietSessions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		now := time.Now().UTC()
+		old := now.Add(-48 * time.Hour)
+
+		st.UpsertSession(model.Session{ID: "live", CWD: "/p", TranscriptPath: "/live.jsonl", CreatedAt: old})
+		st.InsertEvent(model.Event{SessionID: "live", EventType: "Stop", Timestamp: now})
+		st.UpsertSession(model.Session{ID: "new", CWD: "/p", TranscriptPath: "/new.jsonl", CreatedAt: now})
+		st.UpsertSession(model.Session{ID: "ended", CWD: "/p", TranscriptPath: "/ended.jsonl", CreatedAt: now})
+		st.InsertEvent(model.Event{SessionID: "ended", EventType: "SessionEnd", Timestamp: now})
+		st.UpsertSession(model.Session{ID: "quiet", CWD: "/p", TranscriptPath: "/quiet.jsonl", CreatedAt: old})
+		st.UpsertSession(model.Session{ID: "no-transcript", CWD: "/p", CreatedAt: now})
+
+		active, err := st.ActiveSessions(now.Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("active sessions: %v", err)
+		}
+		if len(active) != 2 || active[0].ID != "new" || active[1].ID != "live" {
+			t.Fatalf("expected new and live, got %+v", active)
+		}
+		if active[1].TranscriptPath != "/live.jsonl" {
+			t.Errorf("expected transcript path, got %q", active[1].TranscriptPath)
+		}
+	})
+}
+
+func TestConformance_ListSessions_ShouldAggregatePerSessionStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		start := time.Now().UTC().Add(-2 * time.Hour)
+		model1, reason, tool := "claude-opus", "logout", "Bash"
+
+		st.UpsertSession(model.Session{ID: "sess-1", CWD: "/p", CreatedAt: start})
+		st.InsertEvent(model.Event{SessionID: "sess-1", EventType: "SessionStart", Timestamp: start, Model: &model1})
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "a", Role: "user", Content: "add a login page", Timestamp: start.Add(time.Minute)},
+			{SessionID: "sess-1", UUID: "b", Role: "assistant", Content: "done", Model: "claude-sonnet", Timestamp: start.Add(2 * time.Minute)},
+		}, "/t.jsonl", 1)
+		st.InsertEvent(model.Event{SessionID: "sess-1", EventType: "PostToolUse", Timestamp: start.Add(90 * time.Second), ToolName: &tool})
+		st.InsertEvent(model.Event{SessionID: "sess-1", EventType: "SessionEnd", Timestamp: start.Add(30 * time.Minute), Reason: &reason})
+
+		st.UpsertSession(model.Session{ID: "sess-2", CWD: "/p", CreatedAt: start.Add(time.Hour)})
+
+		sessions, err := st.ListSessions(10, SortByStart, nil)
+		if err != nil {
+			t.Fatalf("list sessions: %v", err)
+		}
+		if len(sessions) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(sessions))
+		}
+		if sessions[0].SessionID != "sess-2" {
+			t.Errorf("expected newest session first, got %q", sessions[0].SessionID)
+		}
+
+		s := sessions[1]
+		if s.MessageCount != 2 || s.ToolCount != 1 {
+			t.Errorf("expected 2 messages and 1 tool call, got %d and %d", s.MessageCount, s.ToolCount)
+		}
+		if s.Duration() != 30*time.Minute {
+			t.Errorf("expected 30m duration, got %v", s.Duration())
+		}
+		if s.EndReason != "logout" || s.FirstPrompt != "add a login page" {
+			t.Errorf("unexpected end reason %q or first prompt %q", s.EndReason, s.FirstPrompt)
+		}
+		if len(s.Models) != 2 || s.Models[0] != "claude-opus" || s.Models[1] != "claude-sonnet" {
+			t.Errorf("expected both models, got %v", s.Models)
+		}
+
+		byDuration, err := st.ListSessions(1, SortByDuration, nil)
+		if err != nil {
+			t.Fatalf("list sessions: %v", err)
+		}
+		if len(byDuration) != 1 || byDuration[0].SessionID != "sess-1" {
+			t.Errorf("expected longest session first, got %+v", byDuration)
+		}
+
+		since := start.Add(30 * time.Minute)
+		filtered, err := st.ListSessions(10, "", &model.TimeFilter{Since: &since})
+		if err != nil {
+			t.Fatalf("list sessions: %v", err)
+		}
+		if len(filtered) != 1 || filtered[0].SessionID != "sess-2" {
+			t.Errorf("expected only sess-2 after cutoff, got %+v", filtered)
+		}
+
+		if _, err := st.ListSessions(10, "bogus", nil); err == nil {
+			t.Error("expected error for unknown sort")
+		}
+	})
+}
+
+func TestConformance_ListSessions_WhenMessagesPredateSession_ShouldFilterOnStart(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		// A harvested transcript can begin before its session row was created.
+		start := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Second)
+		st.UpsertSession(model.Session{ID: "sess-1", CWD: "/p", CreatedAt: start.Add(time.Hour)})
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "sess-1", UUID: "a", Role: "user", Content: "hello", Timestamp: start},
+		}, "/t.jsonl", 1)
+
+		until := start.Add(30 * time.Minute)
+		sessions, err := st.ListSessions(10, "", &model.TimeFilter{Until: &until})
+		if err != nil {
+			t.Fatalf("list sessions: %v", err)
+		}
+		if len(sessions) != 1 || !sessions[0].StartedAt.Equal(start) {
+			t.Errorf("expected sess-1 starting at its first message, got %+v", sessions)
+		}
+	})
+}
+
+func TestConformance_UsageStats_ShouldAggregateEventsAndSessions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		day := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+		bash, read, failed := "Bash", "Read", "exit status 1"
+		interrupted := true
+
+		st.UpsertSession(model.Session{ID: "s1", CWD: "/p", CreatedAt: day})
+		st.UpsertSession(model.Session{ID: "s2", CWD: "/p", CreatedAt: day.Add(24 * time.Hour)})
+		events := []model.Event{
+			{SessionID: "s1", EventType: "UserPromptSubmit", Timestamp: day},
+			{SessionID: "s1", EventType: "UserPromptSubmit", Timestamp: day.Add(10 * time.Minute)},
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &bash, Timestamp: day.Add(20 * time.Minute)},
+			{SessionID: "s1", EventType: "PostToolUseFailure", ToolName: &bash, Error: &failed, Timestamp: day.Add(30 * time.Minute)},
+			{SessionID: "s2", EventType: "UserPromptSubmit", Timestamp: day.Add(24 * time.Hour)},
+			{SessionID: "s2", EventType: "PostToolUse", ToolName: &read, IsInterrupt: &interrupted, Timestamp: day.Add(24*time.Hour + 10*time.Minute)},
+		}
+		for _, e := range events {
+			if err := st.InsertEvent(e); err != nil {
+				t.Fatalf("insert event: %v", err)
+			}
+		}
+
+		stats, err := st.UsageStats(nil)
+		if err != nil {
+			t.Fatalf("usage stats: %v", err)
+		}
+		if stats.Sessions != 2 || stats.Prompts != 3 || stats.AvgPromptsPerSession != 1.5 {
+			t.Errorf("unexpected session/prompt counts %+v", stats)
+		}
+		if len(stats.SessionsPerDay) != 2 || stats.SessionsPerDay[0].Day != "2024-05-06" {
+			t.Errorf("unexpected sessions per day %+v", stats.SessionsPerDay)
+		}
+		if stats.ToolCalls != 3 || stats.ToolErrors != 2 {
+			t.Errorf("expected 3 calls and 2 errors, got %d and %d", stats.ToolCalls, stats.ToolErrors)
+		}
+		if len(stats.Tools) != 2 || stats.Tools[0].Name != "Bash" || stats.Tools[0].Errors != 1 {
+			t.Errorf("unexpected tool usage %+v", stats.Tools)
+		}
+		if len(stats.Hours) == 0 || stats.Hours[0].Hour != 9 || stats.Hours[0].Events != 6 {
+			t.Errorf("expected 09 UTC as busiest hour, got %+v", stats.Hours)
+		}
+		if stats.AvgSessionLength != 20*time.Minute {
+			t.Errorf("expected 20m average session length, got %v", stats.AvgSessionLength)
+		}
+
+		since := day.Add(12 * time.Hour)
+		recent, err := st.UsageStats(&model.TimeFilter{Since: &since})
+		if err != nil {
+			t.Fatalf("usage stats: %v", err)
+		}
+		if recent.Sessions != 1 || recent.Prompts != 1 || recent.ToolCalls != 1 {
+			t.Errorf("expected only the second day, got %+v", recent)
+		}
+	})
+}
+
+func TestConformance_Query_ShouldExposeCuratedViews(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		ts := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+		bash, edit, failed, prompt := "Bash", "Edit", "boom", "fix it"
+		st.UpsertSession(model.Session{ID: "s1", CWD: "/p", CreatedAt: ts})
+		events := []model.Event{
+			{SessionID: "s1", EventType: "UserPromptSubmit", Prompt: &prompt, Timestamp: ts},
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &bash,
+				ToolInput:    json.RawMessage(`{"command":"go test ./...","description":"run tests"}`),
+				ToolResponse: json.RawMessage(`{"stdout":"ok","stderr":"","interrupted":false}`),
+				Timestamp:    ts.Add(time.Minute)},
+			{SessionID: "s1", EventType: "PostToolUseFailure", ToolName: &edit, Error: &failed,
+				ToolInput: json.RawMessage(`{"file_path":"/p/main.go"}`),
+				Timestamp: ts.Add(2 * time.Minute)},
+		}
+		for _, e := range events {
+			if err := st.InsertEvent(e); err != nil {
+				t.Fatalf("insert event: %v", err)
+			}
+		}
+
+		cases := []struct {
+			query string
+			want  []interface{}
+		}{
+			{"SELECT prompt FROM prompts", []interface{}{"fix it"}},
+			{"SELECT command, description, stdout FROM bash_commands", []interface{}{"go test ./...", "run tests", "ok"}},
+			{"SELECT tool_name, file_path, error FROM file_edits", []interface{}{"Edit", "/p/main.go", "boom"}},
+			{"SELECT tool_name, status FROM tool_executions ORDER BY timestamp DESC", []interface{}{"Edit", "error"}},
+			{"SELECT COUNT(*) AS n FROM tool_executions", []interface{}{int64(2)}},
+		}
+		for _, c := range cases {
+			res, err := st.Query(c.query, 1)
+			if err != nil {
+				t.Fatalf("%s: %v", c.query, err)
+			}
+			if len(res.Rows) != 1 || len(res.Columns) != len(c.want) {
+				t.Fatalf("%s: expected 1 row of %d columns, got %+v", c.query, len(c.want), res)
+			}
+			for i, want := range c.want {
+				if res.Rows[0][i] != want {
+					t.Errorf("%s: column %s = %#v, want %#v", c.query, res.Columns[i], res.Rows[0][i], want)
+				}
+			}
+		}
+	})
+}
+
+func TestConformance_OpenReadOnly_ShouldRejectWrites(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test."+backend)
+			st, err := Open(backend, path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if err := st.InitCoreSchema(); err != nil {
+				t.Fatalf("init core schema: %v", err)
+			}
+			st.UpsertSession(model.Session{ID: "s1", CWD: "/p", CreatedAt: time.Now()})
+			st.Close()
+
+			ro, err := OpenReadOnly(backend, path)
+			if err != nil {
+				t.Fatalf("open read-only: %v", err)
+			}
+			defer ro.Close()
+
+			if _, err := ro.Query("DELETE FROM sessions", 0); err == nil {
+				t.Error("expected delete to fail on a read-only store")
+			}
+			res, err := ro.Query("SELECT session_id FROM sessions", 0)
+			if err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			if len(res.Rows) != 1 {
+				t.Errorf("expected session to survive, got %d rows", len(res.Rows))
+			}
+		})
+	}
+}
+
+func TestConformance_InitCoreSchema_ShouldBackfillFileActivityOnce(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		ts := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+		edit, notebook, bash := "Edit", "NotebookEdit", "Bash"
+		st.UpsertSession(model.Session{ID: "s1", CWD: "/p", CreatedAt: ts})
+		events := []model.Event{
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &edit, Timestamp: ts,
+				ToolInput: json.RawMessage(`{"file_path":"/p/main.go","old_string":"a","new_string":"b"}`)},
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &notebook, Timestamp: ts.Add(time.Minute),
+				ToolInput: json.RawMessage(`{"notebook_path":"/p/nb.ipynb"}`)},
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &bash, Timestamp: ts.Add(2 * time.Minute),
+				ToolInput: json.RawMessage(`{"command":"ls"}`)},
+		}
+		for _, e := range events {
+			if err := st.InsertEvent(e); err != nil {
+				t.Fatalf("insert event: %v", err)
+			}
+		}
+		// The hook already recorded the edit; the notebook predates it.
+		st.InsertFileActivity(model.FileActivity{SessionID: "s1", Path: "main.go", Operation: model.FileEdit, Timestamp: ts})
+		if _, err := rawDB(st).Exec("DELETE FROM migrations"); err != nil {
+			t.Fatalf("reset migrations: %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := st.InitCoreSchema(); err != nil {
+				t.Fatalf("init schema: %v", err)
+			}
+		}
+		all, err := st.FileHistory(".", 10, nil)
+		if err != nil {
+			t.Fatalf("file history: %v", err)
+		}
+		if len(all) != 2 || all[0].Path != "nb.ipynb" || all[1].Path != "main.go" {
+			t.Errorf("expected the notebook backfilled beside the edit, got %+v", all)
+		}
+	})
+}
+
+func TestConformance_FileHistory_ShouldMatchFilesAndDirectoriesNewestFirst(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		ts := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+		activity := []model.FileActivity{
+			{SessionID: "s1", Path: "internal/store/store.go", Operation: model.FileRead, Timestamp: ts},
+			{SessionID: "s1", Path: "internal/store/store.go", Operation: model.FileEdit, Timestamp: ts.Add(time.Minute)},
+			{SessionID: "s2", Path: "internal/storex/a.go", Operation: model.FileWrite, Timestamp: ts.Add(2 * time.Minute)},
+			{SessionID: "s2", Path: "main.go", Operation: model.FileEdit, Timestamp: ts.Add(3 * time.Minute)},
+		}
+		for _, a := range activity {
+			if err := st.InsertFileActivity(a); err != nil {
+				t.Fatalf("insert file activity: %v", err)
+			}
+		}
+
+		file, err := st.FileHistory("internal/store/store.go", 10, nil)
+		if err != nil {
+			t.Fatalf("file history: %v", err)
+		}
+		if len(file) != 2 || file[0].Operation != model.FileEdit || file[1].Operation != model.FileRead {
+			t.Errorf("expected edit then read, got %+v", file)
+		}
+
+		dir, err := st.FileHistory("internal/store", 10, nil)
+		if err != nil {
+			t.Fatalf("file history: %v", err)
+		}
+		if len(dir) != 2 {
+			t.Errorf("expected the directory to exclude its sibling internal/storex, got %+v", dir)
+		}
+
+		all, err := st.FileHistory(".", 10, nil)
+		if err != nil {
+			t.Fatalf("file history: %v", err)
+		}
+		if len(all) != 4 || all[0].Path != "main.go" {
+			t.Errorf("expected all activity newest first, got %+v", all)
+		}
+
+		since := ts.Add(90 * time.Second)
+		recent, err := st.FileHistory(".", 10, &model.TimeFilter{Since: &since})
+		if err != nil {
+			t.Fatalf("file history: %v", err)
+		}
+		if len(recent) != 2 {
+			t.Errorf("expected 2 entries after the time filter, got %d", len(recent))
+		}
+	})
+}
+
+func TestConformance_BashHistory_ShouldSearchOutputAndFilterFailures(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		ts := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+		bash, toolUseID := "Bash", "tu-1"
+		if err := st.InsertEvent(model.Event{SessionID: "s1", EventType: "PreToolUse", ToolName: &bash,
+			ToolUseID: &toolUseID, Timestamp: ts}); err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+
+		fail := "Exit code 2"
+		interrupt := true
+		events := []model.Event{
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &bash, ToolUseID: &toolUseID,
+				ToolInput:    json.RawMessage(`{"command":"go build ./..."}`),
+				ToolResponse: json.RawMessage(`{"stdout":"built","stderr":"","interrupted":false}`),
+				Timestamp:    ts.Add(3 * time.Second)},
+			{SessionID: "s1", EventType: "PostToolUseFailure", ToolName: &bash, Error: &fail,
+				ToolInput:    json.RawMessage(`{"command":"go test ./..."}`),
+				ToolResponse: json.RawMessage(`{"stdout":"","stderr":"FAIL clog/store"}`),
+				Timestamp:    ts.Add(time.Minute)},
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &bash, IsInterrupt: &interrupt,
+				ToolInput: json.RawMessage(`{"command":"sleep 60"}`),
+				Timestamp: ts.Add(2 * time.Minute)},
+		}
+		// As the hook does: store the event, then what was parsed from it.
+		for _, e := range events {
+			if err := st.InsertEvent(e); err != nil {
+				t.Fatalf("insert event: %v", err)
+			}
+			b, ok := model.ParseBashExecution(e)
+			if !ok {
+				t.Fatalf("parse %+v", e)
+			}
+			if err := st.InsertBashExecution(b); err != nil {
+				t.Fatalf("insert bash execution: %v", err)
+			}
+		}
+
+		all, err := st.BashHistory("*", false, 10, nil)
+		if err != nil {
+			t.Fatalf("bash history: %v", err)
+		}
+		if len(all) != 3 || all[0].Command != "sleep 60" {
+			t.Fatalf("expected 3 executions newest first, got %+v", all)
+		}
+		if all[0].ExitCode != nil || !all[0].Interrupted {
+			t.Errorf("expected interrupted call with unknown exit, got %+v", all[0])
+		}
+		if d := all[2].Duration(); d != 3*time.Second {
+			t.Errorf("expected duration from PreToolUse of 3s, got %v", d)
+		}
+
+		failed, err := st.BashHistory("", true, 10, nil)
+		if err != nil {
+			t.Fatalf("bash history: %v", err)
+		}
+		if len(failed) != 2 {
+			t.Errorf("expected 2 failed executions, got %d", len(failed))
+		}
+
+		byOutput, err := st.BashHistory("fail clog", false, 10, nil)
+		if err != nil {
+			t.Fatalf("bash history: %v", err)
+		}
+		if len(byOutput) != 1 || byOutput[0].ExitCode == nil || *byOutput[0].ExitCode != 2 {
+			t.Errorf("expected the failing test run via stderr match, got %+v", byOutput)
+		}
+	})
+}
+
+func TestConformance_InsertBashExecution_WhenEventMissing_ShouldFail(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		b := model.BashExecution{SessionID: "s1", Command: "ls", Timestamp: time.Now()}
+		if err := st.InsertBashExecution(b); err == nil {
+			t.Error("expected an error for a call with no stored event")
+		}
+	})
+}
+
+func TestConformance_InitCoreSchema_ShouldBackfillBashExecutionsOnce(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		ts := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+		bash, fail := "Bash", "Exit code 1"
+		for _, e := range []model.Event{
+			{SessionID: "s1", EventType: "PostToolUse", ToolName: &bash, Timestamp: ts,
+				ToolInput:    json.RawMessage(`{"command":"sleep 9"}`),
+				ToolResponse: json.RawMessage(`{"stdout":"","interrupted":true}`)},
+			{SessionID: "s1", EventType: "PostToolUseFailure", ToolName: &bash, Error: &fail, Timestamp: ts.Add(time.Minute),
+				ToolInput: json.RawMessage(`{"command":"false"}`)},
+		} {
+			if err := st.InsertEvent(e); err != nil {
+				t.Fatalf("insert event: %v", err)
+			}
+		}
+		// Stores from before bash_executions kept a copy in bash_history.
+		db := rawDB(st)
+		if _, err := db.Exec("CREATE TABLE bash_history (id INTEGER)"); err != nil {
+			t.Fatalf("create legacy table: %v", err)
+		}
+		if _, err := db.Exec("DELETE FROM migrations"); err != nil {
+			t.Fatalf("reset migrations: %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := st.InitCoreSchema(); err != nil {
+				t.Fatalf("init schema: %v", err)
+			}
+		}
+		all, err := st.BashHistory("*", false, 10, nil)
+		if err != nil {
+			t.Fatalf("bash history: %v", err)
+		}
+		if len(all) != 2 || all[0].ExitCode == nil || *all[0].ExitCode != 1 || !all[1].Interrupted {
+			t.Errorf("expected the failure then the interrupted call, got %+v", all)
+		}
+		if _, err := db.Exec("SELECT * FROM bash_history"); err == nil {
+			t.Error("expected bash_history to be dropped")
+		}
+	})
+}
+
+func TestConformance_ChunkEmbeddings_ShouldScoreMessagesByBestChunk(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedMessages(t, st)
+		initEmbeddings(t, st, 3)
+
+		pending, err := st.UnembeddedMessages(10)
+		if err != nil {
+			t.Fatalf("unembedded: %v", err)
+		}
+		long, other, legacy := pending[0], pending[1], pending[2]
+
+		chunks := []model.MessageChunk{
+			{MessageID: long.ID, Index: 0, Start: 0, End: 10},
+			{MessageID: long.ID, Index: 1, Start: 8, End: 22},
+			{MessageID: other.ID, Index: 0, Start: 0, End: len(other.Content)},
+		}
+		vectors := [][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0.6, 0.8}}
+		if err := st.SaveChunkEmbeddings(chunks, vectors); err != nil {
+			t.Fatalf("save chunk embeddings: %v", err)
+		}
+		if err := st.SaveEmbedding(legacy.ID, []float32{0, 0, 1}); err != nil {
+			t.Fatalf("save embedding: %v", err)
+		}
+
+		pending, err = st.UnembeddedMessages(10)
+		if err != nil {
+			t.Fatalf("unembedded: %v", err)
+		}
+		if len(pending) != 0 {
+			t.Errorf("expected chunked messages to count as embedded, got %d pending", len(pending))
+		}
+
+		results, err := st.SearchSimilar([]float32{0, 1, 0}, nil, 10, false, nil, nil)
+		if err != nil {
+			t.Fatalf("search similar: %v", err)
+		}
+		if len(results) != 3 {
+			t.Fatalf("expected one result per message, got %d", len(results))
+		}
+		if results[0].ID != long.ID || results[0].MatchStart != 8 || results[0].MatchEnd != 22 {
+			t.Errorf("expected the long message's second chunk first, got %+v", results[0])
+		}
+		if results[2].ID != legacy.ID || results[2].MatchEnd != 0 {
+			t.Errorf("expected the whole-message embedding last with no match range, got %+v", results[2])
+		}
+	})
+}
+
+func TestConformance_SaveChunkEmbeddings_WhenLengthsDiffer_ShouldReturnError(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		initEmbeddings(t, st, 3)
+		err := st.SaveChunkEmbeddings([]model.MessageChunk{{MessageID: 1}}, nil)
+		if err == nil {
+			t.Error("expected error for mismatched lengths")
+		}
+	})
+}
+
+func TestConformance_SearchSessions_ShouldRankBySummarySimilarity(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		initEmbeddings(t, st, 3)
+		old := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+		recent := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+		st.UpsertSession(model.Session{ID: "s-auth", CWD: "/p", CreatedAt: old})
+		st.UpsertSession(model.Session{ID: "s-deploy", CWD: "/p", CreatedAt: recent})
+		st.SaveHarvestedMessages([]model.Message{
+			{SessionID: "s-auth", UUID: "m1", Role: "user", Content: "fix auth", Timestamp: old},
+			{SessionID: "s-auth", UUID: "m2", Role: "assistant", Content: "done", Timestamp: old},
+		}, "/t.jsonl", 1)
+		st.SaveSummary("s-auth", "Fixed the auth token refresh.", "m")
+		st.SaveSummary("s-deploy", "Set up the deploy pipeline.", "m")
+
+		pending, err := st.UnembeddedSummaries(10)
+		if err != nil {
+			t.Fatalf("unembedded summaries: %v", err)
+		}
+		if len(pending) != 2 {
+			t.Fatalf("expected 2 unembedded summaries, got %d", len(pending))
+		}
+		vectors := map[string][]float32{"s-auth": {1, 0, 0}, "s-deploy": {0, 1, 0}}
+		embeddings := [][]float32{vectors[pending[0].SessionID], vectors[pending[1].SessionID]}
+		if err := st.SaveSummaryEmbeddings(pending, embeddings); err != nil {
+			t.Fatalf("save summary embeddings: %v", err)
+		}
+		if pending, _ := st.UnembeddedSummaries(10); len(pending) != 0 {
+			t.Errorf("expected no unembedded summaries, got %d", len(pending))
+		}
+
+		results, err := st.SearchSessions([]float32{0.9, 0.1, 0}, 10, nil)
+		if err != nil {
+			t.Fatalf("search sessions: %v", err)
+		}
+		if len(results) != 2 || results[0].SessionID != "s-auth" {
+			t.Fatalf("expected s-auth first, got %+v", results)
+		}
+		if results[0].MessageCount != 2 || !results[0].StartedAt.Equal(old) || results[0].Summary != "Fixed the auth token refresh." {
+			t.Errorf("unexpected match details %+v", results[0])
+		}
+
+		since := recent.Add(-time.Hour)
+		filtered, err := st.SearchSessions([]float32{0.9, 0.1, 0}, 10, &model.TimeFilter{Since: &since})
+		if err != nil {
+			t.Fatalf("search sessions: %v", err)
+		}
+		if len(filtered) != 1 || filtered[0].SessionID != "s-deploy" {
+			t.Errorf("expected only s-deploy after the time filter, got %+v", filtered)
+		}
+	})
+}
+
+func TestConformance_UnembeddedSummaries_WhenSummaryRegenerated_ShouldReturnItAgain(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		initEmbeddings(t, st, 3)
+		st.UpsertSession(model.Session{ID: "s1", CWD: "/p", CreatedAt: time.Now()})
+		st.SaveSummary("s1", "first", "m")
+
+		pending, _ := st.UnembeddedSummaries(10)
+		if err := st.SaveSummaryEmbeddings(pending, [][]float32{{1, 0, 0}}); err != nil {
+			t.Fatalf("save summary embeddings: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		st.SaveSummary("s1", "second", "m")
+		pending, err := st.UnembeddedSummaries(10)
+		if err != nil {
+			t.Fatalf("unembedded summaries: %v", err)
+		}
+		if len(pending) != 1 || pending[0].Summary != "second" {
+			t.Fatalf("expected the regenerated summary, got %+v", pending)
+		}
+		if err := st.SaveSummaryEmbeddings(pending, [][]float32{{0, 1, 0}}); err != nil {
+			t.Fatalf("replace summary embedding: %v", err)
+		}
+	})
+}
+
+func TestConformance_EmbeddingModel_ShouldRecordCheckAndReset(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st Store) {
+		seedMessages(t, st)
+		initEmbeddings(t, st, 3)
+
+		if err := ClaimEmbeddingModel(st, "a@3", 3); err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		if id, err := st.EmbeddingModel(); err != nil || id != "a@3" {
+			t.Fatalf("expected a@3 recorded, got %q, %v", id, err)
+		}
+		var mismatch *ModelMismatchError
+		if err := CheckEmbeddingModel(st, "b@3", 3); !errors.As(err, &mismatch) || mismatch.Stored != "a@3" {
+			t.Fatalf("expected a mismatch with a@3, got %v", err)
+		}
+
+		pending, _ := st.UnembeddedMessages(10)
+		if err := st.SaveEmbedding(pending[0].ID, []float32{1, 0, 0}); err != nil {
+			t.Fatalf("save embedding: %v", err)
+		}
+		if dim, err := st.EmbeddingDimension(); err != nil || dim != 3 {
+			t.Fatalf("expected dimension 3, got %d, %v", dim, err)
+		}
+
+		if err := st.ResetEmbeddings(); err != nil {
+			t.Fatalf("reset: %v", err)
+		}
+		if id, _ := st.EmbeddingModel(); id != "" {
+			t.Errorf("expected the model record cleared, got %q", id)
+		}
+		initEmbeddings(t, st, 4)
+		if after, _ := st.UnembeddedMessages(10); len(after) != len(pending) {
+			t.Fatalf("expected every message unembedded again, got %d of %d", len(after), len(pending))
+		}
+		if err := st.SaveEmbedding(pending[0].ID, []float32{1, 0, 0, 0}); err != nil {
+			t.Fatalf("save embedding of the new dimension: %v", err)
+		}
+
+		// Without a record, vectors are checked by their dimension.
+		if err := CheckEmbeddingModel(st, "c@5", 5); !errors.As(err, &mismatch) {
+			t.Errorf("expected a dimension mismatch, got %v", err)
+		}
+		if err := ClaimEmbeddingModel(st, "c@4", 4); err != nil {
+			t.Errorf("expected an unrecorded model of the same dimension accepted, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+// DuckDB is a Store backed by a DuckDB file, using the vss extension
+// for vector search.
+type DuckDB struct {
+	sqlStore
+}
+
+// OpenDuckDB creates a new DuckDB store connected to the given file.
+func OpenDuckDB(dbPath string) (*DuckDB, error) {
+	db, err := sql.Open("duckdb", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open duckdb %s: %w", dbPath, err)
+	}
+	return &DuckDB{sqlStore{db: db, dialect: duckDialect}}, nil
+}
+
+// OpenDuckDBReadOnly opens an existing DuckDB file in read-only access mode.
+func OpenDuckDBReadOnly(dbPath string) (*DuckDB, error) {
+	db, err := sql.Open("duckdb", dbPath+"?access_mode=read_only")
+	if err != nil {
+		return nil, fmt.Errorf("open duckdb %s: %w", dbPath, err)
+	}
+	return &DuckDB{sqlStore{db: db, dialect: duckDialect}}, nil
+}
+
+var duckDialect = dialect{
+	ilike:      "ILIKE",
+	similarity: "array_cosine_similarity",
+	strftime: func(col, format string) string {
+		return fmt.Sprintf("strftime(%s, '%s')", col, format)
+	},
+	epoch: func(col string) string { return fmt.Sprintf("epoch(%s)", col) },
+	vectorParam: func(dim int) string {
+		return fmt.Sprintf("?::FLOAT[%d]", dim)
+	},
+	vectorLen:   func(col string) string { return fmt.Sprintf("array_length(%s)", col) },
+	tableExists: `SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ?`,
+}
+
+// InitCoreSchema creates the base tables and indexes if they don't exist.
+func (s *DuckDB) InitCoreSchema() error {
+	_, err := s.db.Exec(coreSchema)
+	if err != nil {
+		return fmt.Errorf("init core schema: %w", err)
+	}
+	if err := s.replaceViews(); err != nil {
+		return err
+	}
+	return s.runMigrations()
+}
+
+// InitEmbeddingSchema installs the vss extension and creates the embeddings table.
+func (s *DuckDB) InitEmbeddingSchema(dimension int) error {
+	if _, err := s.db.Exec("INSTALL vss"); err != nil {
+		return fmt.Errorf("install vss extension: %w", err)
+	}
+	if _, err := s.db.Exec("LOAD vss"); err != nil {
+		return fmt.Errorf("load vss extension: %w", err)
+	}
+	if _, err := s.db.Exec(embeddingSchema(dimension)); err != nil {
+		return fmt.Errorf("create embedding table: %w", err)
+	}
+	return nil
+}
+
+// LoadVSS loads the vss extension for the current connection (required for search).
+func (s *DuckDB) LoadVSS() error {
+	_, err := s.db.Exec("LOAD vss")
+	return err
+}
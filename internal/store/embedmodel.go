@@ -0,0 +1,140 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// embeddingTables hold vectors, whose length is fixed by the model.
+var embeddingTables = []string{"chunk_embeddings", "message_embeddings", "summary_embeddings"}
+
+// ModelMismatchError reports that the stored vectors were made by another
+// model than the configured one, so they can't be compared with its.
+type ModelMismatchError struct {
+	Stored, Current string
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("this project's embeddings were made by %s, but the configured model is %s; "+
+		"configure %s again, or run `clog -e --reembed` to replace them", e.Stored, e.Current, e.Stored)
+}
+
+// CheckEmbeddingModel returns a *ModelMismatchError unless the stored
+// vectors were made by model, whose vectors have the given dimension.
+// Stores that predate recording the model are checked by dimension only.
+func CheckEmbeddingModel(st Store, model string, dimension int) error {
+	stored, err := st.EmbeddingModel()
+	if err != nil {
+		return err
+	}
+	if stored != "" {
+		if model != "" && stored != model {
+			return &ModelMismatchError{Stored: stored, Current: model}
+		}
+		return nil
+	}
+	dim, err := st.EmbeddingDimension()
+	if err != nil {
+		return err
+	}
+	if dim > 0 && dim != dimension {
+		return &ModelMismatchError{Stored: fmt.Sprintf("a %d-dimension model", dim), Current: model}
+	}
+	return nil
+}
+
+// ClaimEmbeddingModel checks model as CheckEmbeddingModel does and, if no
+// model is recorded yet, records it as the one making the stored vectors.
+func ClaimEmbeddingModel(st Store, model string, dimension int) error {
+	if err := CheckEmbeddingModel(st, model, dimension); err != nil {
+		return err
+	}
+	stored, err := st.EmbeddingModel()
+	if err != nil || stored != "" || model == "" {
+		return err
+	}
+	return st.SetEmbeddingModel(model)
+}
+
+// EmbeddingModel returns the recorded model. A read-only store may predate
+// the embedding_meta table, which is the same as no record.
+func (s *sqlStore) EmbeddingModel() (string, error) {
+	if ok, err := s.hasTable("embedding_meta"); !ok {
+		return "", err
+	}
+	var id string
+	err := s.queryRow(`SELECT value FROM embedding_meta WHERE name = 'model'`).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read embedding model: %w", err)
+	}
+	return id, nil
+}
+
+// SetEmbeddingModel records id as the model making the stored vectors.
+func (s *sqlStore) SetEmbeddingModel(id string) error {
+	_, err := s.exec(`
+		INSERT INTO embedding_meta (name, value) VALUES ('model', ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value
+	`, id)
+	if err != nil {
+		return fmt.Errorf("record embedding model: %w", err)
+	}
+	return nil
+}
+
+// EmbeddingDimension samples one stored vector.
+func (s *sqlStore) EmbeddingDimension() (int, error) {
+	for _, table := range embeddingTables {
+		ok, err := s.hasTable(table)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		var dim int
+		err = s.queryRow(fmt.Sprintf(`SELECT %s FROM %s LIMIT 1`, s.dialect.vectorLen("embedding"), table)).Scan(&dim)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read embedding dimension: %w", err)
+		}
+		return dim, nil
+	}
+	return 0, nil
+}
+
+// ResetEmbeddings drops the vector tables rather than emptying them,
+// since DuckDB fixes their dimension when they are created.
+func (s *sqlStore) ResetEmbeddings() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, table := range embeddingTables {
+		if _, err := tx.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			return fmt.Errorf("drop %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM embedding_failures`); err != nil {
+		return fmt.Errorf("clear embedding failures: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM embedding_meta WHERE name = 'model'`); err != nil {
+		return fmt.Errorf("clear embedding model: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) hasTable(name string) (bool, error) {
+	var n int
+	if err := s.queryRow(s.dialect.tableExists, name).Scan(&n); err != nil {
+		return false, fmt.Errorf("look up table %s: %w", name, err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"clog/internal/model"
+)
+
+// InsertFileActivity records a tool call that touched a file.
+func (s *sqlStore) InsertFileActivity(a model.FileActivity) error {
+	_, err := s.exec(`
+		INSERT INTO file_activity (session_id, path, operation, timestamp)
+		VALUES (?, ?, ?, ?)`,
+		a.SessionID, a.Path, a.Operation, a.Timestamp,
+	)
+	return err
+}
+
+// FileHistory lists activity on path or beneath it, newest first.
+func (s *sqlStore) FileHistory(path string, limit int, tf *model.TimeFilter) ([]model.FileActivity, error) {
+	query := `
+		SELECT session_id, path, operation, timestamp
+		FROM file_activity`
+	var params []interface{}
+	hasWhere := false
+	if path != "." {
+		dir := path + "/"
+		query += `
+		WHERE (path = ? OR substr(path, 1, ?) = ?)`
+		params = append(params, path, len(dir), dir)
+		hasWhere = true
+	}
+	timeClause, params := appendTimeClauses(tf, "timestamp", hasWhere, params)
+	query += timeClause + `
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ?`
+	params = append(params, limit)
+
+	rows, err := s.query(query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("file history: %w", err)
+	}
+	defer rows.Close()
+
+	var out []model.FileActivity
+	for rows.Next() {
+		var a model.FileActivity
+		if err := rows.Scan(&a.SessionID, &a.Path, &a.Operation, &a.Timestamp); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// backfillFileActivity records file activity for the events stored before
+// file_activity existed. Rows the hook already recorded are not duplicated.
+func (s *sqlStore) backfillFileActivity(tx *sql.Tx) error {
+	// Only the path fields are read; Write inputs carry whole files.
+	rows, err := tx.Query(`
+		SELECT e.session_id, e.tool_name, e.timestamp, COALESCE(s.cwd, ''),
+		       e.tool_input->>'$.file_path', e.tool_input->>'$.notebook_path'
+		FROM events e
+		LEFT JOIN sessions s ON s.session_id = e.session_id
+		WHERE e.event_type = 'PostToolUse'
+		  AND e.tool_name IN ('Read', 'Edit', 'MultiEdit', 'NotebookEdit', 'Write')`)
+	if err != nil {
+		return err
+	}
+	var found []model.FileActivity
+	for rows.Next() {
+		e := model.Event{EventType: "PostToolUse"}
+		var tool, cwd string
+		var filePath, notebookPath sql.NullString
+		if err := rows.Scan(&e.SessionID, &tool, &e.Timestamp, &cwd, &filePath, &notebookPath); err != nil {
+			rows.Close()
+			return err
+		}
+		e.ToolName = &tool
+		e.ToolInput, _ = json.Marshal(map[string]string{"file_path": filePath.String, "notebook_path": notebookPath.String})
+		if a, ok := model.ParseFileActivity(e, cwd); ok {
+			found = append(found, a)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range found {
+		_, err := tx.Exec(`
+			INSERT INTO file_activity (session_id, path, operation, timestamp)
+			SELECT ?, ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM file_activity
+			                  WHERE session_id = ? AND path = ? AND operation = ? AND timestamp = ?)`,
+			s.args([]interface{}{a.SessionID, a.Path, a.Operation, a.Timestamp,
+				a.SessionID, a.Path, a.Operation, a.Timestamp})...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"clog/internal/model"
+	"clog/internal/search"
+)
+
+// where accumulates AND-ed conditions and their parameters. Conditions are
+// fixed SQL written here; user input only ever travels as parameters.
+type where struct {
+	conds  []string
+	params []interface{}
+}
+
+// add appends a condition whose placeholders are bound to params.
+func (w *where) add(cond string, params ...interface{}) {
+	w.conds = append(w.conds, cond)
+	w.params = append(w.params, params...)
+}
+
+// timeRange restricts col to the range in tf, if any.
+func (w *where) timeRange(tf *model.TimeFilter, col string) {
+	if tf == nil {
+		return
+	}
+	if tf.Since != nil {
+		w.add(col+" >= ?", *tf.Since)
+	}
+	if tf.Until != nil {
+		w.add(col+" <= ?", *tf.Until)
+	}
+}
+
+// filterColumns describes how query terms apply to one table. Conditions
+// are written with ILIKE and bind the term's value to every placeholder.
+type filterColumns struct {
+	// kind names the rows, for errors about unsupported fields.
+	kind      string
+	timestamp string
+	// text is the condition for bare words and phrases.
+	text string
+	// fields maps search fields to conditions; missing fields don't apply.
+	fields map[string]string
+}
+
+// messageColumns filters the messages table aliased as m. Messages carry
+// only the subagent id; its type comes from the SubagentStop event. Tool
+// and file terms match the tool_use blocks kept in raw_content.
+var messageColumns = filterColumns{
+	kind:      "messages",
+	timestamp: "m.timestamp",
+	text:      "m.content ILIKE '%' || ? || '%'",
+	fields: map[string]string{
+		search.Role:    "m.role = ?",
+		search.Session: "substr(m.session_id, 1, length(?)) = ?",
+		search.Model:   "m.model ILIKE '%' || ? || '%'",
+		search.Agent:   "(m.agent_id = ? OR m.agent_id IN (SELECT agent_id FROM events WHERE agent_type = ?))",
+		search.Tool:    `CAST(m.raw_content AS VARCHAR) ILIKE '%"name":"' || ? || '"%'`,
+		search.File:    "CAST(m.raw_content AS VARCHAR) ILIKE '%' || ? || '%'",
+	},
+}
+
+// eventColumns filters the events table. Text matches the tool name,
+// input and response.
+var eventColumns = filterColumns{
+	kind:      "tool events",
+	timestamp: "timestamp",
+	text: "(tool_name || ' ' || COALESCE(CAST(tool_input AS VARCHAR), '') || ' ' ||" +
+		" COALESCE(CAST(tool_response AS VARCHAR), '')) ILIKE '%' || ? || '%'",
+	fields: map[string]string{
+		search.Session: "substr(session_id, 1, length(?)) = ?",
+		search.Agent:   "(agent_id = ? OR agent_type = ?)",
+		search.Tool:    "lower(tool_name) = lower(?)",
+		search.File:    "CAST(tool_input AS VARCHAR) ILIKE '%' || ? || '%'",
+	},
+}
+
+// filter restricts the query by f, matching fields as the query
+// language does.
+func (w *where) filter(f *model.Filter, cols filterColumns, d dialect) error {
+	if f == nil {
+		return nil
+	}
+	for _, t := range []struct{ field, value string }{
+		{search.Role, f.Role},
+		{search.Session, f.SessionID},
+		{search.Model, f.Model},
+		{search.Agent, f.Agent},
+	} {
+		if t.value == "" {
+			continue
+		}
+		if err := w.match(cols.fields[t.field], t.field, t.value, false, cols, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// search restricts the query to rows matching every term of q.
+func (w *where) search(q *search.Query, cols filterColumns, d dialect) error {
+	if q == nil {
+		return nil
+	}
+	for _, t := range q.Terms {
+		var err error
+		switch {
+		case t.Field == search.After:
+			w.add(cols.timestamp+" >= ?", t.Time)
+		case t.Field == search.Before:
+			w.add(cols.timestamp+" <= ?", t.Time)
+		case t.Kind == search.Field:
+			err = w.match(cols.fields[t.Field], t.Field, t.Value, t.Negated, cols, d)
+		default:
+			err = w.match(cols.text, "text", t.Value, t.Negated, cols, d)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match adds cond with value bound to each placeholder. A negated match
+// also keeps rows where cond is NULL, such as messages without a model.
+func (w *where) match(cond, field, value string, negated bool, cols filterColumns, d dialect) error {
+	if cond == "" {
+		return fmt.Errorf("%s: does not apply to %s", field, cols.kind)
+	}
+	cond = strings.ReplaceAll(cond, "ILIKE", d.ilike)
+	if negated {
+		cond = "NOT COALESCE(" + cond + ", FALSE)"
+	}
+	params := make([]interface{}, strings.Count(cond, "?"))
+	for i := range params {
+		params[i] = value
+	}
+	w.add(cond, params...)
+	return nil
+}
+
+// String renders the conditions as a WHERE clause, or "" if there are none.
+func (w *where) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(w.conds, "\n\t\t  AND ")
+}
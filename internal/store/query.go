@@ -0,0 +1,106 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"clog/internal/model"
+)
+
+// Query runs an ad-hoc SQL query inside a transaction that is always
+// rolled back, so statements that slip past a read-only connection
+// still leave no trace.
+func (s *sqlStore) Query(query string, limit int) (*model.QueryResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin query: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("query columns: %w", err)
+	}
+	result := &model.QueryResult{Columns: cols}
+	for rows.Next() {
+		if limit > 0 && len(result.Rows) == limit {
+			break
+		}
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("scan query row: %w", err)
+		}
+		for i, v := range vals {
+			vals[i] = queryValue(v)
+		}
+		result.Rows = append(result.Rows, vals)
+	}
+	return result, rows.Err()
+}
+
+// replaceViews recreates the curated views in one transaction, so a
+// concurrent query never finds one missing.
+func (s *sqlStore) replaceViews() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("create views: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(viewSchema); err != nil {
+		return fmt.Errorf("create views: %w", err)
+	}
+	return tx.Commit()
+}
+
+// queryValue normalises a driver value to one of the types documented on
+// model.QueryResult. Anything else (DuckDB JSON, lists, structs, ...) is
+// rendered as JSON text.
+func queryValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, bool, int64, float64, string, time.Time:
+		return x
+	case []byte:
+		return string(x)
+	case int:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case int16:
+		return int64(x)
+	case int8:
+		return int64(x)
+	case uint64:
+		return int64(x)
+	case uint32:
+		return int64(x)
+	case uint16:
+		return int64(x)
+	case uint8:
+		return int64(x)
+	case float32:
+		return float64(x)
+	case *big.Int: // DuckDB HUGEINT, e.g. SUM over integers
+		if x.IsInt64() {
+			return x.Int64()
+		}
+		return x.String()
+	case interface{ Float64() float64 }: // DuckDB DECIMAL
+		return x.Float64()
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
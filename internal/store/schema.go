@@ -5,6 +5,7 @@ import "fmt"
 const coreSchema = `
 CREATE SEQUENCE IF NOT EXISTS events_id_seq START 1;
 CREATE SEQUENCE IF NOT EXISTS messages_id_seq START 1;
+CREATE SEQUENCE IF NOT EXISTS file_activity_id_seq START 1;
 
 CREATE TABLE IF NOT EXISTS sessions (
     session_id       VARCHAR PRIMARY KEY,
@@ -53,29 +54,254 @@ CREATE TABLE IF NOT EXISTS messages (
     content       VARCHAR,
     raw_content   JSON,
     model         VARCHAR,
-    timestamp     TIMESTAMP NOT NULL
+    timestamp     TIMESTAMP NOT NULL,
+    agent_id      VARCHAR
 );
 CREATE INDEX IF NOT EXISTS idx_messages_ts      ON messages(timestamp);
 CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id);
+CREATE INDEX IF NOT EXISTS idx_messages_session_ts ON messages(session_id, timestamp);
+-- Added after the first release; older files lack it.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS agent_id VARCHAR;
 
 CREATE TABLE IF NOT EXISTS transcript_offsets (
     transcript_path  VARCHAR PRIMARY KEY,
     last_offset      BIGINT NOT NULL DEFAULT 0
 );
 
+-- Messages the embedding provider rejected; later runs skip them.
+CREATE TABLE IF NOT EXISTS embedding_failures (
+    message_id  BIGINT PRIMARY KEY,
+    error       VARCHAR NOT NULL,
+    failed_at   TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS embedding_meta (
+    name   VARCHAR PRIMARY KEY,
+    value  VARCHAR NOT NULL
+);
+
+-- One-off data migrations already applied to this store.
+CREATE TABLE IF NOT EXISTS migrations (
+    name        VARCHAR PRIMARY KEY,
+    applied_at  TIMESTAMP NOT NULL
+);
+
 CREATE TABLE IF NOT EXISTS session_summaries (
     session_id    VARCHAR PRIMARY KEY,
     summary       VARCHAR NOT NULL,
     model         VARCHAR,
     generated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+
+CREATE TABLE IF NOT EXISTS file_activity (
+    id          BIGINT DEFAULT nextval('file_activity_id_seq') PRIMARY KEY,
+    session_id  VARCHAR NOT NULL,
+    path        VARCHAR NOT NULL,
+    operation   VARCHAR NOT NULL,
+    timestamp   TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_file_activity_path ON file_activity(path);
+
+-- Bash calls parsed from their PostToolUse or PostToolUseFailure event,
+-- which holds the command and output; only derived fields live here.
+CREATE TABLE IF NOT EXISTS bash_executions (
+    event_id     BIGINT PRIMARY KEY,
+    exit_code    INTEGER,
+    interrupted  BOOLEAN NOT NULL DEFAULT false,
+    started_at   TIMESTAMP
+);
 `
 
 func embeddingSchema(dimension int) string {
 	return fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS message_embeddings (
     message_id BIGINT PRIMARY KEY,
-    embedding  FLOAT[%d]
+    embedding  FLOAT[%[1]d]
+);
+
+CREATE TABLE IF NOT EXISTS chunk_embeddings (
+    message_id    BIGINT NOT NULL,
+    chunk_index   INTEGER NOT NULL,
+    start_offset  INTEGER NOT NULL,
+    end_offset    INTEGER NOT NULL,
+    embedding     FLOAT[%[1]d],
+    PRIMARY KEY (message_id, chunk_index)
+);
+
+CREATE TABLE IF NOT EXISTS summary_embeddings (
+    session_id    VARCHAR PRIMARY KEY,
+    generated_at  TIMESTAMP NOT NULL,
+    embedding     FLOAT[%[1]d]
 );
 `, dimension)
 }
+
+// sqliteCoreSchema mirrors coreSchema for SQLite. JSON columns are stored
+// as TEXT and timestamps as UTC text, which sorts chronologically.
+const sqliteCoreSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+    session_id       TEXT PRIMARY KEY,
+    cwd              TEXT NOT NULL,
+    transcript_path  TEXT,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id                     INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id             TEXT NOT NULL,
+    event_type             TEXT NOT NULL,
+    timestamp              TIMESTAMP NOT NULL,
+    permission_mode        TEXT,
+    source                 TEXT,
+    model                  TEXT,
+    agent_type             TEXT,
+    prompt                 TEXT,
+    tool_name              TEXT,
+    tool_input             TEXT,
+    tool_use_id            TEXT,
+    tool_response          TEXT,
+    permission_suggestions TEXT,
+    error                  TEXT,
+    is_interrupt           BOOLEAN,
+    message                TEXT,
+    title                  TEXT,
+    notification_type      TEXT,
+    agent_id               TEXT,
+    agent_transcript_path  TEXT,
+    stop_hook_active       BOOLEAN,
+    trigger_type           TEXT,
+    custom_instructions    TEXT,
+    reason                 TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_events_ts      ON events(timestamp);
+CREATE INDEX IF NOT EXISTS idx_events_session ON events(session_id);
+CREATE INDEX IF NOT EXISTS idx_events_type    ON events(event_type);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id    TEXT NOT NULL,
+    uuid          TEXT UNIQUE,
+    parent_uuid   TEXT,
+    role          TEXT NOT NULL,
+    content       TEXT,
+    raw_content   TEXT,
+    model         TEXT,
+    timestamp     TIMESTAMP NOT NULL,
+    agent_id      TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_messages_ts      ON messages(timestamp);
+CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id);
+CREATE INDEX IF NOT EXISTS idx_messages_session_ts ON messages(session_id, timestamp);
+
+CREATE TABLE IF NOT EXISTS transcript_offsets (
+    transcript_path  TEXT PRIMARY KEY,
+    last_offset      INTEGER NOT NULL DEFAULT 0
+);
+
+-- Messages the embedding provider rejected; later runs skip them.
+CREATE TABLE IF NOT EXISTS embedding_failures (
+    message_id  INTEGER PRIMARY KEY,
+    error       TEXT NOT NULL,
+    failed_at   TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS embedding_meta (
+    name   TEXT PRIMARY KEY,
+    value  TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS migrations (
+    name        TEXT PRIMARY KEY,
+    applied_at  TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS session_summaries (
+    session_id    TEXT PRIMARY KEY,
+    summary       TEXT NOT NULL,
+    model         TEXT,
+    generated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS file_activity (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id  TEXT NOT NULL,
+    path        TEXT NOT NULL,
+    operation   TEXT NOT NULL,
+    timestamp   TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_file_activity_path ON file_activity(path);
+
+CREATE TABLE IF NOT EXISTS bash_executions (
+    event_id     INTEGER PRIMARY KEY,
+    exit_code    INTEGER,
+    interrupted  BOOLEAN NOT NULL DEFAULT false,
+    started_at   TIMESTAMP
+);
+`
+
+// sqliteEmbeddingSchema stores vectors as float32 blobs (see encodeVector).
+const sqliteEmbeddingSchema = `
+CREATE TABLE IF NOT EXISTS message_embeddings (
+    message_id INTEGER PRIMARY KEY,
+    embedding  BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS chunk_embeddings (
+    message_id    INTEGER NOT NULL,
+    chunk_index   INTEGER NOT NULL,
+    start_offset  INTEGER NOT NULL,
+    end_offset    INTEGER NOT NULL,
+    embedding     BLOB NOT NULL,
+    PRIMARY KEY (message_id, chunk_index)
+);
+
+CREATE TABLE IF NOT EXISTS summary_embeddings (
+    session_id    TEXT PRIMARY KEY,
+    generated_at  TIMESTAMP NOT NULL,
+    embedding     BLOB NOT NULL
+);
+`
+
+// viewSchema defines the curated views exposed to ad-hoc queries. It is
+// shared by both backends: ->> extracts a JSON field as text in DuckDB
+// and SQLite alike. Views are dropped and recreated rather than created
+// if missing, so a changed definition reaches existing databases.
+const viewSchema = `
+DROP VIEW IF EXISTS tool_executions;
+CREATE VIEW tool_executions AS
+SELECT id, session_id, timestamp, tool_name, tool_use_id,
+       CASE WHEN error IS NOT NULL OR event_type = 'PostToolUseFailure' THEN 'error'
+            WHEN is_interrupt THEN 'interrupted'
+            ELSE 'ok' END AS status,
+       error, tool_input, tool_response
+FROM events
+WHERE event_type IN ('PostToolUse', 'PostToolUseFailure');
+
+DROP VIEW IF EXISTS bash_commands;
+CREATE VIEW bash_commands AS
+SELECT e.id, e.session_id, e.timestamp,
+       e.tool_input->>'$.command' AS command,
+       e.tool_input->>'$.description' AS description,
+       e.tool_response->>'$.stdout' AS stdout,
+       e.tool_response->>'$.stderr' AS stderr,
+       e.tool_response->>'$.interrupted' AS interrupted,
+       e.error, b.exit_code, b.started_at
+FROM events e
+LEFT JOIN bash_executions b ON b.event_id = e.id
+WHERE e.event_type IN ('PostToolUse', 'PostToolUseFailure') AND e.tool_name = 'Bash';
+
+DROP VIEW IF EXISTS file_edits;
+CREATE VIEW file_edits AS
+SELECT id, session_id, timestamp, tool_name,
+       COALESCE(tool_input->>'$.file_path', tool_input->>'$.notebook_path') AS file_path,
+       error
+FROM events
+WHERE event_type IN ('PostToolUse', 'PostToolUseFailure')
+  AND tool_name IN ('Edit', 'MultiEdit', 'Write', 'NotebookEdit');
+
+DROP VIEW IF EXISTS prompts;
+CREATE VIEW prompts AS
+SELECT id, session_id, timestamp, prompt
+FROM events
+WHERE event_type = 'UserPromptSubmit';
+`
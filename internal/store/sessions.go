@@ -0,0 +1,193 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"clog/internal/model"
+)
+
+// Session sort keys accepted by ListSessions. All sort newest or largest first.
+const (
+	SortByStart    = "start"
+	SortByEnd      = "end"
+	SortByDuration = "duration"
+	SortByMessages = "messages"
+	SortByTools    = "tools"
+)
+
+// sessionSorts maps each sort key to the column of ListSessions' stats it
+// orders by.
+var sessionSorts = map[string]string{
+	SortByStart:    "started_at",
+	SortByEnd:      "ended_at",
+	SortByDuration: "duration",
+	SortByMessages: "messages",
+	SortByTools:    "tools",
+}
+
+// CheckSessionSort reports whether ListSessions accepts sortBy, so callers
+// can reject a bad sort key before querying.
+func CheckSessionSort(sortBy string) error {
+	if _, ok := sessionSorts[sortBy]; !ok {
+		return fmt.Errorf("unknown sort %q (want start, end, duration, messages or tools)", sortBy)
+	}
+	return nil
+}
+
+// ListSessions returns per-session statistics for every recorded session,
+// filtered by start time. A session starts at its creation or its first
+// message, whichever is earlier, and ends at its last message or event; the
+// filter, sort and limit all apply to those derived times.
+func (s *sqlStore) ListSessions(limit int, sortBy string, tf *model.TimeFilter) ([]model.SessionStats, error) {
+	if sortBy == "" {
+		sortBy = SortByStart
+	}
+	if err := CheckSessionSort(sortBy); err != nil {
+		return nil, err
+	}
+	orderBy := sessionSorts[sortBy]
+
+	params := []interface{}{}
+	timeClause, params := appendTimeClauses(tf, "started_at", false, params)
+	limitClause := ""
+	if limit > 0 {
+		limitClause = "LIMIT ?"
+		params = append(params, limit)
+	}
+
+	query := fmt.Sprintf(`
+		WITH ev AS (
+			SELECT session_id,
+			       MAX(timestamp) AS last_ts,
+			       COUNT(CASE WHEN event_type = 'PostToolUse' THEN 1 END) AS tools,
+			       group_concat(DISTINCT model) AS models
+			FROM events
+			GROUP BY session_id
+		), msg AS (
+			SELECT session_id,
+			       MIN(timestamp) AS first_ts,
+			       MAX(timestamp) AS last_ts,
+			       COUNT(CASE WHEN content IS NOT NULL AND content != '' THEN 1 END) AS n,
+			       group_concat(DISTINCT model) AS models
+			FROM messages
+			GROUP BY session_id
+		), ended AS (
+			SELECT session_id, reason,
+			       ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp DESC) AS rn
+			FROM events
+			WHERE event_type = 'SessionEnd'
+		), first_msg AS (
+			SELECT session_id, content,
+			       ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp ASC) AS rn
+			FROM messages
+			WHERE role = 'user' AND content IS NOT NULL AND content != ''
+		), first_prompt AS (
+			SELECT session_id, prompt,
+			       ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp ASC) AS rn
+			FROM events
+			WHERE event_type = 'UserPromptSubmit'
+		), started AS (
+			SELECT s.session_id, s.cwd,
+			       CASE WHEN msg.first_ts < s.created_at THEN msg.first_ts ELSE s.created_at END AS started_at,
+			       msg.last_ts AS msg_last, ev.last_ts AS ev_last,
+			       COALESCE(msg.n, 0) AS messages, COALESCE(ev.tools, 0) AS tools,
+			       msg.models AS msg_models, ev.models AS ev_models,
+			       ended.reason,
+			       COALESCE(first_msg.content, first_prompt.prompt) AS prompt
+			FROM sessions s
+			LEFT JOIN ev ON ev.session_id = s.session_id
+			LEFT JOIN msg ON msg.session_id = s.session_id
+			LEFT JOIN ended ON ended.session_id = s.session_id AND ended.rn = 1
+			LEFT JOIN first_msg ON first_msg.session_id = s.session_id AND first_msg.rn = 1
+			LEFT JOIN first_prompt ON first_prompt.session_id = s.session_id AND first_prompt.rn = 1
+		), by_msg AS (
+			SELECT *, CASE WHEN msg_last > started_at THEN msg_last ELSE started_at END AS msg_end
+			FROM started
+		), stats AS (
+			SELECT *, CASE WHEN ev_last > msg_end THEN ev_last ELSE msg_end END AS ended_at
+			FROM by_msg
+		)
+		SELECT session_id, cwd, started_at, ended_at, messages, tools,
+		       msg_models, ev_models, reason, prompt,
+		       %s - %s AS duration
+		FROM stats
+		%s
+		ORDER BY %s DESC, started_at DESC, session_id
+		%s
+	`, s.dialect.epoch("ended_at"), s.dialect.epoch("started_at"), timeClause, orderBy, limitClause)
+
+	rows, err := s.query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.SessionStats
+	for rows.Next() {
+		var r model.SessionStats
+		var started, ended nullTime
+		var msgModels, eventModels, reason, prompt sql.NullString
+		var duration float64
+		if err := rows.Scan(&r.SessionID, &r.CWD, &started, &ended,
+			&r.MessageCount, &r.ToolCount,
+			&msgModels, &eventModels, &reason, &prompt, &duration); err != nil {
+			return nil, err
+		}
+		r.StartedAt, r.EndedAt = started.Time, ended.Time
+		r.Models = mergeModels(msgModels.String, eventModels.String)
+		r.EndReason = reason.String
+		r.FirstPrompt = prompt.String
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// mergeModels combines comma-separated model lists into a sorted, unique slice.
+func mergeModels(lists ...string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, list := range lists {
+		for _, m := range strings.Split(list, ",") {
+			if m = strings.TrimSpace(m); m != "" && !seen[m] {
+				seen[m] = true
+				out = append(out, m)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ActiveSessions returns sessions that may still be writing to their
+// transcript, newest first. Sessions killed before SessionEnd fired drop
+// out once they have been quiet since the given time.
+func (s *sqlStore) ActiveSessions(since time.Time) ([]model.Session, error) {
+	rows, err := s.query(`
+		SELECT s.session_id, s.cwd, s.transcript_path, s.created_at
+		FROM sessions s
+		WHERE s.transcript_path IS NOT NULL AND s.transcript_path != ''
+		  AND NOT EXISTS (SELECT 1 FROM events e
+		                  WHERE e.session_id = s.session_id AND e.event_type = 'SessionEnd')
+		  AND (s.created_at >= ? OR EXISTS (SELECT 1 FROM events e
+		                                    WHERE e.session_id = s.session_id AND e.timestamp >= ?))
+		ORDER BY s.created_at DESC
+	`, since, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.Session
+	for rows.Next() {
+		var m model.Session
+		if err := rows.Scan(&m.ID, &m.CWD, &m.TranscriptPath, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
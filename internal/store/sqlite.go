@@ -0,0 +1,147 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is go-sqlite3 with clog's vector functions registered on
+// every connection.
+const sqliteDriver = "sqlite3_clog"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("cosine_similarity", cosineSimilarity, true)
+		},
+	})
+}
+
+// SQLite is a Store backed by a SQLite file. It needs no extensions:
+// embeddings are stored as little-endian float32 blobs (the sqlite-vec
+// layout) and compared by brute force with a Go cosine_similarity function.
+type SQLite struct {
+	sqlStore
+}
+
+// OpenSQLite creates a new SQLite store connected to the given file.
+func OpenSQLite(dbPath string) (*SQLite, error) {
+	db, err := sql.Open(sqliteDriver, dbPath+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %s: %w", dbPath, err)
+	}
+	return &SQLite{sqlStore{db: db, dialect: sqliteDialect}}, nil
+}
+
+// OpenSQLiteReadOnly opens an existing SQLite file with mode=ro.
+func OpenSQLiteReadOnly(dbPath string) (*SQLite, error) {
+	db, err := sql.Open(sqliteDriver, "file:"+dbPath+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %s: %w", dbPath, err)
+	}
+	return &SQLite{sqlStore{db: db, dialect: sqliteDialect}}, nil
+}
+
+var sqliteDialect = dialect{
+	// LIKE is case-insensitive for ASCII in SQLite.
+	ilike:      "LIKE",
+	similarity: "cosine_similarity",
+	strftime: func(col, format string) string {
+		return fmt.Sprintf("strftime('%s', %s)", format, col)
+	},
+	epoch: func(col string) string {
+		return fmt.Sprintf("(julianday(%s) - 2440587.5) * 86400.0", col)
+	},
+	vectorParam: func(dim int) string { return "?" },
+	vectorLen:   func(col string) string { return fmt.Sprintf("length(%s) / 4", col) },
+	tableExists: `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+	bind: func(arg interface{}) interface{} {
+		switch v := arg.(type) {
+		case time.Time:
+			// Timestamps are stored as text, so they must share a zone to compare.
+			return v.UTC()
+		case []float32:
+			return encodeVector(v)
+		}
+		return arg
+	},
+}
+
+// InitCoreSchema creates the base tables and indexes if they don't exist.
+func (s *SQLite) InitCoreSchema() error {
+	_, err := s.db.Exec(sqliteCoreSchema)
+	if err != nil {
+		return fmt.Errorf("init core schema: %w", err)
+	}
+	// Added after the first release; older files lack it.
+	if err := s.addColumn("messages", "agent_id", "TEXT"); err != nil {
+		return fmt.Errorf("init core schema: %w", err)
+	}
+	if err := s.replaceViews(); err != nil {
+		return err
+	}
+	return s.runMigrations()
+}
+
+// addColumn adds a column to table unless it already exists; SQLite has
+// no ADD COLUMN IF NOT EXISTS.
+func (s *SQLite) addColumn(table, column, typ string) error {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, typ)); err != nil {
+		return fmt.Errorf("add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// InitEmbeddingSchema creates the embeddings table. The dimension is not
+// enforced by SQLite; mismatched vectors fail at comparison time.
+func (s *SQLite) InitEmbeddingSchema(dimension int) error {
+	if _, err := s.db.Exec(sqliteEmbeddingSchema); err != nil {
+		return fmt.Errorf("create embedding table: %w", err)
+	}
+	return nil
+}
+
+// LoadVSS is a no-op: cosine_similarity is registered on every connection.
+func (s *SQLite) LoadVSS() error {
+	return nil
+}
+
+// encodeVector packs v as little-endian float32s.
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+// cosineSimilarity compares two vectors packed by encodeVector.
+func cosineSimilarity(a, b []byte) (float64, error) {
+	if len(a) != len(b) || len(a)%4 != 0 {
+		return 0, fmt.Errorf("cosine_similarity: vector size mismatch (%d vs %d bytes)", len(a), len(b))
+	}
+	var dot, na, nb float64
+	for i := 0; i < len(a); i += 4 {
+		x := float64(math.Float32frombits(binary.LittleEndian.Uint32(a[i:])))
+		y := float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i:])))
+		dot += x * y
+		na += x * x
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0, nil
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb)), nil
+}
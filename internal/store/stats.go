@@ -0,0 +1,168 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"clog/internal/model"
+)
+
+// UsageStats aggregates sessions, prompts and tool calls in the time range.
+// Session counts use session start; everything else uses event time. Days and
+// hours are UTC.
+func (s *sqlStore) UsageStats(tf *model.TimeFilter) (*model.UsageStats, error) {
+	var st model.UsageStats
+
+	days, err := s.sessionsPerDay(tf)
+	if err != nil {
+		return nil, fmt.Errorf("sessions per day: %w", err)
+	}
+	st.SessionsPerDay = days
+	for _, d := range days {
+		st.Sessions += d.Sessions
+	}
+
+	timeClause, params := appendTimeClauses(tf, "timestamp", true, nil)
+	if err := s.queryRow(`
+		SELECT COUNT(*) FROM events
+		WHERE event_type = 'UserPromptSubmit'
+	`+timeClause, params...).Scan(&st.Prompts); err != nil {
+		return nil, fmt.Errorf("count prompts: %w", err)
+	}
+	if st.Sessions > 0 {
+		st.AvgPromptsPerSession = float64(st.Prompts) / float64(st.Sessions)
+	}
+
+	if st.Tools, err = s.toolUsage(tf); err != nil {
+		return nil, fmt.Errorf("tool usage: %w", err)
+	}
+	for _, t := range st.Tools {
+		st.ToolCalls += t.Calls
+		st.ToolErrors += t.Errors
+	}
+
+	if st.Hours, err = s.busiestHours(tf); err != nil {
+		return nil, fmt.Errorf("busiest hours: %w", err)
+	}
+
+	if st.AvgSessionLength, err = s.avgSessionLength(tf); err != nil {
+		return nil, fmt.Errorf("session length: %w", err)
+	}
+
+	return &st, nil
+}
+
+func (s *sqlStore) sessionsPerDay(tf *model.TimeFilter) ([]model.DayCount, error) {
+	timeClause, params := appendTimeClauses(tf, "created_at", false, nil)
+	rows, err := s.query(fmt.Sprintf(`
+		SELECT %s AS day, COUNT(*)
+		FROM sessions
+		%s
+		GROUP BY day
+		ORDER BY day
+	`, s.dialect.strftime("created_at", "%Y-%m-%d"), timeClause), params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.DayCount
+	for rows.Next() {
+		var d model.DayCount
+		if err := rows.Scan(&d.Day, &d.Sessions); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// toolUsage counts completed and failed tool calls per tool, busiest first.
+// A call counts as an error when it reported an error or was interrupted.
+func (s *sqlStore) toolUsage(tf *model.TimeFilter) ([]model.ToolUsage, error) {
+	timeClause, params := appendTimeClauses(tf, "timestamp", true, nil)
+	rows, err := s.query(fmt.Sprintf(`
+		SELECT tool_name,
+		       COUNT(*),
+		       COUNT(CASE WHEN error IS NOT NULL OR is_interrupt THEN 1 END)
+		FROM events
+		WHERE event_type IN ('PostToolUse', 'PostToolUseFailure')
+		  AND tool_name IS NOT NULL
+		%s
+		GROUP BY tool_name
+		ORDER BY COUNT(*) DESC, tool_name
+	`, timeClause), params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ToolUsage
+	for rows.Next() {
+		var t model.ToolUsage
+		if err := rows.Scan(&t.Name, &t.Calls, &t.Errors); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// busiestHours counts events per UTC hour of day, busiest first.
+func (s *sqlStore) busiestHours(tf *model.TimeFilter) ([]model.HourCount, error) {
+	timeClause, params := appendTimeClauses(tf, "timestamp", false, nil)
+	rows, err := s.query(fmt.Sprintf(`
+		SELECT CAST(%s AS INTEGER) AS hour, COUNT(*)
+		FROM events
+		%s
+		GROUP BY hour
+		ORDER BY COUNT(*) DESC, hour
+	`, s.dialect.strftime("timestamp", "%H"), timeClause), params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.HourCount
+	for rows.Next() {
+		var h model.HourCount
+		if err := rows.Scan(&h.Hour, &h.Events); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// avgSessionLength averages the span between each session's first and last event.
+func (s *sqlStore) avgSessionLength(tf *model.TimeFilter) (time.Duration, error) {
+	timeClause, params := appendTimeClauses(tf, "timestamp", false, nil)
+	rows, err := s.query(fmt.Sprintf(`
+		SELECT MIN(timestamp), MAX(timestamp)
+		FROM events
+		%s
+		GROUP BY session_id
+	`, timeClause), params...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var total time.Duration
+	var n int
+	for rows.Next() {
+		var first, last nullTime
+		if err := rows.Scan(&first, &last); err != nil {
+			return 0, err
+		}
+		total += last.Time.Sub(first.Time)
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return total / time.Duration(n), nil
+}
@@ -1,72 +1,233 @@
-// Package store manages all DuckDB persistence operations.
+// Package store manages all database persistence operations.
 package store
 
 import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"os"
 	"strings"
 	"time"
 
 	"clog/internal/model"
+	"clog/internal/search"
+)
+
+// Store is the persistence API used by the CLI. DuckDB and SQLite
+// implementations are provided; see Open.
+type Store interface {
+	Close() error
+
+	// InitCoreSchema creates the base tables and indexes if they don't exist.
+	InitCoreSchema() error
+	// InitEmbeddingSchema prepares vector search and creates the embeddings table.
+	InitEmbeddingSchema(dimension int) error
+	// LoadVSS prepares the current connection for similarity search.
+	LoadVSS() error
+
+	UpsertSession(session model.Session) error
+	InsertEvent(e model.Event) error
+	InsertFileActivity(a model.FileActivity) error
+	// InsertBashExecution records a Bash call. A missing StartedAt is taken
+	// from the PreToolUse event with the same tool_use_id, if any.
+	InsertBashExecution(b model.BashExecution) error
+	SaveHarvestedMessages(messages []model.Message, transcriptPath string, newOffset int64) error
+	GetOffset(path string) (int64, error)
+
+	UnembeddedMessages(limit int) ([]model.StoredMessage, error)
+	SaveEmbedding(messageID int64, embedding []float32) error
+	SaveEmbeddings(messageIDs []int64, embeddings [][]float32) error
+	SaveChunkEmbeddings(chunks []model.MessageChunk, embeddings [][]float32) error
+	// RecordEmbeddingFailure marks a message the provider rejected, so it
+	// is no longer returned by UnembeddedMessages.
+	RecordEmbeddingFailure(messageID int64, reason string) error
+	// EmbeddingModel returns the model recorded as having made the stored
+	// vectors, or "" if none is. See CheckEmbeddingModel.
+	EmbeddingModel() (string, error)
+	SetEmbeddingModel(id string) error
+	// EmbeddingDimension returns the length of the stored vectors, or 0 if
+	// there are none.
+	EmbeddingDimension() (int, error)
+	// ResetEmbeddings drops every stored vector, the recorded failures and
+	// the recorded model, so another model can embed from scratch. The
+	// embedding schema must be initialised again afterwards.
+	ResetEmbeddings() error
+	// SearchSimilar and TextSearch return at most limit hits. With group,
+	// each session contributes only its best hit, whose Collapsed counts
+	// the session's other hits.
+	SearchSimilar(embedding []float32, q *search.Query, limit int, group bool, tf *model.TimeFilter, f *model.Filter) ([]model.SearchResult, error)
+	TextSearch(q *search.Query, limit int, group bool, tf *model.TimeFilter, f *model.Filter) ([]model.SearchResult, error)
+	ToolSearch(q *search.Query, limit int, tf *model.TimeFilter, f *model.Filter) ([]model.ToolResult, error)
+	SessionMessages(sessionID string, limit int) ([]model.StoredMessage, error)
+	MessageContext(messageID int64, n int) ([]model.ContextMessage, error)
+	ResolveSession(prefix string) (model.Session, error)
+	ListSessions(limit int, sortBy string, tf *model.TimeFilter) ([]model.SessionStats, error)
+	// ActiveSessions lists sessions with a transcript that have not ended
+	// and were started or saw a hook event since the given time.
+	ActiveSessions(since time.Time) ([]model.Session, error)
+	UsageStats(tf *model.TimeFilter) (*model.UsageStats, error)
+	// FileHistory lists activity on path, or on anything beneath it when
+	// path is a directory, newest first. "." matches every file.
+	FileHistory(path string, limit int, tf *model.TimeFilter) ([]model.FileActivity, error)
+	// BashHistory lists Bash calls whose command or output contains pattern
+	// ("" or "*" for all), newest first.
+	BashHistory(pattern string, failedOnly bool, limit int, tf *model.TimeFilter) ([]model.BashExecution, error)
+	SessionTimeline(sessionID string, limit, offset int) ([]model.TimelineEntry, error)
+	// Query runs an ad-hoc SQL query and returns at most limit rows (0 = all).
+	// Callers wanting a guarantee against writes should use OpenReadOnly.
+	Query(query string, limit int) (*model.QueryResult, error)
+
+	SaveSummary(sessionID, summary, modelName string) error
+	ListSummaries(limit int, tf *model.TimeFilter) ([]model.SummaryResult, error)
+	SessionSummary(sessionID string) (*model.SummaryResult, error)
+	// UnembeddedSummaries returns summaries that have no embedding, or whose
+	// embedding predates the summary's latest regeneration.
+	UnembeddedSummaries(limit int) ([]model.SummaryResult, error)
+	SaveSummaryEmbeddings(summaries []model.SummaryResult, embeddings [][]float32) error
+	// SearchSessions ranks sessions by summary similarity, filtering on
+	// the session start time.
+	SearchSessions(embedding []float32, limit int, tf *model.TimeFilter) ([]model.SessionMatch, error)
+}
 
-	_ "github.com/duckdb/duckdb-go/v2"
+// Backend names accepted by Open.
+const (
+	BackendDuckDB = "duckdb"
+	BackendSQLite = "sqlite"
 )
 
-// Store wraps a DuckDB connection and exposes domain-specific persistence.
-type Store struct {
-	db *sql.DB
+// Open connects to the database file using the named backend.
+// An empty backend selects DuckDB.
+func Open(backend, dbPath string) (Store, error) {
+	switch backend {
+	case "", BackendDuckDB:
+		return OpenDuckDB(dbPath)
+	case BackendSQLite:
+		return OpenSQLite(dbPath)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q (want %q or %q)", backend, BackendDuckDB, BackendSQLite)
+	}
 }
 
-// Open creates a new Store connected to the given DuckDB file.
-func Open(dbPath string) (*Store, error) {
-	db, err := sql.Open("duckdb", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("open duckdb %s: %w", dbPath, err)
+// OpenReadOnly connects to an existing database file without write access.
+func OpenReadOnly(backend, dbPath string) (Store, error) {
+	switch backend {
+	case "", BackendDuckDB:
+		return OpenDuckDBReadOnly(dbPath)
+	case BackendSQLite:
+		return OpenSQLiteReadOnly(dbPath)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q (want %q or %q)", backend, BackendDuckDB, BackendSQLite)
 	}
-	return &Store{db: db}, nil
+}
+
+// OpenProjectReadOnly opens an existing project database read-only, with
+// a helpful error if the project has none yet.
+func OpenProjectReadOnly(backend, dbPath string) (Store, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no database found at %s — run a Claude Code session in this project first", dbPath)
+	}
+	return OpenReadOnly(backend, dbPath)
+}
+
+// dialect captures the SQL differences between backends.
+type dialect struct {
+	// ilike is the case-insensitive pattern match operator.
+	ilike string
+	// similarity is the cosine similarity function over two vectors.
+	similarity string
+	// strftime formats a timestamp column with a strftime pattern.
+	strftime func(col, format string) string
+	// epoch converts a timestamp column to seconds since the Unix epoch.
+	epoch func(col string) string
+	// vectorParam is the placeholder for a []float32 argument of the given dimension.
+	vectorParam func(dim int) string
+	// vectorLen returns the number of floats in a vector column.
+	vectorLen func(col string) string
+	// tableExists counts the tables named by its one argument.
+	tableExists string
+	// bind normalises a query argument before it reaches the driver.
+	bind func(arg interface{}) interface{}
+}
+
+// sqlStore implements the backend-neutral parts of Store over database/sql.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
 }
 
 // Close releases the database connection.
-func (s *Store) Close() error {
+func (s *sqlStore) Close() error {
 	return s.db.Close()
 }
 
-// InitCoreSchema creates the base tables and indexes if they don't exist.
-func (s *Store) InitCoreSchema() error {
-	_, err := s.db.Exec(coreSchema)
-	if err != nil {
-		return fmt.Errorf("init core schema: %w", err)
+func (s *sqlStore) args(args []interface{}) []interface{} {
+	if s.dialect.bind == nil {
+		return args
+	}
+	out := make([]interface{}, len(args))
+	for i, a := range args {
+		out[i] = s.dialect.bind(a)
 	}
-	return nil
+	return out
+}
+
+func (s *sqlStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(query, s.args(args)...)
+}
+
+func (s *sqlStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(query, s.args(args)...)
 }
 
-// InitEmbeddingSchema installs the vss extension and creates the embeddings table.
-func (s *Store) InitEmbeddingSchema(dimension int) error {
-	if _, err := s.db.Exec("INSTALL vss"); err != nil {
-		return fmt.Errorf("install vss extension: %w", err)
+func (s *sqlStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRow(query, s.args(args)...)
+}
+
+// migrate runs a one-off data migration in a transaction, unless the
+// store has already recorded it. Claiming the name first makes a
+// concurrent run fail rather than apply the migration twice.
+func (s *sqlStore) migrate(name string, fn func(tx *sql.Tx) error) error {
+	var done int
+	if err := s.queryRow(`SELECT COUNT(*) FROM migrations WHERE name = ?`, name).Scan(&done); err != nil {
+		return fmt.Errorf("migrate %s: %w", name, err)
 	}
-	if _, err := s.db.Exec("LOAD vss"); err != nil {
-		return fmt.Errorf("load vss extension: %w", err)
+	if done > 0 {
+		return nil
 	}
-	if _, err := s.db.Exec(embeddingSchema(dimension)); err != nil {
-		return fmt.Errorf("create embedding table: %w", err)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", name, err)
 	}
-	return nil
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO migrations (name, applied_at) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		s.args([]interface{}{name, time.Now()})...)
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", name, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return fmt.Errorf("migrate %s: %w", name, err)
+	}
+	return tx.Commit()
 }
 
-// LoadVSS loads the vss extension for the current connection (required for search).
-func (s *Store) LoadVSS() error {
-	_, err := s.db.Exec("LOAD vss")
-	return err
+// runMigrations applies the one-off data migrations, oldest first.
+func (s *sqlStore) runMigrations() error {
+	if err := s.migrate("file_activity_backfill", s.backfillFileActivity); err != nil {
+		return err
+	}
+	return s.migrate("bash_executions_from_events", s.backfillBashExecutions)
 }
 
 // --- Session operations ---
 
 // UpsertSession inserts or updates a session record.
-func (s *Store) UpsertSession(session model.Session) error {
-	_, err := s.db.Exec(`
+func (s *sqlStore) UpsertSession(session model.Session) error {
+	_, err := s.exec(`
 		INSERT INTO sessions (session_id, cwd, transcript_path, created_at)
 		VALUES (?, ?, ?, ?)
 		ON CONFLICT (session_id) DO UPDATE SET transcript_path = excluded.transcript_path
@@ -77,8 +238,8 @@ func (s *Store) UpsertSession(session model.Session) error {
 // --- Event operations ---
 
 // InsertEvent persists a hook event.
-func (s *Store) InsertEvent(e model.Event) error {
-	_, err := s.db.Exec(`
+func (s *sqlStore) InsertEvent(e model.Event) error {
+	_, err := s.exec(`
 		INSERT INTO events (
 			session_id, event_type, timestamp, permission_mode,
 			source, model, agent_type, prompt,
@@ -110,7 +271,7 @@ func (s *Store) InsertEvent(e model.Event) error {
 // --- Message operations ---
 
 // SaveHarvestedMessages inserts messages and updates the transcript offset atomically.
-func (s *Store) SaveHarvestedMessages(messages []model.Message, transcriptPath string, newOffset int64) error {
+func (s *sqlStore) SaveHarvestedMessages(messages []model.Message, transcriptPath string, newOffset int64) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
@@ -118,8 +279,8 @@ func (s *Store) SaveHarvestedMessages(messages []model.Message, transcriptPath s
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO messages (session_id, uuid, parent_uuid, role, content, raw_content, model, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO messages (session_id, uuid, parent_uuid, role, content, raw_content, model, timestamp, agent_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT (uuid) DO NOTHING
 	`)
 	if err != nil {
@@ -128,7 +289,7 @@ func (s *Store) SaveHarvestedMessages(messages []model.Message, transcriptPath s
 	defer stmt.Close()
 
 	for _, m := range messages {
-		if _, err := stmt.Exec(
+		if _, err := stmt.Exec(s.args([]interface{}{
 			m.SessionID,
 			nullStr(m.UUID),
 			nullStr(m.ParentUUID),
@@ -137,7 +298,8 @@ func (s *Store) SaveHarvestedMessages(messages []model.Message, transcriptPath s
 			nullStr(m.RawContent),
 			nullStr(m.Model),
 			m.Timestamp,
-		); err != nil {
+			nullStr(m.AgentID),
+		})...); err != nil {
 			return fmt.Errorf("insert message %s: %w", m.UUID, err)
 		}
 	}
@@ -154,9 +316,9 @@ func (s *Store) SaveHarvestedMessages(messages []model.Message, transcriptPath s
 }
 
 // GetOffset returns the last read offset for a transcript file.
-func (s *Store) GetOffset(path string) (int64, error) {
+func (s *sqlStore) GetOffset(path string) (int64, error) {
 	var offset int64
-	err := s.db.QueryRow(
+	err := s.queryRow(
 		`SELECT last_offset FROM transcript_offsets WHERE transcript_path = ?`, path,
 	).Scan(&offset)
 	if err == sql.ErrNoRows {
@@ -168,14 +330,16 @@ func (s *Store) GetOffset(path string) (int64, error) {
 // --- Embedding operations ---
 
 // UnembeddedMessages returns messages that lack embeddings.
-func (s *Store) UnembeddedMessages(limit int) ([]model.StoredMessage, error) {
-	rows, err := s.db.Query(`
+func (s *sqlStore) UnembeddedMessages(limit int) ([]model.StoredMessage, error) {
+	rows, err := s.query(`
 		SELECT m.id, m.session_id, m.role, m.content, m.timestamp
 		FROM messages m
 		LEFT JOIN message_embeddings e ON m.id = e.message_id
 		WHERE e.message_id IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM chunk_embeddings c WHERE c.message_id = m.id)
+		  AND NOT EXISTS (SELECT 1 FROM embedding_failures f WHERE f.message_id = m.id)
 		  AND m.content IS NOT NULL
-		  AND m.content != ''
+		  AND TRIM(m.content) != ''
 		ORDER BY m.id
 		LIMIT ?
 	`, limit)
@@ -196,34 +360,84 @@ func (s *Store) UnembeddedMessages(limit int) ([]model.StoredMessage, error) {
 }
 
 // SaveEmbedding persists a single message embedding.
-func (s *Store) SaveEmbedding(messageID int64, embedding []float32) error {
-	query := fmt.Sprintf(
-		`INSERT INTO message_embeddings (message_id, embedding) VALUES (?, %s::FLOAT[%d])
-		 ON CONFLICT DO NOTHING`,
-		formatFloatArray(embedding), len(embedding),
-	)
-	_, err := s.db.Exec(query, messageID)
-	return err
+func (s *sqlStore) SaveEmbedding(messageID int64, embedding []float32) error {
+	return s.SaveEmbeddings([]int64{messageID}, [][]float32{embedding})
 }
 
-// SearchSimilar finds the top-k messages most similar to the given embedding.
-func (s *Store) SearchSimilar(embedding []float32, limit int, tf *model.TimeFilter) ([]model.SearchResult, error) {
-	params := []interface{}{}
-	timeClause, params := appendTimeClauses(tf, "m.timestamp", false, params)
+// SaveEmbeddings persists a batch of message embeddings in one transaction,
+// so a batch is either fully stored or not at all.
+func (s *sqlStore) SaveEmbeddings(messageIDs []int64, embeddings [][]float32) error {
+	if len(messageIDs) != len(embeddings) {
+		return fmt.Errorf("save embeddings: %d ids for %d embeddings", len(messageIDs), len(embeddings))
+	}
+	if len(embeddings) == 0 {
+		return nil
+	}
 
-	query := fmt.Sprintf(`
-		SELECT m.id, m.session_id, m.role, m.content,
-		       array_cosine_similarity(e.embedding, %s::FLOAT[%d]) AS score,
-		       m.timestamp
-		FROM messages m
-		JOIN message_embeddings e ON m.id = e.message_id
-		%s
-		ORDER BY score DESC
-		LIMIT ?
-	`, formatFloatArray(embedding), len(embedding), timeClause)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO message_embeddings (message_id, embedding) VALUES (?, %s)
+		ON CONFLICT DO NOTHING
+	`, s.dialect.vectorParam(len(embeddings[0]))))
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
 
+	for i, id := range messageIDs {
+		if _, err := stmt.Exec(s.args([]interface{}{id, embeddings[i]})...); err != nil {
+			return fmt.Errorf("insert embedding for message %d: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SearchSimilar finds the top-k messages most similar to the given
+// embedding. A message scores as its best chunk, whose offsets are
+// returned as the match; messages embedded whole match entirely. q, if
+// set, restricts the candidates before ranking.
+func (s *sqlStore) SearchSimilar(embedding []float32, q *search.Query, limit int, group bool, tf *model.TimeFilter, f *model.Filter) ([]model.SearchResult, error) {
+	vec := s.dialect.vectorParam(len(embedding))
+	w := &where{}
+	w.add("b.rank = 1")
+	w.timeRange(tf, "m.timestamp")
+	if err := w.filter(f, messageColumns, s.dialect); err != nil {
+		return nil, err
+	}
+	if err := w.search(q, messageColumns, s.dialect); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		WITH hits AS (
+			SELECT message_id, start_offset, end_offset, %[1]s(embedding, %[2]s) AS score
+			FROM chunk_embeddings
+			UNION ALL
+			SELECT message_id, 0, 0, %[1]s(embedding, %[2]s)
+			FROM message_embeddings
+		), best AS (
+			SELECT message_id, start_offset, end_offset, score,
+			       ROW_NUMBER() OVER (PARTITION BY message_id ORDER BY score DESC) AS rank
+			FROM hits
+		), matched AS (
+			SELECT m.id, m.session_id, m.role, m.content, b.score, m.timestamp,
+			       b.start_offset, b.end_offset
+			FROM best b
+			JOIN messages m ON m.id = b.message_id
+			%[3]s
+		)
+		%[4]s
+	`, s.dialect.similarity, vec, w, rankHits("score DESC, id", group))
+
+	params := append([]interface{}{embedding, embedding}, w.params...)
 	params = append(params, limit)
-	rows, err := s.db.Query(query, params...)
+	rows, err := s.query(query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -232,7 +446,8 @@ func (s *Store) SearchSimilar(embedding []float32, limit int, tf *model.TimeFilt
 	var out []model.SearchResult
 	for rows.Next() {
 		var r model.SearchResult
-		if err := rows.Scan(&r.ID, &r.SessionID, &r.Role, &r.Content, &r.Score, &r.Timestamp); err != nil {
+		if err := rows.Scan(&r.ID, &r.SessionID, &r.Role, &r.Content, &r.Score, &r.Timestamp,
+			&r.MatchStart, &r.MatchEnd, &r.Collapsed); err != nil {
 			return nil, err
 		}
 		out = append(out, r)
@@ -240,22 +455,34 @@ func (s *Store) SearchSimilar(embedding []float32, limit int, tf *model.TimeFilt
 	return out, rows.Err()
 }
 
-// TextSearch performs a case-insensitive text search across messages.
-func (s *Store) TextSearch(pattern string, limit int, tf *model.TimeFilter) ([]model.SearchResult, error) {
-	params := []interface{}{"%"+pattern+"%"}
-	timeClause, params := appendTimeClauses(tf, "m.timestamp", true, params)
+// TextSearch returns messages matching q, newest first. Words and phrases
+// match case-insensitive substrings of the content.
+func (s *sqlStore) TextSearch(q *search.Query, limit int, group bool, tf *model.TimeFilter, f *model.Filter) ([]model.SearchResult, error) {
+	w := &where{}
+	w.timeRange(tf, "m.timestamp")
+	if err := w.filter(f, messageColumns, s.dialect); err != nil {
+		return nil, err
+	}
+	if err := w.search(q, messageColumns, s.dialect); err != nil {
+		return nil, err
+	}
+	// Text terms only match text, but a query of filters alone would
+	// otherwise return tool-only turns with nothing to show.
+	w.add("m.content IS NOT NULL AND m.content != ''")
 
 	query := fmt.Sprintf(`
-		SELECT m.id, m.session_id, m.role, m.content, 0.0 AS score, m.timestamp
-		FROM messages m
-		WHERE m.content ILIKE ?
+		WITH matched AS (
+			SELECT m.id, m.session_id, m.role, m.content, 0.0 AS score, m.timestamp,
+			       0 AS start_offset, 0 AS end_offset
+			FROM messages m
+			%s
+		)
 		%s
-		ORDER BY m.timestamp DESC
-		LIMIT ?
-	`, timeClause)
+	`, w, rankHits("timestamp DESC, id DESC", group))
 
+	params := w.params
 	params = append(params, limit)
-	rows, err := s.db.Query(query, params...)
+	rows, err := s.query(query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +491,8 @@ func (s *Store) TextSearch(pattern string, limit int, tf *model.TimeFilter) ([]m
 	var out []model.SearchResult
 	for rows.Next() {
 		var r model.SearchResult
-		if err := rows.Scan(&r.ID, &r.SessionID, &r.Role, &r.Content, &r.Score, &r.Timestamp); err != nil {
+		if err := rows.Scan(&r.ID, &r.SessionID, &r.Role, &r.Content, &r.Score, &r.Timestamp,
+			&r.MatchStart, &r.MatchEnd, &r.Collapsed); err != nil {
 			return nil, err
 		}
 		out = append(out, r)
@@ -272,45 +500,56 @@ func (s *Store) TextSearch(pattern string, limit int, tf *model.TimeFilter) ([]m
 	return out, rows.Err()
 }
 
+// rankHits selects the hits of a query's matched CTE in the given order,
+// up to a LIMIT parameter. With group, only each session's first hit in
+// that order is kept, and collapsed counts the session's other hits, so
+// the limit applies to sessions.
+func rankHits(order string, group bool) string {
+	const cols = "id, session_id, role, content, score, timestamp, start_offset, end_offset"
+	if !group {
+		return fmt.Sprintf(`SELECT %s, 0 AS collapsed FROM matched ORDER BY %s LIMIT ?`, cols, order)
+	}
+	return fmt.Sprintf(`
+		SELECT %[1]s, collapsed
+		FROM (
+			SELECT %[1]s,
+			       ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY %[2]s) AS session_rank,
+			       COUNT(*) OVER (PARTITION BY session_id) - 1 AS collapsed
+			FROM matched
+		) g
+		WHERE session_rank = 1
+		ORDER BY %[2]s
+		LIMIT ?`, cols, order)
+}
+
 // --- Tool search ---
 
-// ToolSearch queries PostToolUse events, optionally filtered by tool name.
-func (s *Store) ToolSearch(toolName string, limit int, tf *model.TimeFilter) ([]model.ToolResult, error) {
-	var query string
-	var params []interface{}
-
-	if toolName == "" || toolName == "*" {
-		params = []interface{}{}
-		timeClause, p := appendTimeClauses(tf, "timestamp", true, params)
-		params = p
-		query = fmt.Sprintf(`
-			SELECT session_id, tool_name, CAST(tool_input AS VARCHAR),
-			       CAST(tool_response AS VARCHAR), timestamp
-			FROM events
-			WHERE event_type = 'PostToolUse'
-			  AND tool_name IS NOT NULL
-			%s
-			ORDER BY timestamp DESC
-			LIMIT ?
-		`, timeClause)
-	} else {
-		params = []interface{}{toolName}
-		timeClause, p := appendTimeClauses(tf, "timestamp", true, params)
-		params = p
-		query = fmt.Sprintf(`
-			SELECT session_id, tool_name, CAST(tool_input AS VARCHAR),
-			       CAST(tool_response AS VARCHAR), timestamp
-			FROM events
-			WHERE event_type = 'PostToolUse'
-			  AND tool_name ILIKE '%%' || ? || '%%'
-			%s
-			ORDER BY timestamp DESC
-			LIMIT ?
-		`, timeClause)
+// ToolSearch returns PostToolUse events matching q, newest first. Words
+// and phrases match the tool name, input or response.
+func (s *sqlStore) ToolSearch(q *search.Query, limit int, tf *model.TimeFilter, f *model.Filter) ([]model.ToolResult, error) {
+	w := &where{}
+	w.add("event_type = 'PostToolUse'")
+	w.add("tool_name IS NOT NULL")
+	w.timeRange(tf, "timestamp")
+	if err := w.filter(f, eventColumns, s.dialect); err != nil {
+		return nil, err
+	}
+	if err := w.search(q, eventColumns, s.dialect); err != nil {
+		return nil, err
 	}
 
+	query := fmt.Sprintf(`
+		SELECT session_id, tool_name, CAST(tool_input AS VARCHAR),
+		       CAST(tool_response AS VARCHAR), timestamp
+		FROM events
+		%s
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`, w)
+
+	params := w.params
 	params = append(params, limit)
-	rows, err := s.db.Query(query, params...)
+	rows, err := s.query(query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -337,8 +576,8 @@ func (s *Store) ToolSearch(toolName string, limit int, tf *model.TimeFilter) ([]
 // --- Session message retrieval ---
 
 // SessionMessages returns messages for a session in chronological order.
-func (s *Store) SessionMessages(sessionID string, limit int) ([]model.StoredMessage, error) {
-	rows, err := s.db.Query(`
+func (s *sqlStore) SessionMessages(sessionID string, limit int) ([]model.StoredMessage, error) {
+	rows, err := s.query(`
 		SELECT id, session_id, role, content, timestamp
 		FROM messages
 		WHERE session_id = ? AND content IS NOT NULL AND content != ''
@@ -361,11 +600,98 @@ func (s *Store) SessionMessages(sessionID string, limit int) ([]model.StoredMess
 	return out, rows.Err()
 }
 
+// ResolveSession finds the single session whose id starts with prefix.
+func (s *sqlStore) ResolveSession(prefix string) (model.Session, error) {
+	if prefix == "" {
+		return model.Session{}, fmt.Errorf("empty session prefix")
+	}
+
+	rows, err := s.query(`
+		SELECT session_id, cwd, transcript_path, created_at
+		FROM sessions
+		WHERE substr(session_id, 1, ?) = ?
+		ORDER BY created_at DESC
+		LIMIT 6
+	`, len(prefix), prefix)
+	if err != nil {
+		return model.Session{}, err
+	}
+	defer rows.Close()
+
+	var matches []model.Session
+	for rows.Next() {
+		var m model.Session
+		var transcript sql.NullString
+		if err := rows.Scan(&m.ID, &m.CWD, &transcript, &m.CreatedAt); err != nil {
+			return model.Session{}, err
+		}
+		m.TranscriptPath = transcript.String
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Session{}, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return model.Session{}, fmt.Errorf("no session matches %q", prefix)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	return model.Session{}, fmt.Errorf("session prefix %q is ambiguous: %s", prefix, strings.Join(ids, ", "))
+}
+
+// SessionTimeline returns a session's messages and tool calls interleaved in
+// chronological order, skipping offset entries.
+func (s *sqlStore) SessionTimeline(sessionID string, limit, offset int) ([]model.TimelineEntry, error) {
+	rows, err := s.query(`
+		SELECT kind, role, content, tool_name, tool_input, tool_response, timestamp
+		FROM (
+			SELECT 'message' AS kind, role, content,
+			       CAST(NULL AS VARCHAR) AS tool_name,
+			       CAST(NULL AS VARCHAR) AS tool_input,
+			       CAST(NULL AS VARCHAR) AS tool_response,
+			       timestamp, 0 AS ord
+			FROM messages
+			WHERE session_id = ? AND content IS NOT NULL AND content != ''
+			UNION ALL
+			SELECT 'tool', CAST(NULL AS VARCHAR), CAST(NULL AS VARCHAR), tool_name,
+			       CAST(tool_input AS VARCHAR), CAST(tool_response AS VARCHAR),
+			       timestamp, 1
+			FROM events
+			WHERE session_id = ? AND event_type = 'PostToolUse'
+		) t
+		ORDER BY timestamp ASC, ord ASC
+		LIMIT ? OFFSET ?
+	`, sessionID, sessionID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.TimelineEntry
+	for rows.Next() {
+		var e model.TimelineEntry
+		var role, content, toolName, toolInput, toolResponse sql.NullString
+		if err := rows.Scan(&e.Kind, &role, &content, &toolName, &toolInput, &toolResponse, &e.Timestamp); err != nil {
+			return nil, err
+		}
+		e.Role, e.Content = role.String, content.String
+		e.ToolName, e.ToolInput, e.ToolResponse = toolName.String, toolInput.String, toolResponse.String
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
 // --- Summary operations ---
 
 // SaveSummary persists or updates a session summary.
-func (s *Store) SaveSummary(sessionID, summary, modelName string) error {
-	_, err := s.db.Exec(`
+func (s *sqlStore) SaveSummary(sessionID, summary, modelName string) error {
+	_, err := s.exec(`
 		INSERT INTO session_summaries (session_id, summary, model, generated_at)
 		VALUES (?, ?, ?, ?)
 		ON CONFLICT (session_id) DO UPDATE
@@ -375,7 +701,7 @@ func (s *Store) SaveSummary(sessionID, summary, modelName string) error {
 }
 
 // ListSummaries returns session summaries ordered by generation time.
-func (s *Store) ListSummaries(limit int, tf *model.TimeFilter) ([]model.SummaryResult, error) {
+func (s *sqlStore) ListSummaries(limit int, tf *model.TimeFilter) ([]model.SummaryResult, error) {
 	params := []interface{}{}
 	timeClause, params := appendTimeClauses(tf, "ss.generated_at", false, params)
 
@@ -389,7 +715,7 @@ func (s *Store) ListSummaries(limit int, tf *model.TimeFilter) ([]model.SummaryR
 	`, timeClause)
 
 	params = append(params, limit)
-	rows, err := s.db.Query(query, params...)
+	rows, err := s.query(query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -406,6 +732,26 @@ func (s *Store) ListSummaries(limit int, tf *model.TimeFilter) ([]model.SummaryR
 	return out, rows.Err()
 }
 
+// SessionSummary returns the summary for a session, or nil if none exists.
+func (s *sqlStore) SessionSummary(sessionID string) (*model.SummaryResult, error) {
+	var r model.SummaryResult
+	var modelName sql.NullString
+	err := s.queryRow(`
+		SELECT ss.session_id, ss.summary, ss.model, ss.generated_at, s.cwd
+		FROM session_summaries ss
+		JOIN sessions s ON ss.session_id = s.session_id
+		WHERE ss.session_id = ?
+	`, sessionID).Scan(&r.SessionID, &r.Summary, &modelName, &r.GeneratedAt, &r.CWD)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Model = modelName.String
+	return &r, nil
+}
+
 // --- helpers ---
 
 // appendTimeClauses builds SQL fragments for time filtering.
@@ -441,6 +787,42 @@ func appendTimeClauses(tf *model.TimeFilter, tsCol string, hasWhere bool, params
 	return sb.String(), params
 }
 
+// nullTime scans a nullable timestamp. SQLite returns computed timestamp
+// columns (MIN, MAX, COALESCE) as text, so strings are parsed too.
+type nullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+var textTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+func (t *nullTime) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		for _, layout := range textTimeLayouts {
+			if parsed, err := time.Parse(layout, v); err == nil {
+				t.Time, t.Valid = parsed.UTC(), true
+				return nil
+			}
+		}
+		return fmt.Errorf("unrecognised timestamp %q", v)
+	}
+	return fmt.Errorf("cannot scan %T into timestamp", v)
+}
+
 func nullStr(s string) interface{} {
 	if s == "" {
 		return nil
@@ -454,16 +836,3 @@ func rawJSON(r json.RawMessage) interface{} {
 	}
 	return string(r)
 }
-
-func formatFloatArray(v []float32) string {
-	var b strings.Builder
-	b.WriteByte('[')
-	for i, f := range v {
-		if i > 0 {
-			b.WriteByte(',')
-		}
-		b.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
-	}
-	b.WriteByte(']')
-	return b.String()
-}
@@ -114,11 +114,11 @@ func TestAppendTimeClauses_WhenFilterHasNoFields_ShouldReturnEmpty(t *testing.T)
 // --- Integration tests with DuckDB ---
 
 // openTestStore creates an in-memory DuckDB store with core schema initialized.
-func openTestStore(t *testing.T) *Store {
+func openTestStore(t *testing.T) *DuckDB {
 	t.Helper()
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.duckdb")
-	st, err := Open(dbPath)
+	st, err := OpenDuckDB(dbPath)
 	if err != nil {
 		t.Fatalf("open test store: %v", err)
 	}
@@ -130,7 +130,7 @@ func openTestStore(t *testing.T) *Store {
 }
 
 // seedMessages inserts messages at specific timestamps for testing time filters.
-func seedMessages(t *testing.T, st *Store) {
+func seedMessages(t *testing.T, st Store) {
 	t.Helper()
 	session := model.Session{
 		ID:        "test-session",
@@ -152,7 +152,7 @@ func seedMessages(t *testing.T, st *Store) {
 }
 
 // seedEvents inserts PostToolUse events at specific timestamps.
-func seedEvents(t *testing.T, st *Store) {
+func seedEvents(t *testing.T, st Store) {
 	t.Helper()
 
 	toolName := func(s string) *string { return &s }
@@ -193,7 +193,7 @@ func TestTextSearch_WhenNoTimeFilter_ShouldReturnAllMatches(t *testing.T) {
 	st := openTestStore(t)
 	seedMessages(t, st)
 
-	results, err := st.TextSearch("auth", 10, nil)
+	results, err := st.TextSearch(mustParse(t, "auth"), 10, false, nil, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -209,7 +209,7 @@ func TestTextSearch_WhenSinceFilterSet_ShouldExcludeOlderMessages(t *testing.T)
 	since := time.Now().Add(-6 * time.Hour)
 	tf := &model.TimeFilter{Since: &since}
 
-	results, err := st.TextSearch("auth", 10, tf)
+	results, err := st.TextSearch(mustParse(t, "auth"), 10, false, tf, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -228,7 +228,7 @@ func TestTextSearch_WhenUntilFilterSet_ShouldExcludeNewerMessages(t *testing.T)
 	until := time.Now().Add(-6 * time.Hour)
 	tf := &model.TimeFilter{Until: &until}
 
-	results, err := st.TextSearch("auth", 10, tf)
+	results, err := st.TextSearch(mustParse(t, "auth"), 10, false, tf, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -248,7 +248,7 @@ func TestTextSearch_WhenBothSinceAndUntilSet_ShouldReturnMessagesInRange(t *test
 	until := time.Now().Add(-30 * time.Minute)
 	tf := &model.TimeFilter{Since: &since, Until: &until}
 
-	results, err := st.TextSearch("message", 10, tf)
+	results, err := st.TextSearch(mustParse(t, "message"), 10, false, tf, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -268,7 +268,7 @@ func TestTextSearch_WhenTimeRangeExcludesAll_ShouldReturnNoResults(t *testing.T)
 	until := time.Now().Add(-99 * time.Hour)
 	tf := &model.TimeFilter{Since: &since, Until: &until}
 
-	results, err := st.TextSearch("auth", 10, tf)
+	results, err := st.TextSearch(mustParse(t, "auth"), 10, false, tf, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -284,7 +284,7 @@ func TestToolSearch_WhenNoTimeFilter_ShouldReturnAllEvents(t *testing.T) {
 	seedMessages(t, st)
 	seedEvents(t, st)
 
-	results, err := st.ToolSearch("*", 10, nil)
+	results, err := st.ToolSearch(mustParse(t, "*"), 10, nil, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -301,7 +301,7 @@ func TestToolSearch_WhenSinceFilterSet_ShouldExcludeOlderEvents(t *testing.T) {
 	since := time.Now().Add(-6 * time.Hour)
 	tf := &model.TimeFilter{Since: &since}
 
-	results, err := st.ToolSearch("*", 10, tf)
+	results, err := st.ToolSearch(mustParse(t, "*"), 10, tf, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -318,7 +318,7 @@ func TestToolSearch_WhenFilteredByToolNameWithTimeFilter_ShouldApplyBothFilters(
 	since := time.Now().Add(-6 * time.Hour)
 	tf := &model.TimeFilter{Since: &since}
 
-	results, err := st.ToolSearch("Bash", 10, tf)
+	results, err := st.ToolSearch(mustParse(t, "Bash"), 10, tf, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -335,7 +335,7 @@ func TestToolSearch_WhenUntilFilterSet_ShouldExcludeNewerEvents(t *testing.T) {
 	until := time.Now().Add(-6 * time.Hour)
 	tf := &model.TimeFilter{Until: &until}
 
-	results, err := st.ToolSearch("*", 10, tf)
+	results, err := st.ToolSearch(mustParse(t, "*"), 10, tf, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -353,7 +353,7 @@ func TestToolSearch_WhenTimeRangeExcludesAll_ShouldReturnNoResults(t *testing.T)
 	until := time.Now().Add(-199 * time.Hour)
 	tf := &model.TimeFilter{Since: &since, Until: &until}
 
-	results, err := st.ToolSearch("*", 10, tf)
+	results, err := st.ToolSearch(mustParse(t, "*"), 10, tf, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -367,7 +367,7 @@ func TestToolSearch_WhenTimeRangeExcludesAll_ShouldReturnNoResults(t *testing.T)
 func TestOpen_WhenGivenValidPath_ShouldReturnStore(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.duckdb")
-	st, err := Open(dbPath)
+	st, err := Open(BackendDuckDB, dbPath)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -385,6 +385,23 @@ func TestInitCoreSchema_WhenCalledTwice_ShouldBeIdempotent(t *testing.T) {
 	}
 }
 
+func TestInitCoreSchema_WhenViewIsStale_ShouldReplaceIt(t *testing.T) {
+	st := openTestStore(t)
+	if _, err := st.db.Exec("DROP VIEW prompts; CREATE VIEW prompts AS SELECT 1 AS stale"); err != nil {
+		t.Fatalf("replace view: %v", err)
+	}
+	if err := st.InitCoreSchema(); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	res, err := st.Query("SELECT * FROM prompts", 0)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(res.Columns) == 0 || res.Columns[len(res.Columns)-1] != "prompt" {
+		t.Errorf("expected the current prompts view, got columns %v", res.Columns)
+	}
+}
+
 // --- UpsertSession ---
 
 func TestUpsertSession_WhenGivenNewSession_ShouldInsertIt(t *testing.T) {
@@ -612,7 +629,7 @@ func TestTextSearch_WhenPatternMatchesSubstring_ShouldReturnResults(t *testing.T
 		{SessionID: "sess-1", UUID: "m3", Role: "user", Content: "deploy to production", Timestamp: time.Now()},
 	}, "/test.jsonl", 999)
 
-	results, err := st.TextSearch("auth", 10, nil)
+	results, err := st.TextSearch(mustParse(t, "auth"), 10, false, nil, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -628,7 +645,7 @@ func TestTextSearch_WhenPatternIsCaseInsensitive_ShouldMatchRegardlessOfCase(t *
 		{SessionID: "sess-1", UUID: "m1", Role: "user", Content: "Hello World", Timestamp: time.Now()},
 	}, "/test.jsonl", 999)
 
-	results, err := st.TextSearch("hello", 10, nil)
+	results, err := st.TextSearch(mustParse(t, "hello"), 10, false, nil, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -644,7 +661,7 @@ func TestTextSearch_WhenNoMatches_ShouldReturnEmptySlice(t *testing.T) {
 		{SessionID: "sess-1", UUID: "m1", Role: "user", Content: "hello", Timestamp: time.Now()},
 	}, "/test.jsonl", 999)
 
-	results, err := st.TextSearch("zzzznotfound", 10, nil)
+	results, err := st.TextSearch(mustParse(t, "zzzznotfound"), 10, false, nil, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -668,7 +685,7 @@ func TestTextSearch_ShouldRespectLimit(t *testing.T) {
 	}
 	st.SaveHarvestedMessages(msgs, "/test.jsonl", 999)
 
-	results, err := st.TextSearch("matching", 3, nil)
+	results, err := st.TextSearch(mustParse(t, "matching"), 3, false, nil, nil)
 	if err != nil {
 		t.Fatalf("text search: %v", err)
 	}
@@ -684,7 +701,7 @@ func TestToolSearch_WhenWildcard_ShouldReturnAllToolEvents(t *testing.T) {
 	st.UpsertSession(model.Session{ID: "sess-1", CWD: "/tmp", CreatedAt: time.Now()})
 	seedEvents(t, st)
 
-	results, err := st.ToolSearch("*", 10, nil)
+	results, err := st.ToolSearch(mustParse(t, "*"), 10, nil, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -698,7 +715,7 @@ func TestToolSearch_WhenFilteredByName_ShouldReturnOnlyMatching(t *testing.T) {
 	st.UpsertSession(model.Session{ID: "sess-1", CWD: "/tmp", CreatedAt: time.Now()})
 	seedEvents(t, st)
 
-	results, err := st.ToolSearch("Read", 10, nil)
+	results, err := st.ToolSearch(mustParse(t, "Read"), 10, nil, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -715,7 +732,7 @@ func TestToolSearch_WhenEmptyPattern_ShouldReturnAll(t *testing.T) {
 	st.UpsertSession(model.Session{ID: "sess-1", CWD: "/tmp", CreatedAt: time.Now()})
 	seedEvents(t, st)
 
-	results, err := st.ToolSearch("", 10, nil)
+	results, err := st.ToolSearch(mustParse(t, ""), 10, nil, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -739,7 +756,7 @@ func TestToolSearch_ShouldIncludeToolInputAndResponse(t *testing.T) {
 	}
 	st.InsertEvent(event)
 
-	results, err := st.ToolSearch("Bash", 10, nil)
+	results, err := st.ToolSearch(mustParse(t, "Bash"), 10, nil, nil)
 	if err != nil {
 		t.Fatalf("tool search: %v", err)
 	}
@@ -1012,6 +1029,91 @@ func TestListSummaries_WhenUntilFilterSet_ShouldExcludeNewerSummaries(t *testing
 	}
 }
 
+// --- SaveEmbeddings / SearchSimilar ---
+
+// initTestEmbeddings creates the embeddings table directly, skipping the vss
+// install (which needs network access); array_cosine_similarity is built in.
+func initTestEmbeddings(t *testing.T, st *DuckDB, dimension int) {
+	t.Helper()
+	if _, err := st.db.Exec(embeddingSchema(dimension)); err != nil {
+		t.Fatalf("create embedding table: %v", err)
+	}
+}
+
+func TestSaveEmbeddings_WhenGivenBatch_ShouldPersistAllVectors(t *testing.T) {
+	st := openTestStore(t)
+	seedMessages(t, st)
+	initTestEmbeddings(t, st, 3)
+
+	pending, _ := st.UnembeddedMessages(10)
+	ids := []int64{pending[0].ID, pending[1].ID, pending[2].ID}
+	vecs := [][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	if err := st.SaveEmbeddings(ids, vecs); err != nil {
+		t.Fatalf("save embeddings: %v", err)
+	}
+
+	var count int
+	st.db.QueryRow("SELECT COUNT(*) FROM message_embeddings").Scan(&count)
+	if count != 3 {
+		t.Errorf("expected 3 embeddings, got %d", count)
+	}
+
+	results, err := st.SearchSimilar([]float32{0, 1, 0}, nil, 1, false, nil, nil)
+	if err != nil {
+		t.Fatalf("search similar: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != ids[1] {
+		t.Errorf("expected message %d as best match, got %+v", ids[1], results)
+	}
+}
+
+func TestSaveEmbeddings_WhenBatchFails_ShouldRollBackWholeBatch(t *testing.T) {
+	st := openTestStore(t)
+	seedMessages(t, st)
+	initTestEmbeddings(t, st, 3)
+
+	pending, _ := st.UnembeddedMessages(10)
+	ids := []int64{pending[0].ID, pending[1].ID}
+	vecs := [][]float32{{1, 0, 0}, {1, 0}} // second vector has the wrong dimension
+	if err := st.SaveEmbeddings(ids, vecs); err == nil {
+		t.Fatal("expected error for mismatched dimension")
+	}
+
+	var count int
+	st.db.QueryRow("SELECT COUNT(*) FROM message_embeddings").Scan(&count)
+	if count != 0 {
+		t.Errorf("expected no embeddings after rollback, got %d", count)
+	}
+}
+
+func TestSaveEmbeddings_WhenLengthsDiffer_ShouldReturnError(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.SaveEmbeddings([]int64{1, 2}, [][]float32{{1}}); err == nil {
+		t.Fatal("expected error for mismatched ids and embeddings")
+	}
+}
+
+func TestSaveEmbeddings_WhenAlreadyEmbedded_ShouldIgnoreDuplicate(t *testing.T) {
+	st := openTestStore(t)
+	seedMessages(t, st)
+	initTestEmbeddings(t, st, 2)
+
+	pending, _ := st.UnembeddedMessages(1)
+	id := pending[0].ID
+	if err := st.SaveEmbedding(id, []float32{1, 0}); err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	if err := st.SaveEmbeddings([]int64{id}, [][]float32{{0, 1}}); err != nil {
+		t.Fatalf("duplicate save: %v", err)
+	}
+
+	var count int
+	st.db.QueryRow("SELECT COUNT(*) FROM message_embeddings").Scan(&count)
+	if count != 1 {
+		t.Errorf("expected 1 embedding, got %d", count)
+	}
+}
+
 // --- helpers ---
 
 func TestNullStr_WhenGivenEmptyString_ShouldReturnNil(t *testing.T) {
@@ -1042,24 +1144,38 @@ func TestRawJSON_WhenGivenData_ShouldReturnString(t *testing.T) {
 	}
 }
 
-func TestFormatFloatArray_WhenGivenValues_ShouldReturnBracketedString(t *testing.T) {
-	got := formatFloatArray([]float32{0.1, 0.2, 0.3})
-	if got != "[0.1,0.2,0.3]" {
-		t.Errorf("expected '[0.1,0.2,0.3]', got %q", got)
+func TestNullTime_WhenGivenSQLiteText_ShouldParseAsUTC(t *testing.T) {
+	var nt nullTime
+	if err := nt.Scan("2024-03-01 10:30:00.5+00:00"); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	want := time.Date(2024, 3, 1, 10, 30, 0, 500000000, time.UTC)
+	if !nt.Valid || !nt.Time.Equal(want) {
+		t.Errorf("expected %v, got %v (valid=%v)", want, nt.Time, nt.Valid)
+	}
+}
+
+func TestNullTime_WhenGivenNil_ShouldBeInvalid(t *testing.T) {
+	nt := nullTime{Valid: true}
+	if err := nt.Scan(nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if nt.Valid {
+		t.Error("expected invalid time for NULL")
 	}
 }
 
-func TestFormatFloatArray_WhenGivenSingleValue_ShouldReturnSingleElement(t *testing.T) {
-	got := formatFloatArray([]float32{1.5})
-	if got != "[1.5]" {
-		t.Errorf("expected '[1.5]', got %q", got)
+func TestNullTime_WhenGivenGarbage_ShouldReturnError(t *testing.T) {
+	var nt nullTime
+	if err := nt.Scan("yesterday"); err == nil {
+		t.Error("expected error for unparseable timestamp")
 	}
 }
 
-func TestFormatFloatArray_WhenGivenEmptySlice_ShouldReturnEmptyBrackets(t *testing.T) {
-	got := formatFloatArray([]float32{})
-	if got != "[]" {
-		t.Errorf("expected '[]', got %q", got)
+func TestMergeModels_ShouldDedupeAndSort(t *testing.T) {
+	got := mergeModels("b,a", "", "a, c")
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("expected [a b c], got %v", got)
 	}
 }
 
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"fmt"
+
+	"clog/internal/model"
+)
+
+// UnembeddedSummaries returns summaries lacking an up-to-date embedding.
+func (s *sqlStore) UnembeddedSummaries(limit int) ([]model.SummaryResult, error) {
+	rows, err := s.query(`
+		SELECT ss.session_id, ss.summary, ss.generated_at
+		FROM session_summaries ss
+		LEFT JOIN summary_embeddings se ON se.session_id = ss.session_id
+		WHERE se.session_id IS NULL OR se.generated_at < ss.generated_at
+		ORDER BY ss.generated_at
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.SummaryResult
+	for rows.Next() {
+		var r model.SummaryResult
+		if err := rows.Scan(&r.SessionID, &r.Summary, &r.GeneratedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// SaveSummaryEmbeddings stores one embedding per summary in a single
+// transaction, replacing embeddings of earlier versions of the summary.
+func (s *sqlStore) SaveSummaryEmbeddings(summaries []model.SummaryResult, embeddings [][]float32) error {
+	if len(summaries) != len(embeddings) {
+		return fmt.Errorf("save summary embeddings: %d summaries for %d embeddings", len(summaries), len(embeddings))
+	}
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	del, err := tx.Prepare(`DELETE FROM summary_embeddings WHERE session_id = ?`)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer del.Close()
+	ins, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO summary_embeddings (session_id, generated_at, embedding) VALUES (?, ?, %s)
+	`, s.dialect.vectorParam(len(embeddings[0]))))
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer ins.Close()
+
+	for i, r := range summaries {
+		if _, err := del.Exec(r.SessionID); err != nil {
+			return fmt.Errorf("replace embedding for session %s: %w", r.SessionID, err)
+		}
+		if _, err := ins.Exec(s.args([]interface{}{r.SessionID, r.GeneratedAt, embeddings[i]})...); err != nil {
+			return fmt.Errorf("insert embedding for session %s: %w", r.SessionID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SearchSessions ranks sessions by the similarity of their summaries.
+func (s *sqlStore) SearchSessions(embedding []float32, limit int, tf *model.TimeFilter) ([]model.SessionMatch, error) {
+	params := []interface{}{embedding}
+	timeClause, params := appendTimeClauses(tf, "s.created_at", false, params)
+
+	query := fmt.Sprintf(`
+		SELECT ss.session_id, ss.summary, %s(se.embedding, %s) AS score, s.created_at,
+		       (SELECT COUNT(*) FROM messages m WHERE m.session_id = ss.session_id)
+		FROM summary_embeddings se
+		JOIN session_summaries ss ON ss.session_id = se.session_id
+		JOIN sessions s ON s.session_id = se.session_id
+		%s
+		ORDER BY score DESC
+		LIMIT ?
+	`, s.dialect.similarity, s.dialect.vectorParam(len(embedding)), timeClause)
+
+	params = append(params, limit)
+	rows, err := s.query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.SessionMatch
+	for rows.Next() {
+		var r model.SessionMatch
+		if err := rows.Scan(&r.SessionID, &r.Summary, &r.Score, &r.StartedAt, &r.MessageCount); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
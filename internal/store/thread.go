@@ -0,0 +1,143 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"clog/internal/model"
+)
+
+// threadRow is a message with the links needed to rebuild its thread.
+type threadRow struct {
+	msg    model.ContextMessage
+	uuid   string
+	parent string
+}
+
+// MessageContext returns the hit message with up to n messages before and
+// after it in the same thread, oldest first. The thread follows parent_uuid
+// links rather than timestamps, so interleaved sidechains are excluded.
+// Messages without text are walked through but not counted or returned.
+//
+// Only the session's messages nearest the hit are loaded. The span is
+// doubled while a walk reaches its edge short of n messages, so long
+// sessions aren't read whole for every hit.
+func (s *sqlStore) MessageContext(messageID int64, n int) ([]model.ContextMessage, error) {
+	for span := 4*n + 16; ; span *= 2 {
+		before, err := s.threadRows(messageID, span+1, true)
+		if err != nil {
+			return nil, err
+		}
+		after, err := s.threadRows(messageID, span, false)
+		if err != nil {
+			return nil, err
+		}
+		moreBefore, moreAfter := len(before) > span, len(after) == span
+
+		thread := make([]threadRow, 0, len(before)+len(after))
+		for i := len(before) - 1; i >= 0; i-- {
+			thread = append(thread, before[i])
+		}
+		thread = append(thread, after...)
+		out := threadWindow(thread, messageID, n)
+
+		hit := 0
+		for hit < len(out) && !out[hit].Hit {
+			hit++
+		}
+		if (hit < n && moreBefore) || (len(out)-1-hit < n && moreAfter) {
+			continue
+		}
+		for i := range out {
+			out[i].HitID = messageID
+		}
+		return out, nil
+	}
+}
+
+// threadRows loads up to limit messages of the hit's session nearest to it:
+// the hit and those before it, newest first, or those after it, oldest
+// first.
+func (s *sqlStore) threadRows(messageID int64, limit int, before bool) ([]threadRow, error) {
+	cond, order := `m.timestamp > h.timestamp OR (m.timestamp = h.timestamp AND m.id > h.id)`, "ASC"
+	if before {
+		cond, order = `m.timestamp < h.timestamp OR (m.timestamp = h.timestamp AND m.id <= h.id)`, "DESC"
+	}
+	rows, err := s.query(fmt.Sprintf(`
+		SELECT m.id, m.uuid, m.parent_uuid, m.role, m.content, m.timestamp
+		FROM messages m
+		JOIN (SELECT session_id, timestamp, id FROM messages WHERE id = ?) h
+		  ON m.session_id = h.session_id
+		WHERE %s
+		ORDER BY m.timestamp %s, m.id %[2]s
+		LIMIT ?
+	`, cond, order), messageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []threadRow
+	for rows.Next() {
+		var r threadRow
+		var uuid, parent, content sql.NullString
+		if err := rows.Scan(&r.msg.ID, &uuid, &parent, &r.msg.Role, &content, &r.msg.Timestamp); err != nil {
+			return nil, err
+		}
+		r.uuid, r.parent, r.msg.Content = uuid.String, parent.String, content.String
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// threadWindow walks up to n text messages up the parent chain and down the
+// first-child chain from the hit. rows must be in chronological order.
+func threadWindow(rows []threadRow, hitID int64, n int) []model.ContextMessage {
+	byUUID := make(map[string]int, len(rows))
+	firstChild := make(map[string]int)
+	hit := -1
+	for i, r := range rows {
+		if r.uuid != "" {
+			byUUID[r.uuid] = i
+		}
+		if r.parent != "" {
+			if _, ok := firstChild[r.parent]; !ok {
+				firstChild[r.parent] = i
+			}
+		}
+		if r.msg.ID == hitID {
+			hit = i
+		}
+	}
+	if hit < 0 {
+		return nil
+	}
+
+	// Guard against cycles in malformed transcripts.
+	seen := map[int]bool{hit: true}
+
+	var before []model.ContextMessage
+	for i, ok := byUUID[rows[hit].parent]; ok && len(before) < n && !seen[i]; i, ok = byUUID[rows[i].parent] {
+		seen[i] = true
+		if rows[i].msg.Content != "" {
+			before = append(before, rows[i].msg)
+		}
+	}
+
+	var after []model.ContextMessage
+	for i, ok := firstChild[rows[hit].uuid]; ok && len(after) < n && !seen[i]; i, ok = firstChild[rows[i].uuid] {
+		seen[i] = true
+		if rows[i].msg.Content != "" {
+			after = append(after, rows[i].msg)
+		}
+	}
+
+	out := make([]model.ContextMessage, 0, len(before)+1+len(after))
+	for i := len(before) - 1; i >= 0; i-- {
+		out = append(out, before[i])
+	}
+	h := rows[hit].msg
+	h.Hit = true
+	out = append(out, h)
+	return append(out, after...)
+}
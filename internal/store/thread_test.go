@@ -0,0 +1,113 @@
+package store
+
+import (
+	"testing"
+
+	"clog/internal/model"
+)
+
+func row(id int64, uuid, parent, content string) threadRow {
+	return threadRow{msg: model.ContextMessage{ID: id, Content: content}, uuid: uuid, parent: parent}
+}
+
+func contents(msgs []model.ContextMessage) []string {
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.Content
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestThreadWindow_WhenLinearThread_ShouldReturnNeighboursAroundHit(t *testing.T) {
+	rows := []threadRow{
+		row(1, "a", "", "one"),
+		row(2, "b", "a", "two"),
+		row(3, "c", "b", "three"),
+		row(4, "d", "c", "four"),
+		row(5, "e", "d", "five"),
+	}
+
+	got := threadWindow(rows, 3, 1)
+
+	if want := []string{"two", "three", "four"}; !equalStrings(contents(got), want) {
+		t.Errorf("expected %v, got %v", want, contents(got))
+	}
+	if !got[1].Hit || got[0].Hit || got[2].Hit {
+		t.Errorf("expected only the middle message marked as hit, got %+v", got)
+	}
+}
+
+func TestThreadWindow_WhenMessagesHaveNoText_ShouldSkipWithoutCounting(t *testing.T) {
+	rows := []threadRow{
+		row(1, "a", "", "question"),
+		row(2, "b", "a", ""), // tool_use only
+		row(3, "c", "b", ""), // tool_result only
+		row(4, "d", "c", "answer"),
+	}
+
+	got := threadWindow(rows, 4, 1)
+
+	if want := []string{"question", "answer"}; !equalStrings(contents(got), want) {
+		t.Errorf("expected %v, got %v", want, contents(got))
+	}
+}
+
+func TestThreadWindow_WhenTimestampsInterleaveAnotherBranch_ShouldFollowParentLinks(t *testing.T) {
+	rows := []threadRow{
+		row(1, "a", "", "root"),
+		row(2, "x", "", "other branch"),
+		row(3, "b", "a", "reply"),
+		row(4, "y", "x", "other reply"),
+	}
+
+	got := threadWindow(rows, 3, 2)
+
+	if want := []string{"root", "reply"}; !equalStrings(contents(got), want) {
+		t.Errorf("expected %v, got %v", want, contents(got))
+	}
+}
+
+func TestThreadWindow_WhenBranched_ShouldFollowFirstChild(t *testing.T) {
+	rows := []threadRow{
+		row(1, "a", "", "root"),
+		row(2, "b", "a", "first"),
+		row(3, "c", "a", "retry"),
+	}
+
+	got := threadWindow(rows, 1, 1)
+
+	if want := []string{"root", "first"}; !equalStrings(contents(got), want) {
+		t.Errorf("expected %v, got %v", want, contents(got))
+	}
+}
+
+func TestThreadWindow_WhenParentLinksCycle_ShouldTerminate(t *testing.T) {
+	rows := []threadRow{
+		row(1, "a", "b", "one"),
+		row(2, "b", "a", "two"),
+	}
+
+	got := threadWindow(rows, 1, 5)
+
+	if len(got) != 2 {
+		t.Errorf("expected 2 messages, got %v", contents(got))
+	}
+}
+
+func TestThreadWindow_WhenHitMissing_ShouldReturnNil(t *testing.T) {
+	if got := threadWindow([]threadRow{row(1, "a", "", "one")}, 99, 2); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
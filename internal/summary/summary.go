@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"clog/internal/model"
 )
@@ -90,7 +91,11 @@ func (s *Summarizer) Summarize(messages []model.StoredMessage) (string, error) {
 	if resp.StatusCode != http.StatusOK {
 		preview := string(body)
 		if len(preview) > 200 {
-			preview = preview[:200] + "..."
+			cut := 200
+			for cut > 0 && !utf8.RuneStart(preview[cut]) {
+				cut--
+			}
+			preview = preview[:cut] + "..."
 		}
 		return "", fmt.Errorf("chat API returned %d: %s", resp.StatusCode, preview)
 	}
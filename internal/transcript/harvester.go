@@ -21,6 +21,8 @@ type transcriptLine struct {
 	Message *messagePayload `json:"message"`
 	// Some lines use a top-level timestamp.
 	Timestamp string `json:"timestamp"`
+	// Subagent lines carry the id of the agent that produced them.
+	AgentID string `json:"agentId"`
 }
 
 type messagePayload struct {
@@ -44,57 +46,61 @@ func Harvest(sessionID, transcriptPath string, fromOffset int64) (*model.Harvest
 		}
 	}
 
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024) // 10 MB max line
-
+	r := bufio.NewReaderSize(f, 1024*1024)
+	offset := fromOffset
 	now := time.Now().UTC()
 	var messages []model.Message
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
-
-		var tl transcriptLine
-		if err := json.Unmarshal(line, &tl); err != nil {
-			continue
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("read transcript: %w", err)
 		}
-		if tl.Message == nil {
-			continue
+		last := err == io.EOF
+		// A final line without a newline may still be being written. Leave
+		// it for the next harvest unless it is already a whole JSON value.
+		if last && (len(line) == 0 || !json.Valid(line)) {
+			break
 		}
+		offset += int64(len(line))
 
-		role := tl.Message.Role
-		if role != "user" && role != "assistant" {
-			continue
+		if msg, ok := parseLine(sessionID, line, now); ok {
+			messages = append(messages, msg)
+		}
+		if last {
+			break
 		}
-
-		ts := parseTimestamp(tl.Timestamp, now)
-
-		messages = append(messages, model.Message{
-			SessionID:  sessionID,
-			UUID:       tl.UUID,
-			ParentUUID: tl.Parent,
-			Role:       role,
-			Content:    extractText(tl.Message.Content),
-			RawContent: string(tl.Message.Content),
-			Model:      tl.Message.Model,
-			Timestamp:  ts,
-		})
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scan transcript: %w", err)
 	}
 
-	newOffset, _ := f.Seek(0, io.SeekCurrent)
-
 	return &model.HarvestResult{
 		Messages:  messages,
-		NewOffset: newOffset,
+		NewOffset: offset,
 	}, nil
 }
 
+// parseLine returns the user or assistant message on a transcript line.
+func parseLine(sessionID string, line []byte, now time.Time) (model.Message, bool) {
+	var tl transcriptLine
+	if err := json.Unmarshal(line, &tl); err != nil || tl.Message == nil {
+		return model.Message{}, false
+	}
+	role := tl.Message.Role
+	if role != "user" && role != "assistant" {
+		return model.Message{}, false
+	}
+	return model.Message{
+		SessionID:  sessionID,
+		UUID:       tl.UUID,
+		ParentUUID: tl.Parent,
+		Role:       role,
+		Content:    extractText(tl.Message.Content),
+		RawContent: string(tl.Message.Content),
+		Model:      tl.Message.Model,
+		AgentID:    tl.AgentID,
+		Timestamp:  parseTimestamp(tl.Timestamp, now),
+	}, true
+}
+
 // extractText pulls human-readable text from a message's content field.
 // User messages have a plain string; assistant messages have an array of blocks.
 func extractText(raw json.RawMessage) string {
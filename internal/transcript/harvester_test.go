@@ -172,6 +172,21 @@ func TestHarvest_WhenGivenAssistantMessage_ShouldExtractTextBlocks(t *testing.T)
 	}
 }
 
+func TestHarvest_WhenGivenSidechainMessage_ShouldRecordAgentID(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTranscript(t, dir,
+		`{"type":"assistant","uuid":"a1","isSidechain":true,"agentId":"agent-7","message":{"role":"assistant","content":"found it"}}`,
+	)
+
+	result, err := Harvest("sess-1", path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Messages) != 1 || result.Messages[0].AgentID != "agent-7" {
+		t.Errorf("expected agent id 'agent-7', got %+v", result.Messages)
+	}
+}
+
 func TestHarvest_WhenGivenSystemMessage_ShouldSkipIt(t *testing.T) {
 	dir := t.TempDir()
 	path := writeTranscript(t, dir,
@@ -336,6 +351,52 @@ func TestHarvest_WhenResumedAndNewDataAppended_ShouldReturnOnlyNewMessages(t *te
 	}
 }
 
+func TestHarvest_WhenLastLineIsPartial_ShouldLeaveItForNextHarvest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "transcript.jsonl")
+	first := `{"type":"message","uuid":"u1","message":{"role":"user","content":"first"}}` + "\n"
+	second := `{"type":"message","uuid":"u2","message":{"role":"user","content":"second"}}`
+	if err := os.WriteFile(path, []byte(first+second[:20]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result1, err := Harvest("sess-1", path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result1.Messages) != 1 || result1.NewOffset != int64(len(first)) {
+		t.Fatalf("expected 1 message and offset %d, got %d at %d", len(first), len(result1.Messages), result1.NewOffset)
+	}
+
+	if err := os.WriteFile(path, []byte(first+second+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result2, err := Harvest("sess-1", path, result1.NewOffset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result2.Messages) != 1 || result2.Messages[0].Content != "second" {
+		t.Fatalf("expected the completed line harvested, got %+v", result2.Messages)
+	}
+}
+
+func TestHarvest_WhenLastLineIsCompleteWithoutNewline_ShouldReadIt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "transcript.jsonl")
+	line := `{"type":"message","uuid":"u1","message":{"role":"user","content":"only"}}`
+	if err := os.WriteFile(path, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Harvest("sess-1", path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Messages) != 1 || result.NewOffset != int64(len(line)) {
+		t.Errorf("expected 1 message and offset %d, got %d at %d", len(line), len(result.Messages), result.NewOffset)
+	}
+}
+
 func TestHarvest_ShouldPreserveUUIDAndParentUUID(t *testing.T) {
 	dir := t.TempDir()
 	path := writeTranscript(t, dir,
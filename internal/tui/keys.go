@@ -0,0 +1,102 @@
+package tui
+
+import "unicode/utf8"
+
+// keyCode names a key that isn't a printable rune.
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyEnter
+	keyTab
+	keyBacktab
+	keyBackspace
+	keyEsc
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+	keyCtrlC
+	keyCtrlT
+	keyCtrlU
+)
+
+// key is one keypress; r is set for keyRune.
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// escapes maps the VT sequences terminals send after ESC.
+var escapes = map[string]keyCode{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[4~": keyEnd, "[7~": keyHome, "[8~": keyEnd,
+	"[5~": keyPgUp, "[6~": keyPgDn, "[Z": keyBacktab,
+}
+
+// decodeKeys splits input read from a raw-mode terminal into keys. A read
+// holds whole escape sequences in practice, so a lone ESC is the Esc key.
+// Unknown sequences are dropped.
+func decodeKeys(b []byte) []key {
+	var out []key
+	for len(b) > 0 {
+		c := b[0]
+		switch {
+		case c == 0x1b:
+			n, code := escape(b[1:])
+			if n == 0 {
+				out = append(out, key{code: keyEsc})
+			} else if code != keyRune {
+				out = append(out, key{code: code})
+			}
+			b = b[1+n:]
+			continue
+		case c == '\r' || c == '\n':
+			out = append(out, key{code: keyEnter})
+		case c == '\t':
+			out = append(out, key{code: keyTab})
+		case c == 0x7f || c == 0x08:
+			out = append(out, key{code: keyBackspace})
+		case c == 0x03:
+			out = append(out, key{code: keyCtrlC})
+		case c == 0x14:
+			out = append(out, key{code: keyCtrlT})
+		case c == 0x15:
+			out = append(out, key{code: keyCtrlU})
+		case c < 0x20:
+			// Other control keys are unbound.
+		default:
+			r, size := utf8.DecodeRune(b)
+			out = append(out, key{code: keyRune, r: r})
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return out
+}
+
+// escape reads the sequence after an ESC, returning its length and key.
+// A length of 0 means there was no sequence.
+func escape(b []byte) (int, keyCode) {
+	if len(b) < 2 || (b[0] != '[' && b[0] != 'O') {
+		return 0, keyRune
+	}
+	// CSI sequences end at the first byte in @..~; SS3 ones are 2 bytes.
+	n := 2
+	if b[0] == '[' {
+		for n = 1; n < len(b) && (b[n] < 0x40 || b[n] > 0x7e); n++ {
+		}
+		if n == len(b) {
+			return len(b), keyRune
+		}
+		n++
+	}
+	return n, escapes[string(b[:n])]
+}
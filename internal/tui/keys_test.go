@@ -0,0 +1,26 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeKeys_WhenGivenSequences_ShouldSplitIntoKeys(t *testing.T) {
+	got := decodeKeys([]byte("aé\x1b[A\x1bOB\x1b[5~\r\t\x7f\x03\x1b"))
+	want := []key{
+		{code: keyRune, r: 'a'}, {code: keyRune, r: 'é'},
+		{code: keyUp}, {code: keyDown}, {code: keyPgUp},
+		{code: keyEnter}, {code: keyTab}, {code: keyBackspace}, {code: keyCtrlC}, {code: keyEsc},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestDecodeKeys_WhenSequenceUnknown_ShouldDropIt(t *testing.T) {
+	got := decodeKeys([]byte("\x1b[1;5Cx\x1b[200~"))
+	want := []key{{code: keyRune, r: 'x'}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"clog/internal/config"
+	"clog/internal/embedding"
+	"clog/internal/model"
+	"clog/internal/search"
+	"clog/internal/store"
+)
+
+// debounce is how long typing must pause before a search runs.
+const debounce = 150 * time.Millisecond
+
+// storeSource reads a project's store, opening it read-only for each query
+// so hooks can keep writing while the UI is open.
+type storeSource struct {
+	dir string
+
+	mu sync.Mutex
+	// emb is created on the first semantic search.
+	emb embedding.Embedder
+}
+
+func (s *storeSource) open() (store.Store, error) {
+	cfg := config.Default()
+	return store.OpenProjectReadOnly(cfg.Backend, cfg.DBPath(s.dir))
+}
+
+func (s *storeSource) Sessions() ([]model.SessionStats, error) {
+	st, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	return st.ListSessions(500, "start", nil)
+}
+
+func (s *storeSource) Search(query string, semantic bool) ([]model.SearchResult, error) {
+	q, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	if !semantic {
+		return st.TextSearch(q, 200, false, nil, nil)
+	}
+
+	text := q.Text()
+	if text == "" {
+		return nil, fmt.Errorf("semantic search needs some plain words to embed")
+	}
+	s.mu.Lock()
+	if s.emb == nil {
+		s.emb, err = embedding.NewFromEnv()
+	}
+	emb := s.emb
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CheckEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension()); err != nil {
+		return nil, err
+	}
+	if err := st.LoadVSS(); err != nil {
+		return nil, fmt.Errorf("load vss: %w", err)
+	}
+	vecs, err := emb.Embed([]string{text})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	return st.SearchSimilar(vecs[0], q.Filters(), 50, false, nil, nil)
+}
+
+func (s *storeSource) Timeline(sessionID string) ([]model.TimelineEntry, error) {
+	st, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	return st.SessionTimeline(sessionID, 5000, 0)
+}
+
+// searchDone carries a finished search back to the UI loop.
+type searchDone struct {
+	query   string
+	results []model.SearchResult
+	err     error
+}
+
+// Run browses the project in dir until the user quits. Stdin and stdout
+// must be a terminal.
+func Run(dir string) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return fmt.Errorf("tui needs a terminal")
+	}
+	src := &storeSource{dir: dir}
+	if _, err := src.Sessions(); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return fmt.Errorf("raw mode: %w", err)
+	}
+	// Alternate screen, so the shell's scrollback is left as it was.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(in, state)
+	}()
+
+	a := newApp(src, func(text string) { fmt.Print(osc52(text)) })
+
+	keys := make(chan []key)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- decodeKeys(buf[:n])
+		}
+	}()
+
+	results := make(chan searchDone, 1)
+	var timer <-chan time.Time
+	// Redraw periodically to follow terminal resizes.
+	tick := time.NewTicker(500 * time.Millisecond)
+	defer tick.Stop()
+
+	for !a.quit {
+		draw(a, out)
+		select {
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range ks {
+				a.handle(k)
+			}
+			if a.searchDue {
+				timer = time.After(debounce)
+			}
+		case <-timer:
+			timer = nil
+			a.searchDue, a.inFlight = false, true
+			query, semantic := a.query, a.semantic
+			go func() {
+				r, err := src.Search(query, semantic)
+				results <- searchDone{query, r, err}
+			}()
+		case r := <-results:
+			a.setResults(r.query, r.results, r.err)
+		case <-tick.C:
+		}
+	}
+	return nil
+}
+
+// draw repaints the whole screen.
+func draw(a *app, fd int) {
+	width, height, err := term.GetSize(fd)
+	if err != nil || width < 20 || height < 5 {
+		width, height = 80, 24
+	}
+	lines, cursor := a.render(width, height)
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+	}
+	if a.focus == paneSearch {
+		fmt.Fprintf(&b, "\x1b[1;%dH\x1b[?25h", cursor)
+	} else {
+		b.WriteString("\x1b[?25l")
+	}
+	os.Stdout.WriteString(b.String())
+}
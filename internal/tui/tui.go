@@ -0,0 +1,527 @@
+// Package tui is an interactive terminal browser for a project's history:
+// a search box whose results update as you type, a list of sessions or
+// hits, and the selected conversation with its tool calls.
+package tui
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"clog/internal/model"
+	"clog/internal/search"
+	"clog/internal/snippet"
+)
+
+// Source supplies what the UI shows.
+type Source interface {
+	Sessions() ([]model.SessionStats, error)
+	// Search runs a text search, or a semantic one.
+	Search(query string, semantic bool) ([]model.SearchResult, error)
+	Timeline(sessionID string) ([]model.TimelineEntry, error)
+}
+
+// pane is the part of the screen receiving keys.
+type pane int
+
+const (
+	paneSearch pane = iota
+	paneList
+	paneConversation
+)
+
+// help is shown on the bottom line.
+const help = "tab pane · ↑↓ move · enter open · t tool output · y copy · ctrl-t text/semantic · esc clear · ctrl-c quit"
+
+// maxToolLines caps a tool response shown with tool output on.
+const maxToolLines = 15
+
+// app is the UI state. Keys change it through handle; render draws it.
+type app struct {
+	src Source
+
+	query    string
+	semantic bool
+	focus    pane
+	// searchDue is set when the query changed and a search should run;
+	// inFlight while one is running.
+	searchDue, inFlight bool
+
+	sessions []model.SessionStats
+	results  []model.SearchResult
+	// terms are the query's words, for excerpts.
+	terms []string
+	// list and listTop are the selected row and first visible row.
+	list, listTop int
+
+	// session is the conversation shown, if any.
+	session string
+	entries []model.TimelineEntry
+	// entry is the selected entry; convTop the first visible line.
+	entry, convTop int
+	// follow scrolls the selected entry into view on the next render.
+	follow    bool
+	showTools bool
+
+	status string
+	quit   bool
+	// copy puts text on the clipboard.
+	copy func(text string)
+	// height is the number of rows the panes get, kept from the last render.
+	height int
+}
+
+func newApp(src Source, copy func(string)) *app {
+	a := &app{src: src, copy: copy, focus: paneSearch, height: 20}
+	a.loadSessions()
+	return a
+}
+
+func (a *app) loadSessions() {
+	sessions, err := a.src.Sessions()
+	if err != nil {
+		a.status = err.Error()
+		return
+	}
+	a.sessions = sessions
+}
+
+// searching reports whether the list shows search results.
+func (a *app) searching() bool {
+	return strings.TrimSpace(a.query) != ""
+}
+
+func (a *app) listLen() int {
+	if a.searching() {
+		return len(a.results)
+	}
+	return len(a.sessions)
+}
+
+// setResults shows the results of a search for query, unless the query
+// has changed since.
+func (a *app) setResults(query string, results []model.SearchResult, err error) {
+	if query != a.query {
+		return
+	}
+	a.inFlight = false
+	if err != nil {
+		a.status = err.Error()
+		a.results = nil
+	} else {
+		a.status = fmt.Sprintf("%d hits", len(results))
+		a.results = results
+	}
+	a.list, a.listTop = 0, 0
+	if q, err := search.Parse(query); err == nil {
+		a.terms = q.Highlights()
+	}
+}
+
+// handle applies one key.
+func (a *app) handle(k key) {
+	switch k.code {
+	case keyCtrlC:
+		a.quit = true
+		return
+	case keyTab:
+		a.focus = (a.focus + 1) % 3
+		return
+	case keyBacktab:
+		a.focus = (a.focus + 2) % 3
+		return
+	case keyCtrlT:
+		a.semantic = !a.semantic
+		a.searchDue = a.searching()
+		return
+	}
+	switch a.focus {
+	case paneSearch:
+		a.handleSearch(k)
+	case paneList:
+		a.handleList(k)
+	case paneConversation:
+		a.handleConversation(k)
+	}
+}
+
+func (a *app) handleSearch(k key) {
+	switch k.code {
+	case keyRune:
+		a.query += string(k.r)
+	case keyBackspace:
+		if a.query != "" {
+			_, size := utf8.DecodeLastRuneInString(a.query)
+			a.query = a.query[:len(a.query)-size]
+		}
+	case keyCtrlU, keyEsc:
+		a.query = ""
+	case keyDown, keyEnter:
+		a.focus = paneList
+		return
+	default:
+		return
+	}
+	a.searchDue = a.searching()
+	if !a.searching() {
+		a.results, a.list, a.listTop, a.status = nil, 0, 0, ""
+	}
+}
+
+func (a *app) handleList(k key) {
+	switch {
+	case k.code == keyUp || k.r == 'k':
+		if a.list > 0 {
+			a.list--
+		} else {
+			a.focus = paneSearch
+		}
+	case k.code == keyDown || k.r == 'j':
+		if a.list < a.listLen()-1 {
+			a.list++
+		}
+	case k.code == keyPgUp:
+		a.list = max(a.list-a.height, 0)
+	case k.code == keyPgDn:
+		a.list = max(min(a.list+a.height, a.listLen()-1), 0)
+	case k.code == keyEnter || k.code == keyRight || k.r == 'o':
+		a.open()
+	case k.code == keyEsc || k.r == '/':
+		a.focus = paneSearch
+	case k.r == 't':
+		a.showTools = !a.showTools
+	case k.r == 'q':
+		a.quit = true
+	}
+}
+
+func (a *app) handleConversation(k key) {
+	switch {
+	case k.code == keyUp || k.r == 'k':
+		if a.entry > 0 {
+			a.entry--
+			a.follow = true
+		}
+	case k.code == keyDown || k.r == 'j':
+		if a.entry < len(a.entries)-1 {
+			a.entry++
+			a.follow = true
+		}
+	case k.code == keyPgUp:
+		a.convTop = max(a.convTop-a.height, 0)
+	case k.code == keyPgDn:
+		a.convTop += a.height
+	case k.code == keyHome || k.r == 'g':
+		a.entry, a.follow = 0, true
+	case k.code == keyEnd || k.r == 'G':
+		a.entry, a.follow = max(len(a.entries)-1, 0), true
+	case k.code == keyLeft || k.code == keyEsc:
+		a.focus = paneList
+	case k.r == 't':
+		a.showTools = !a.showTools
+	case k.r == 'y':
+		a.copyEntry()
+	case k.r == '/':
+		a.focus = paneSearch
+	case k.r == 'q':
+		a.quit = true
+	}
+}
+
+// open shows the selected session, at the selected hit if searching.
+func (a *app) open() {
+	var id string
+	var hit *model.SearchResult
+	switch {
+	case a.searching() && a.list < len(a.results):
+		hit = &a.results[a.list]
+		id = hit.SessionID
+	case !a.searching() && a.list < len(a.sessions):
+		id = a.sessions[a.list].SessionID
+	default:
+		return
+	}
+	entries, err := a.src.Timeline(id)
+	if err != nil {
+		a.status = err.Error()
+		return
+	}
+	a.session, a.entries, a.entry, a.convTop, a.follow = id, entries, 0, 0, true
+	if hit != nil {
+		for i, e := range entries {
+			if e.Kind != "tool" && e.Role == hit.Role && e.Content == hit.Content {
+				a.entry = i
+				break
+			}
+		}
+	}
+	a.focus = paneConversation
+	a.status = fmt.Sprintf("session %s · %d entries", id, len(entries))
+}
+
+func (a *app) copyEntry() {
+	if a.entry >= len(a.entries) {
+		return
+	}
+	e := a.entries[a.entry]
+	text := e.Content
+	if e.Kind == "tool" {
+		text = e.ToolInput
+		if e.ToolResponse != "" {
+			text += "\n" + e.ToolResponse
+		}
+	}
+	a.copy(text)
+	a.status = fmt.Sprintf("copied %d characters", utf8.RuneCountInString(text))
+}
+
+// osc52 returns the escape sequence asking the terminal to put text on
+// the clipboard. It works over SSH and needs no clipboard program.
+func osc52(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
+
+// --- Rendering ---
+
+// ANSI styles.
+const (
+	reverse = "\x1b[7m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reset   = "\x1b[0m"
+)
+
+// render draws the screen as height lines of width columns, returning the
+// lines and the column of the cursor in the search box.
+func (a *app) render(width, height int) ([]string, int) {
+	a.height = max(height-3, 1)
+	lines := make([]string, 0, height)
+
+	mode := "text"
+	if a.semantic {
+		mode = "semantic"
+	}
+	prompt := fmt.Sprintf(" clog [%s] > ", mode)
+	lines = append(lines, fit(prompt+a.query, width))
+	cursor := min(utf8.RuneCountInString(prompt+a.query)+1, width)
+	lines = append(lines, dim+strings.Repeat("─", width)+reset)
+
+	listWidth := min(max(width*2/5, 24), width)
+	convWidth := width - listWidth - 1
+	list := a.renderList(listWidth, a.height)
+	var conv []string
+	if convWidth > 0 {
+		conv = a.renderConversation(convWidth, a.height)
+	}
+	for i := 0; i < a.height; i++ {
+		row := list[i]
+		if convWidth > 0 {
+			row += dim + "│" + reset + conv[i]
+		}
+		lines = append(lines, row)
+	}
+
+	status := help
+	if a.status != "" {
+		status = a.status + " · " + help
+	}
+	lines = append(lines, dim+fit(" "+status, width)+reset)
+	return lines, cursor
+}
+
+// renderList draws the sessions or search hits.
+func (a *app) renderList(width, height int) []string {
+	n := a.listLen()
+	if a.list < a.listTop {
+		a.listTop = a.list
+	}
+	if a.list >= a.listTop+height {
+		a.listTop = a.list - height + 1
+	}
+	rows := make([]string, height)
+	for i := range rows {
+		idx := a.listTop + i
+		if idx >= n {
+			rows[i] = strings.Repeat(" ", width)
+			if n == 0 && i == 0 {
+				rows[i] = fit(" "+a.emptyList(), width)
+			}
+			continue
+		}
+		row := fit(" "+a.listRow(idx, width-1), width)
+		if idx == a.list {
+			style := bold
+			if a.focus == paneList {
+				style = reverse
+			}
+			row = style + row + reset
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func (a *app) emptyList() string {
+	switch {
+	case a.searchDue || a.inFlight:
+		return "searching…"
+	case a.searching():
+		return "no hits"
+	}
+	return "no sessions"
+}
+
+func (a *app) listRow(i, width int) string {
+	if a.searching() {
+		r := a.results[i]
+		head := fmt.Sprintf("%s %-9s ", r.Timestamp.Format("01-02 15:04"), r.Role)
+		room := max(width-utf8.RuneCountInString(head), 1)
+		return head + snippet.Excerpt(r.Content, r.MatchStart, r.MatchEnd, a.terms, snippet.Options{Max: room, BestSentence: a.semantic})
+	}
+	s := a.sessions[i]
+	prompt := strings.Join(strings.Fields(s.FirstPrompt), " ")
+	if prompt == "" {
+		prompt = s.SessionID
+	}
+	return fmt.Sprintf("%s %3d msgs  %s", s.StartedAt.Format("01-02 15:04"), s.MessageCount, prompt)
+}
+
+// renderConversation draws the open session, scrolled to keep the
+// selected entry in view.
+func (a *app) renderConversation(width, height int) []string {
+	var lines []string
+	start := make([]int, len(a.entries))
+	for i, e := range a.entries {
+		start[i] = len(lines)
+		lines = append(lines, a.entryLines(e, i == a.entry, width)...)
+	}
+	if len(a.entries) == 0 {
+		lines = []string{fit(" pick a session or a hit and press enter", width)}
+	}
+
+	if a.follow && a.entry < len(start) {
+		if s := start[a.entry]; s < a.convTop || s >= a.convTop+height {
+			a.convTop = max(s-height/3, 0)
+		}
+		a.follow = false
+	}
+	a.convTop = max(min(a.convTop, len(lines)-1), 0)
+
+	rows := make([]string, height)
+	for i := range rows {
+		if j := a.convTop + i; j < len(lines) {
+			rows[i] = lines[j]
+		} else {
+			rows[i] = strings.Repeat(" ", width)
+		}
+	}
+	return rows
+}
+
+// entryLines renders one message or tool call, each line width wide.
+func (a *app) entryLines(e model.TimelineEntry, selected bool, width int) []string {
+	ts := e.Timestamp.Format("2006-01-02 15:04:05")
+	style := bold
+	if selected && a.focus == paneConversation {
+		style = reverse
+	}
+	var out []string
+	if e.Kind == "tool" {
+		head := fmt.Sprintf(" ▸ %s  %s  %s", e.ToolName, ts, compact(e.ToolInput))
+		if selected {
+			head = style + fit(head, width) + reset
+		} else {
+			head = dim + fit(head, width) + reset
+		}
+		out = append(out, head)
+		if a.showTools {
+			body := wrap(compact(e.ToolInput), width-4)
+			if e.ToolResponse != "" {
+				resp := wrap("→ "+compact(e.ToolResponse), width-4)
+				if len(resp) > maxToolLines {
+					resp = append(resp[:maxToolLines], "…")
+				}
+				body = append(body, resp...)
+			}
+			for _, l := range body {
+				out = append(out, dim+fit("    "+l, width)+reset)
+			}
+		}
+		return out
+	}
+
+	head := fmt.Sprintf(" %s  %s", e.Role, ts)
+	if selected {
+		head = style + fit(head, width) + reset
+	} else {
+		head = bold + fit(head, width) + reset
+	}
+	out = append(out, head)
+	for _, l := range wrap(e.Content, width-2) {
+		out = append(out, fit("  "+l, width))
+	}
+	return append(out, strings.Repeat(" ", width))
+}
+
+// compact shortens JSON to one line, leaving other text as is.
+func compact(s string) string {
+	var v interface{}
+	if json.Unmarshal([]byte(s), &v) == nil {
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// wrap breaks text into lines of at most width runes, at spaces where
+// possible.
+func wrap(text string, width int) []string {
+	width = max(width, 1)
+	var out []string
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n") {
+		para = strings.TrimRight(para, " \r")
+		for utf8.RuneCountInString(para) > width {
+			cut := runeOffset(para, width)
+			if sp := strings.LastIndexByte(para[:cut], ' '); sp > 0 {
+				cut = sp
+			}
+			out = append(out, para[:cut])
+			para = strings.TrimLeft(para[cut:], " ")
+		}
+		out = append(out, para)
+	}
+	return out
+}
+
+// fit pads or cuts s to exactly width runes, blanking control characters
+// so that logged text can't move the cursor.
+func fit(s string, width int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		if width < 1 {
+			return ""
+		}
+		return s[:runeOffset(s, width-1)] + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// runeOffset returns the byte offset of the nth rune of s.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"clog/internal/model"
+)
+
+// fakeSource serves fixed sessions and records searches.
+type fakeSource struct {
+	searches []string
+}
+
+var t0 = time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+
+func (f *fakeSource) Sessions() ([]model.SessionStats, error) {
+	return []model.SessionStats{
+		{SessionID: "sess-1", StartedAt: t0, MessageCount: 2, FirstPrompt: "please fix the flaky auth test"},
+		{SessionID: "sess-2", StartedAt: t0.Add(-time.Hour), MessageCount: 1, FirstPrompt: "deploy"},
+	}, nil
+}
+
+func (f *fakeSource) Search(query string, semantic bool) ([]model.SearchResult, error) {
+	f.searches = append(f.searches, fmt.Sprintf("%s/%v", query, semantic))
+	return []model.SearchResult{{ID: 2, SessionID: "sess-1", Role: "assistant", Content: "fixed the clock", Timestamp: t0}}, nil
+}
+
+func (f *fakeSource) Timeline(sessionID string) ([]model.TimelineEntry, error) {
+	return []model.TimelineEntry{
+		{Kind: "message", Role: "user", Content: "please fix the flaky auth test", Timestamp: t0},
+		{Kind: "tool", ToolName: "Bash", ToolInput: `{"command": "go test"}`, ToolResponse: `{"stdout":"FAIL"}`, Timestamp: t0},
+		{Kind: "message", Role: "assistant", Content: "fixed the clock", Timestamp: t0},
+	}, nil
+}
+
+func typeKeys(a *app, s string) {
+	for _, k := range decodeKeys([]byte(s)) {
+		a.handle(k)
+	}
+}
+
+// screen renders a and strips styles.
+func screen(a *app, width, height int) []string {
+	lines, _ := a.render(width, height)
+	for i, l := range lines {
+		for _, s := range []string{reverse, bold, dim, reset} {
+			l = strings.ReplaceAll(l, s, "")
+		}
+		lines[i] = l
+	}
+	return lines
+}
+
+func TestApp_WhenTyping_ShouldRequestSearchAndShowHits(t *testing.T) {
+	src := &fakeSource{}
+	a := newApp(src, nil)
+	typeKeys(a, "clock")
+	if !a.searchDue || a.query != "clock" {
+		t.Fatalf("expected a search for %q to be due, got %q due=%v", "clock", a.query, a.searchDue)
+	}
+	results, err := src.Search(a.query, a.semantic)
+	a.setResults("clock", results, err)
+	if got := strings.Join(screen(a, 100, 10), "\n"); !strings.Contains(got, "fixed the clock") {
+		t.Errorf("expected the hit listed, got\n%s", got)
+	}
+
+	a.setResults("clo", nil, nil)
+	if len(a.results) != 1 {
+		t.Errorf("expected stale results to be ignored, got %+v", a.results)
+	}
+	typeKeys(a, "\x7f\x7f\x7f\x7f\x7f")
+	if a.searching() || a.results != nil {
+		t.Errorf("expected clearing the query to show sessions again")
+	}
+}
+
+func TestApp_WhenHitOpened_ShouldSelectItInConversation(t *testing.T) {
+	src := &fakeSource{}
+	a := newApp(src, nil)
+	typeKeys(a, "clock")
+	results, _ := src.Search("clock", false)
+	a.setResults("clock", results, nil)
+
+	typeKeys(a, "\r\r")
+	if a.focus != paneConversation || a.session != "sess-1" {
+		t.Fatalf("expected sess-1 open in the conversation pane, got focus=%v session=%q", a.focus, a.session)
+	}
+	if a.entry != 2 {
+		t.Errorf("expected the hit (entry 2) selected, got %d", a.entry)
+	}
+}
+
+func TestApp_WhenToolsToggled_ShouldShowToolResponses(t *testing.T) {
+	a := newApp(&fakeSource{}, nil)
+	typeKeys(a, "\t\r")
+	if got := strings.Join(screen(a, 120, 20), "\n"); strings.Contains(got, "FAIL") || !strings.Contains(got, `▸ Bash`) {
+		t.Errorf("expected tool calls collapsed, got\n%s", got)
+	}
+	typeKeys(a, "t")
+	if got := strings.Join(screen(a, 120, 20), "\n"); !strings.Contains(got, `→ {"stdout":"FAIL"}`) {
+		t.Errorf("expected tool response shown, got\n%s", got)
+	}
+}
+
+func TestApp_WhenCopying_ShouldCopySelectedMessage(t *testing.T) {
+	var copied string
+	a := newApp(&fakeSource{}, func(s string) { copied = s })
+	typeKeys(a, "\t\rjjy")
+	if copied != "fixed the clock" {
+		t.Errorf("expected the selected message copied, got %q", copied)
+	}
+	typeKeys(a, "ky")
+	if copied != "{\"command\": \"go test\"}\n{\"stdout\":\"FAIL\"}" {
+		t.Errorf("expected the tool call copied, got %q", copied)
+	}
+}
+
+func TestApp_Render_ShouldFitScreenExactly(t *testing.T) {
+	a := newApp(&fakeSource{}, nil)
+	a.entries, _ = (&fakeSource{}).Timeline("sess-1")
+	a.entries[0].Content = strings.Repeat("日本語 ", 50) + "\x1b[2J"
+	for _, size := range [][2]int{{80, 24}, {40, 8}} {
+		lines := screen(a, size[0], size[1])
+		if len(lines) != size[1] {
+			t.Fatalf("%v: expected %d lines, got %d", size, size[1], len(lines))
+		}
+		for i, l := range lines {
+			if n := utf8.RuneCountInString(l); n != size[0] {
+				t.Errorf("%v: line %d is %d runes wide: %q", size, i, n, l)
+			}
+			if strings.Contains(l, "\x1b") {
+				t.Errorf("%v: line %d leaks an escape sequence: %q", size, i, l)
+			}
+		}
+	}
+}
+
+func TestWrap_WhenLineLong_ShouldBreakAtSpaces(t *testing.T) {
+	got := wrap("the quick brown fox\njumps", 10)
+	want := []string{"the quick", "brown fox", "jumps"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,110 @@
+// Package watch follows the transcripts of running sessions, so their
+// messages become searchable as they are written instead of only when a
+// Stop hook fires.
+//
+// Watching is by polling: transcript sizes are cheap to stat, and the
+// database, which DuckDB lets only one process write at a time, is
+// opened only when a transcript has grown.
+package watch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Target is a transcript to follow.
+type Target struct {
+	SessionID string
+	Path      string
+}
+
+// Watcher hands transcripts that have grown to Harvest.
+type Watcher struct {
+	// List returns the transcripts to follow.
+	List func() ([]Target, error)
+	// Harvest reads new lines from the given transcripts. Targets are
+	// offered again on the next poll if it fails.
+	Harvest func(targets []Target) error
+	// Interval is the time between polls; Refresh is the time between
+	// calls to List.
+	Interval, Refresh time.Duration
+	// Logf reports errors that don't stop watching.
+	Logf func(format string, args ...interface{})
+
+	targets []Target
+	listed  time.Time
+	// sizes holds each transcript's size when it was last harvested.
+	sizes map[string]int64
+}
+
+// Run polls until ctx is cancelled. A harvest in progress is finished
+// before it returns.
+func (w *Watcher) Run(ctx context.Context) error {
+	tick := time.NewTicker(w.Interval)
+	defer tick.Stop()
+	for {
+		w.Poll(time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// Poll harvests every target whose size has changed since it was last
+// harvested, refreshing the target list first if it is due.
+func (w *Watcher) Poll(now time.Time) {
+	if w.listed.IsZero() || now.Sub(w.listed) >= w.Refresh {
+		w.refresh(now)
+	}
+
+	var grown []Target
+	var sizes []int64
+	for _, t := range w.targets {
+		fi, err := os.Stat(t.Path)
+		if err != nil {
+			// Not written yet, or removed.
+			continue
+		}
+		if size, ok := w.sizes[t.Path]; ok && size == fi.Size() {
+			continue
+		}
+		grown = append(grown, t)
+		sizes = append(sizes, fi.Size())
+	}
+	if len(grown) == 0 {
+		return
+	}
+	if err := w.Harvest(grown); err != nil {
+		w.Logf("harvest: %v", err)
+		return
+	}
+	for i, t := range grown {
+		w.sizes[t.Path] = sizes[i]
+	}
+}
+
+// Targets returns the transcripts currently followed.
+func (w *Watcher) Targets() []Target {
+	return w.targets
+}
+
+func (w *Watcher) refresh(now time.Time) {
+	targets, err := w.List()
+	if err != nil {
+		w.Logf("list sessions: %v", err)
+		return
+	}
+	w.targets, w.listed = targets, now
+
+	// Forget sessions that have ended.
+	sizes := make(map[string]int64, len(targets))
+	for _, t := range targets {
+		if size, ok := w.sizes[t.Path]; ok {
+			sizes[t.Path] = size
+		}
+	}
+	w.sizes = sizes
+}
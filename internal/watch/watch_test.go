@@ -0,0 +1,134 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recorder is a Watcher whose calls are recorded.
+type recorder struct {
+	w         *Watcher
+	targets   []Target
+	lists     int
+	harvested [][]string
+	fail      error
+	logs      []string
+}
+
+func newRecorder(targets ...Target) *recorder {
+	r := &recorder{targets: targets}
+	r.w = &Watcher{
+		List: func() ([]Target, error) {
+			r.lists++
+			return r.targets, nil
+		},
+		Harvest: func(ts []Target) error {
+			var ids []string
+			for _, t := range ts {
+				ids = append(ids, t.SessionID)
+			}
+			r.harvested = append(r.harvested, ids)
+			return r.fail
+		},
+		Interval: time.Millisecond,
+		Refresh:  time.Minute,
+		Logf:     func(format string, args ...interface{}) { r.logs = append(r.logs, fmt.Sprintf(format, args...)) },
+	}
+	return r
+}
+
+func appendTo(t *testing.T, path, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString(s)
+}
+
+func TestPoll_WhenTranscriptGrows_ShouldHarvestOnlyIt(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.jsonl"), filepath.Join(dir, "b.jsonl")
+	appendTo(t, a, "{}\n")
+	appendTo(t, b, "{}\n")
+	r := newRecorder(Target{"a", a}, Target{"b", b}, Target{"c", filepath.Join(dir, "missing.jsonl")})
+	now := time.Now()
+
+	r.w.Poll(now)
+	if len(r.harvested) != 1 || len(r.harvested[0]) != 2 {
+		t.Fatalf("expected the first poll to harvest both existing transcripts, got %v", r.harvested)
+	}
+
+	r.w.Poll(now.Add(time.Second))
+	if len(r.harvested) != 1 {
+		t.Fatalf("expected no harvest when nothing changed, got %v", r.harvested)
+	}
+
+	appendTo(t, b, "{}\n")
+	r.w.Poll(now.Add(2 * time.Second))
+	if len(r.harvested) != 2 || fmt.Sprint(r.harvested[1]) != "[b]" {
+		t.Errorf("expected only b harvested, got %v", r.harvested)
+	}
+	if r.lists != 1 {
+		t.Errorf("expected the list cached until the refresh interval, listed %d times", r.lists)
+	}
+}
+
+func TestPoll_WhenHarvestFails_ShouldRetryNextPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.jsonl")
+	appendTo(t, path, "{}\n")
+	r := newRecorder(Target{"a", path})
+	r.fail = errors.New("database is locked")
+	now := time.Now()
+
+	r.w.Poll(now)
+	r.fail = nil
+	r.w.Poll(now.Add(time.Second))
+	if len(r.harvested) != 2 {
+		t.Errorf("expected the failed harvest retried, got %v", r.harvested)
+	}
+	if len(r.logs) != 1 {
+		t.Errorf("expected the failure logged, got %v", r.logs)
+	}
+}
+
+func TestPoll_WhenRefreshDue_ShouldPickUpNewSessions(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.jsonl"), filepath.Join(dir, "b.jsonl")
+	appendTo(t, a, "{}\n")
+	appendTo(t, b, "{}\n")
+	r := newRecorder(Target{"a", a})
+	now := time.Now()
+
+	r.w.Poll(now)
+	r.targets = []Target{{"b", b}}
+	r.w.Poll(now.Add(2 * time.Minute))
+	if len(r.harvested) != 2 || fmt.Sprint(r.harvested[1]) != "[b]" {
+		t.Errorf("expected the new session harvested after refresh, got %v", r.harvested)
+	}
+	if len(r.w.Targets()) != 1 || r.w.Targets()[0].SessionID != "b" {
+		t.Errorf("expected the ended session dropped, got %v", r.w.Targets())
+	}
+}
+
+func TestRun_WhenCancelled_ShouldReturn(t *testing.T) {
+	r := newRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.w.Run(ctx) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return after cancellation")
+	}
+}
@@ -0,0 +1,372 @@
+// Package web serves a project's history over HTTP: a JSON API over the
+// store and a small browser UI embedded in the binary.
+//
+// API responses are clog's versioned JSON envelopes (see the output
+// package); errors are {"error": "..."} with a 4xx or 5xx status.
+//
+//	GET /api/sessions?limit=&sort=&since=&until=
+//	GET /api/sessions/{id}/timeline?limit=&offset=
+//	GET /api/sessions/{id}/summary
+//	GET /api/messages/{id}/context?n=
+//	GET /api/search?q=&mode=text|semantic&limit=&since=&until=
+//	GET /api/tools?q=&limit=&since=&until=
+//	GET /api/summaries?limit=&since=&until=
+package web
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"clog/internal/config"
+	"clog/internal/embedding"
+	"clog/internal/model"
+	"clog/internal/output"
+	"clog/internal/search"
+	"clog/internal/store"
+)
+
+//go:embed ui
+var ui embed.FS
+
+// Server answers API and UI requests for one project.
+type Server struct {
+	dir string
+	// open opens the store for a project directory.
+	open func(dir string) (store.Store, error)
+	// embedder returns the provider used by semantic search.
+	embedder func() (embedding.Embedder, error)
+}
+
+// New returns a server for the project in dir, opening its database
+// read-only for each request so hooks can keep writing between them.
+func New(dir string) *Server {
+	return &Server{dir: dir, open: openProject, embedder: embedding.NewFromEnv}
+}
+
+func openProject(dir string) (store.Store, error) {
+	cfg := config.Default()
+	return store.OpenProjectReadOnly(cfg.Backend, cfg.DBPath(dir))
+}
+
+// ListenAddr binds addr to localhost when it names only a port, so the
+// history is not exposed to the network unless a host is given.
+func ListenAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("listen address %q: %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Handler routes the API and the UI for a server listening on addr.
+// Requests naming another host are refused; see allowedHosts.
+func (s *Server) Handler(addr string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/sessions", s.api(listSessions))
+	mux.Handle("GET /api/sessions/{id}/timeline", s.api(sessionTimeline))
+	mux.Handle("GET /api/sessions/{id}/summary", s.api(sessionSummary))
+	mux.Handle("GET /api/messages/{id}/context", s.api(messageContext))
+	mux.Handle("GET /api/search", s.api(s.searchMessages))
+	mux.Handle("GET /api/tools", s.api(searchTools))
+	mux.Handle("GET /api/summaries", s.api(listSummaries))
+	mux.Handle("GET /api/", http.NotFoundHandler())
+
+	static, _ := fs.Sub(ui, "ui")
+	mux.Handle("GET /", http.FileServerFS(static))
+	return checkHost(addr, mux)
+}
+
+// checkHost refuses requests whose Host header isn't one addr answers to.
+// Without this, a web page could rebind its own domain name to 127.0.0.1
+// and read the history through the browser.
+func checkHost(addr string, next http.Handler) http.Handler {
+	allowed := allowedHosts(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r.Host) {
+			writeError(w, &statusError{http.StatusForbidden, fmt.Errorf("unexpected host %q", r.Host)})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHosts returns whether a Host header names addr: localhost,
+// 127.0.0.1 or [::1] with addr's port, or addr's host itself. When addr
+// listens on every interface any IP address is accepted too, since a
+// rebinding attack needs a domain name.
+func allowedHosts(addr string) func(host string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return func(string) bool { return false }
+	}
+	names := map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true}
+	ip := net.ParseIP(host)
+	anyIP := host == "" || ip != nil && ip.IsUnspecified()
+	if !anyIP {
+		names[strings.ToLower(host)] = true
+	}
+	return func(hostport string) bool {
+		h, p, err := net.SplitHostPort(hostport)
+		if err != nil || p != port {
+			return false
+		}
+		h = strings.ToLower(h)
+		return names[h] || anyIP && net.ParseIP(h) != nil
+	}
+}
+
+// statusError carries the HTTP status for an API failure.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func badRequest(err error) error { return &statusError{http.StatusBadRequest, err} }
+
+func notFound(err error) error { return &statusError{http.StatusNotFound, err} }
+
+// api adapts a query over the store to an HTTP handler.
+func (s *Server) api(fn func(st store.Store, r *http.Request) (output.Set, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st, err := s.open(s.dir)
+		if err != nil {
+			writeError(w, &statusError{http.StatusServiceUnavailable, err})
+			return
+		}
+		defer st.Close()
+		set, err := fn(st, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		output.Write(w, output.JSON, set)
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var se *statusError
+	if errors.As(err, &se) {
+		status = se.status
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// intParam reads a non-negative integer query parameter.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, badRequest(fmt.Errorf("%s must be a non-negative integer", name))
+	}
+	return n, nil
+}
+
+func timeFilter(r *http.Request) (*model.TimeFilter, error) {
+	tf, err := model.ParseTimeFilter(r.URL.Query().Get("since"), r.URL.Query().Get("until"))
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	return tf, nil
+}
+
+// query parses the q parameter in the query language.
+func query(r *http.Request) (*search.Query, error) {
+	q, err := search.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	return q, nil
+}
+
+func listSessions(st store.Store, r *http.Request) (output.Set, error) {
+	limit, err := intParam(r, "limit", 50)
+	if err != nil {
+		return output.Set{}, err
+	}
+	tf, err := timeFilter(r)
+	if err != nil {
+		return output.Set{}, err
+	}
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = "start"
+	}
+	if err := store.CheckSessionSort(sortBy); err != nil {
+		return output.Set{}, badRequest(err)
+	}
+	results, err := st.ListSessions(limit, sortBy, tf)
+	if err != nil {
+		return output.Set{}, fmt.Errorf("list sessions: %w", err)
+	}
+	return output.Sessions(results), nil
+}
+
+func sessionTimeline(st store.Store, r *http.Request) (output.Set, error) {
+	limit, err := intParam(r, "limit", 200)
+	if err != nil {
+		return output.Set{}, err
+	}
+	offset, err := intParam(r, "offset", 0)
+	if err != nil {
+		return output.Set{}, err
+	}
+	sess, err := st.ResolveSession(r.PathValue("id"))
+	if err != nil {
+		return output.Set{}, notFound(err)
+	}
+	entries, err := st.SessionTimeline(sess.ID, limit, offset)
+	if err != nil {
+		return output.Set{}, fmt.Errorf("session timeline: %w", err)
+	}
+	return output.Timeline(entries), nil
+}
+
+// sessionSummary returns the session's summary as a list of zero or one.
+func sessionSummary(st store.Store, r *http.Request) (output.Set, error) {
+	sess, err := st.ResolveSession(r.PathValue("id"))
+	if err != nil {
+		return output.Set{}, notFound(err)
+	}
+	sum, err := st.SessionSummary(sess.ID)
+	if err != nil {
+		return output.Set{}, fmt.Errorf("session summary: %w", err)
+	}
+	var results []model.SummaryResult
+	if sum != nil {
+		results = append(results, *sum)
+	}
+	return output.Summaries(results), nil
+}
+
+func messageContext(st store.Store, r *http.Request) (output.Set, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return output.Set{}, badRequest(fmt.Errorf("message id must be an integer"))
+	}
+	n, err := intParam(r, "n", 5)
+	if err != nil {
+		return output.Set{}, err
+	}
+	thread, err := st.MessageContext(id, n)
+	if err != nil {
+		return output.Set{}, fmt.Errorf("context for message %d: %w", id, err)
+	}
+	if len(thread) == 0 {
+		return output.Set{}, notFound(fmt.Errorf("no message %d", id))
+	}
+	return output.Context(thread), nil
+}
+
+// searchMessages runs a text search, or a semantic one when mode=semantic.
+func (s *Server) searchMessages(st store.Store, r *http.Request) (output.Set, error) {
+	q, err := query(r)
+	if err != nil {
+		return output.Set{}, err
+	}
+	limit, err := intParam(r, "limit", 20)
+	if err != nil {
+		return output.Set{}, err
+	}
+	tf, err := timeFilter(r)
+	if err != nil {
+		return output.Set{}, err
+	}
+
+	var results []model.SearchResult
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "text":
+		results, err = st.TextSearch(q, limit, false, tf, nil)
+		if err != nil {
+			return output.Set{}, fmt.Errorf("text search: %w", err)
+		}
+	case "semantic":
+		results, err = s.semantic(st, q, limit, tf)
+		if err != nil {
+			return output.Set{}, err
+		}
+	default:
+		return output.Set{}, badRequest(fmt.Errorf("unknown mode %q (want text or semantic)", mode))
+	}
+	return output.Messages(results), nil
+}
+
+func (s *Server) semantic(st store.Store, q *search.Query, limit int, tf *model.TimeFilter) ([]model.SearchResult, error) {
+	text := q.Text()
+	if text == "" {
+		return nil, badRequest(fmt.Errorf("semantic search needs some plain words to embed; only filters were given"))
+	}
+	emb, err := s.embedder()
+	if err != nil {
+		return nil, &statusError{http.StatusServiceUnavailable, err}
+	}
+	if err := store.CheckEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension()); err != nil {
+		return nil, &statusError{http.StatusConflict, err}
+	}
+	if err := st.LoadVSS(); err != nil {
+		return nil, fmt.Errorf("load vss: %w", err)
+	}
+	vecs, err := emb.Embed([]string{text})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	results, err := st.SearchSimilar(vecs[0], q.Filters(), limit, false, tf, nil)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return results, nil
+}
+
+func searchTools(st store.Store, r *http.Request) (output.Set, error) {
+	q, err := query(r)
+	if err != nil {
+		return output.Set{}, err
+	}
+	limit, err := intParam(r, "limit", 20)
+	if err != nil {
+		return output.Set{}, err
+	}
+	tf, err := timeFilter(r)
+	if err != nil {
+		return output.Set{}, err
+	}
+	results, err := st.ToolSearch(q, limit, tf, nil)
+	if err != nil {
+		return output.Set{}, fmt.Errorf("tool search: %w", err)
+	}
+	return output.ToolCalls(results), nil
+}
+
+func listSummaries(st store.Store, r *http.Request) (output.Set, error) {
+	limit, err := intParam(r, "limit", 20)
+	if err != nil {
+		return output.Set{}, err
+	}
+	tf, err := timeFilter(r)
+	if err != nil {
+		return output.Set{}, err
+	}
+	results, err := st.ListSummaries(limit, tf)
+	if err != nil {
+		return output.Set{}, fmt.Errorf("list summaries: %w", err)
+	}
+	return output.Summaries(results), nil
+}
@@ -0,0 +1,215 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"clog/internal/model"
+	"clog/internal/store"
+)
+
+// newTestServer serves a seeded SQLite store.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.sqlite")
+	st, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	if err := st.InitCoreSchema(); err != nil {
+		t.Fatalf("init core schema: %v", err)
+	}
+	now := time.Now()
+	st.UpsertSession(model.Session{ID: "sess-1", CWD: "/proj", CreatedAt: now})
+	st.SaveHarvestedMessages([]model.Message{
+		{SessionID: "sess-1", UUID: "m1", Role: "user", Content: "please fix the flaky auth test", Timestamp: now},
+		{SessionID: "sess-1", UUID: "m2", ParentUUID: "m1", Role: "assistant", Content: "fixed it", Timestamp: now.Add(time.Second)},
+	}, "/t.jsonl", 1)
+	st.SaveSummary("sess-1", "Fixed a flaky auth test.", "test")
+
+	s := New("/proj")
+	s.open = func(string) (store.Store, error) { return store.OpenSQLite(path) }
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config.Handler = s.Handler(srv.Listener.Addr().String())
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get fetches path and decodes its JSON body.
+func get(t *testing.T, srv *httptest.Server, path string) (int, map[string]interface{}) {
+	t.Helper()
+	res, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer res.Body.Close()
+	var body map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("GET %s: decode: %v", path, err)
+	}
+	return res.StatusCode, body
+}
+
+func TestHandler_API_ShouldReturnVersionedEnvelopes(t *testing.T) {
+	srv := newTestServer(t)
+	cases := []struct {
+		path, kind string
+		count      float64
+	}{
+		{"/api/sessions", "session", 1},
+		{"/api/sessions/sess/timeline", "timeline_entry", 2},
+		{"/api/sessions/sess/summary", "summary", 1},
+		{"/api/search?q=flaky+role:user", "message", 1},
+		{"/api/search?q=" + "%22fixed+it%22", "message", 1},
+		{"/api/tools?q=*", "tool_call", 0},
+		{"/api/summaries", "summary", 1},
+		{"/api/messages/2/context?n=1", "context_message", 2},
+	}
+	for _, c := range cases {
+		status, body := get(t, srv, c.path)
+		if status != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d %v", c.path, status, body)
+			continue
+		}
+		if body["version"] != float64(1) || body["kind"] != c.kind || body["count"] != c.count {
+			t.Errorf("%s: expected %d %s results, got %v", c.path, int(c.count), c.kind, body)
+		}
+	}
+}
+
+func TestHandler_WhenRequestBad_ShouldReturnJSONError(t *testing.T) {
+	srv := newTestServer(t)
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{"/api/search?q=colour:red", http.StatusBadRequest},
+		{"/api/search?q=x&mode=fuzzy", http.StatusBadRequest},
+		{"/api/sessions?limit=-1", http.StatusBadRequest},
+		{"/api/sessions?since=yesterdayish", http.StatusBadRequest},
+		{"/api/sessions?sort=bogus", http.StatusBadRequest},
+		{"/api/sessions/nope/timeline", http.StatusNotFound},
+		{"/api/messages/999/context", http.StatusNotFound},
+		{"/api/nothing", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		res, err := http.Get(srv.URL + c.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", c.path, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != c.status {
+			t.Errorf("%s: expected %d, got %d %s", c.path, c.status, res.StatusCode, body)
+		}
+		if c.path != "/api/nothing" && !strings.Contains(string(body), `"error"`) {
+			t.Errorf("%s: expected a JSON error, got %s", c.path, body)
+		}
+	}
+}
+
+func TestHandler_WhenStoreFails_ShouldReturnServerError(t *testing.T) {
+	// A store without its schema fails every query.
+	path := filepath.Join(t.TempDir(), "events.sqlite")
+	s := New("/proj")
+	s.open = func(string) (store.Store, error) { return store.OpenSQLite(path) }
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config.Handler = s.Handler(srv.Listener.Addr().String())
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	for _, path := range []string{"/api/sessions", "/api/search?q=auth", "/api/tools?q=auth"} {
+		status, body := get(t, srv, path)
+		if status != http.StatusInternalServerError || body["error"] == nil {
+			t.Errorf("%s: expected a 500 JSON error, got %d %v", path, status, body)
+		}
+	}
+}
+
+func TestHandler_UI_ShouldBeSelfContained(t *testing.T) {
+	srv := newTestServer(t)
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || len(body) == 0 {
+			t.Errorf("%s: expected the embedded file, got %d", path, res.StatusCode)
+		}
+		if strings.Contains(string(body), "http://") || strings.Contains(string(body), "https://") {
+			t.Errorf("%s: references an external asset", path)
+		}
+	}
+}
+
+func TestHandler_WhenHostUnexpected_ShouldRefuse(t *testing.T) {
+	srv := newTestServer(t)
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	cases := map[string]int{
+		"localhost:" + port:        http.StatusOK,
+		"127.0.0.1:" + port:        http.StatusOK,
+		"evil.example:" + port:     http.StatusForbidden,
+		"localhost:1":              http.StatusForbidden,
+		"localhost":                http.StatusForbidden,
+		"127.0.0.1.nip.io:" + port: http.StatusForbidden,
+	}
+	for host, want := range cases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/sessions", nil)
+		req.Host = host
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET with Host %q: %v", host, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Errorf("Host %q: expected %d, got %d", host, want, res.StatusCode)
+		}
+	}
+}
+
+func TestAllowedHosts_WhenListeningOnAllInterfaces_ShouldAcceptIPsOnly(t *testing.T) {
+	allowed := allowedHosts("0.0.0.0:7357")
+	for host, want := range map[string]bool{
+		"192.168.1.5:7357": true,
+		"[::1]:7357":       true,
+		"localhost:7357":   true,
+		"myhost.lan:7357":  false,
+		"192.168.1.5:80":   false,
+	} {
+		if got := allowed(host); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if !allowedHosts("myhost.lan:7357")("MyHost.lan:7357") {
+		t.Error("expected the named listen host accepted")
+	}
+}
+
+func TestListenAddr_WhenOnlyPortGiven_ShouldBindLocalhost(t *testing.T) {
+	cases := map[string]string{
+		":8080":        "127.0.0.1:8080",
+		"0.0.0.0:8080": "0.0.0.0:8080",
+		"localhost:80": "localhost:80",
+		"[::1]:9000":   "[::1]:9000",
+	}
+	for in, want := range cases {
+		got, err := ListenAddr(in)
+		if err != nil || got != want {
+			t.Errorf("ListenAddr(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ListenAddr("8080"); err == nil {
+		t.Error("expected an error for a bare port number")
+	}
+}
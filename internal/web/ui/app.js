@@ -0,0 +1,235 @@
+// clog web UI: sessions as chat threads, search, and hits in context.
+// Routes live in the URL hash:
+//   #/session/ID    a session's timeline
+//   #/search?q=&mode=   search results
+//   #/context/ID    the thread around message ID
+"use strict";
+
+const PAGE = 200;
+const view = document.getElementById("view");
+const sessionList = document.getElementById("sessions");
+const form = document.getElementById("search");
+
+// api fetches an API path and returns its envelope, throwing its error.
+async function api(path) {
+  const res = await fetch(path);
+  const body = await res.json().catch(() => ({ error: res.statusText }));
+  if (!res.ok) throw new Error(body.error || res.statusText);
+  return body;
+}
+
+// el builds an element; children may be strings, nodes or arrays of them.
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === "class") node.className = v;
+    else if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children.flat()) {
+    if (c != null) node.append(c);
+  }
+  return node;
+}
+
+function show(...nodes) {
+  view.replaceChildren(...nodes);
+  view.scrollTop = 0;
+}
+
+function showError(err) {
+  show(el("p", { class: "error" }, String(err.message || err)));
+}
+
+function when(ts) {
+  if (!ts) return "";
+  return new Date(ts).toLocaleString(undefined, { dateStyle: "medium", timeStyle: "short" });
+}
+
+// terms picks the words and phrases of a query worth highlighting.
+function terms(q) {
+  const out = [];
+  for (const m of q.matchAll(/(-?)(?:"([^"]*)"|(\S+))/g)) {
+    const value = m[2] !== undefined ? m[2] : m[3];
+    if (m[1] || !value || value === "*" || (m[3] && /^[a-z]+:/.test(value))) continue;
+    out.push(value);
+  }
+  return out;
+}
+
+// highlight returns text with case-insensitive matches of words in <mark>.
+function highlight(text, words) {
+  if (!words.length) return [text];
+  const escaped = words.map((w) => w.replace(/[.*+?^${}()|[\]\\]/g, "\\$&"));
+  const re = new RegExp("(" + escaped.join("|") + ")", "gi");
+  return text.split(re).map((part, i) => (i % 2 ? el("mark", {}, part) : part));
+}
+
+function json(value) {
+  if (value == null || value === "") return "";
+  return typeof value === "string" ? value : JSON.stringify(value, null, 2);
+}
+
+// --- Sessions ---
+
+async function loadSessions() {
+  try {
+    const { results } = await api("/api/sessions?limit=200");
+    if (!results.length) {
+      sessionList.replaceChildren(el("p", { class: "muted" }, "No sessions yet."));
+      return;
+    }
+    sessionList.replaceChildren(...results.map((s) =>
+      el("a", { class: "session", href: "#/session/" + s.session_id, "data-id": s.session_id },
+        el("div", { class: "meta" }, when(s.started_at), " · ", s.message_count + " messages · ", s.tool_count + " tools"),
+        el("div", { class: "prompt" }, s.first_prompt || s.session_id))));
+  } catch (err) {
+    sessionList.replaceChildren(el("p", { class: "error" }, err.message));
+  }
+}
+
+function markActive(id) {
+  for (const a of sessionList.querySelectorAll(".session")) {
+    a.classList.toggle("active", a.dataset.id === id);
+  }
+}
+
+function entry(e) {
+  if (e.type === "tool") {
+    return el("details", { class: "tool" },
+      el("summary", {}, e.tool_name, " · ", when(e.timestamp)),
+      el("pre", {}, json(e.tool_input)),
+      e.tool_response ? el("pre", {}, "→ ", json(e.tool_response)) : null);
+  }
+  return el("div", { class: "msg " + e.role },
+    el("div", { class: "meta" }, e.role, " · ", when(e.timestamp)),
+    e.content);
+}
+
+async function showSession(id) {
+  markActive(id);
+  show(el("p", { class: "muted" }, "Loading…"));
+  try {
+    const [summary, timeline] = await Promise.all([
+      api(`/api/sessions/${encodeURIComponent(id)}/summary`),
+      api(`/api/sessions/${encodeURIComponent(id)}/timeline?limit=${PAGE}`),
+    ]);
+    const thread = el("div", { class: "thread" },
+      el("h2", {}, "Session ", id.slice(0, 8)),
+      summary.results.length ? el("p", { class: "summary" }, summary.results[0].summary) : null,
+      timeline.results.map(entry));
+    show(thread);
+    if (timeline.count === PAGE) moreButton(thread, id, PAGE);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+// moreButton appends the next page of a timeline when clicked.
+function moreButton(thread, id, offset) {
+  const button = el("button", {
+    onclick: async () => {
+      button.remove();
+      try {
+        const page = await api(`/api/sessions/${encodeURIComponent(id)}/timeline?limit=${PAGE}&offset=${offset}`);
+        thread.append(...page.results.map(entry));
+        if (page.count === PAGE) moreButton(thread, id, offset + PAGE);
+      } catch (err) {
+        thread.append(el("p", { class: "error" }, err.message));
+      }
+    },
+  }, "Load more");
+  thread.append(button);
+}
+
+// --- Search ---
+
+async function showSearch(q, mode) {
+  form.q.value = q;
+  form.mode.value = mode;
+  markActive(null);
+  if (!q.trim()) {
+    show(el("p", { class: "muted" }, "Type a query."));
+    return;
+  }
+  show(el("p", { class: "muted" }, "Searching…"));
+  const words = terms(q);
+  try {
+    if (mode === "tools") {
+      const { results } = await api("/api/tools?limit=50&q=" + encodeURIComponent(q));
+      show(el("div", { class: "thread" },
+        el("p", { class: "muted" }, results.length + " tool calls"),
+        results.map((r) => el("div", { class: "result" },
+          el("div", { class: "meta" }, r.tool_name, " · ", when(r.timestamp), " · session ",
+            el("a", { href: "#/session/" + r.session_id }, r.session_id.slice(0, 8))),
+          el("pre", { class: "content" }, highlight(json(r.tool_input), words))))));
+      return;
+    }
+    const { results } = await api(`/api/search?limit=50&mode=${mode}&q=${encodeURIComponent(q)}`);
+    show(el("div", { class: "thread" },
+      el("p", { class: "muted" }, results.length + " messages"),
+      results.map((r) => el("div", { class: "result" },
+        el("div", { class: "meta" }, r.role, " · ", when(r.timestamp),
+          r.score ? " · score " + r.score.toFixed(3) : ""),
+        el("div", { class: "content" }, highlight(excerpt(r), words)),
+        el("div", { class: "actions" },
+          el("a", { href: "#/context/" + r.id }, "In context"),
+          el("a", { href: "#/session/" + r.session_id }, "Whole session"))))));
+  } catch (err) {
+    showError(err);
+  }
+}
+
+// excerpt shortens a hit to the part that matched, if known.
+function excerpt(r) {
+  let text = r.content;
+  if (r.match_end > r.match_start) {
+    const bytes = new TextEncoder().encode(r.content);
+    text = new TextDecoder().decode(bytes.slice(r.match_start, r.match_end));
+  }
+  return text.length > 600 ? text.slice(0, 600) + "…" : text;
+}
+
+form.addEventListener("submit", (ev) => {
+  ev.preventDefault();
+  const params = new URLSearchParams({ q: form.q.value, mode: form.mode.value });
+  location.hash = "#/search?" + params;
+});
+
+// --- Context ---
+
+async function showContext(id) {
+  show(el("p", { class: "muted" }, "Loading…"));
+  try {
+    const { results } = await api(`/api/messages/${encodeURIComponent(id)}/context?n=5`);
+    const thread = el("div", { class: "thread" },
+      el("p", { class: "muted" }, "Thread around the hit"),
+      results.map((m) => el("div", { class: "msg " + m.role + (m.hit ? " hit" : "") },
+        el("div", { class: "meta" }, m.role, " · ", when(m.timestamp)),
+        m.content)));
+    show(thread);
+    const hit = thread.querySelector(".hit");
+    if (hit) hit.scrollIntoView({ block: "center" });
+  } catch (err) {
+    showError(err);
+  }
+}
+
+// --- Routing ---
+
+function route() {
+  const hash = location.hash.slice(1);
+  const [path, query] = hash.split("?");
+  const parts = path.split("/").filter(Boolean);
+  if (parts[0] === "session" && parts[1]) return showSession(decodeURIComponent(parts[1]));
+  if (parts[0] === "context" && parts[1]) return showContext(parts[1]);
+  if (parts[0] === "search") {
+    const params = new URLSearchParams(query);
+    return showSearch(params.get("q") || "", params.get("mode") || "text");
+  }
+  markActive(null);
+  show(el("p", { class: "muted" }, "Pick a session or search."));
+}
+
+window.addEventListener("hashchange", route);
+loadSessions().then(route);
@@ -1,37 +1,85 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
-
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"clog/internal/bgjob"
+	"clog/internal/chunk"
 	"clog/internal/config"
+	"clog/internal/embedcache"
 	"clog/internal/embedding"
+	"clog/internal/mcp"
 	"clog/internal/model"
+	"clog/internal/output"
+	"clog/internal/rerank"
+	"clog/internal/search"
+	"clog/internal/snippet"
 	"clog/internal/store"
 	"clog/internal/summary"
 	"clog/internal/transcript"
+	"clog/internal/tui"
+	"clog/internal/watch"
+	"clog/internal/web"
 )
 
-const embeddingBatchSize = 64
-
 func main() {
+	// Long-running servers are subcommands rather than flags.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "clog: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ingest := flag.Bool("i", false, "")
 	ingestLong := flag.Bool("ingest", false, "read a Claude Code hook event from stdin")
 	embed := flag.Bool("e", false, "")
 	embedLong := flag.Bool("embed", false, "embed unembedded messages")
+	watchMode := flag.Bool("watch", false, "harvest transcripts of running sessions as they grow")
+	interval := flag.Duration("interval", 2*time.Second, "how often --watch checks transcripts")
+	reembed := flag.Bool("reembed", false, "drop stored embeddings and embed everything again (use with -e)")
 	search := flag.String("s", "", "")
 	searchLong := flag.String("search", "", "semantic search query")
+	searchSessions := flag.String("search-sessions", "", "rank sessions by summary similarity to a query")
 	text := flag.String("t", "", "")
-	textLong := flag.String("text-search", "", "case-insensitive text search pattern")
+	textLong := flag.String("text-search", "", "text search query")
 	commands := flag.String("c", "", "")
-	commandsLong := flag.String("commands", "", "search tool call events by tool name")
+	commandsLong := flag.String("commands", "", "search tool call events")
 	verbose := flag.Bool("v", false, "")
 	verboseLong := flag.Bool("verbose", false, "show tool responses (use with -c)")
 	changelog := flag.Bool("changelog", false, "list session summaries")
+	session := flag.String("session", "", "print the session whose id starts with PREFIX, or restrict -s/-t/-c to it")
+	sessions := flag.Bool("sessions", false, "list sessions with statistics")
+	sortBy := flag.String("sort", "start", "sort sessions by start, end, duration, messages or tools")
+	stats := flag.Bool("stats", false, "print a usage analytics report")
+	bash := flag.String("bash", "", "search Bash commands and their output")
+	failed := flag.Bool("failed", false, "only failing Bash commands (use with --bash)")
+	file := flag.String("file", "", "list sessions and operations that touched a file or directory")
+	sqlQuery := flag.String("sql", "", "run a read-only SQL query against the project store")
+	format := flag.String("format", "table", "output format: table, json, ndjson, markdown, tsv or csv")
+	offset := flag.Int("offset", 0, "skip this many entries (use with --session)")
 	n := flag.Int("n", 0, "max results or messages")
+	group := flag.Bool("group", false, "show the best hit per session with a count of the others (use with -s, -t)")
+	diversity := flag.Float64("diversity", 0, "MMR trade-off from 0 (relevance only) to 1 (novelty only) (use with -s, -t)")
+	contextN := flag.Int("context", 0, "show N thread messages before and after each hit (use with -s, -t)")
+	role := flag.String("role", "", "only messages from user or assistant (use with -s, -t)")
+	modelName := flag.String("model", "", "only messages from models matching this substring (use with -s, -t)")
+	agent := flag.String("agent", "", "only subagent activity, by agent id or type (use with -s, -t, -c)")
 	since := flag.String("since", "", "filter results after this time (e.g. 1h, 2d, 1w, 2024-01-15)")
 	until := flag.String("until", "", "filter results before this time (e.g. 1h, 2d, 1w, 2024-01-15)")
 
@@ -39,28 +87,80 @@ func main() {
 		fmt.Fprintf(os.Stderr, `clog - Claude Code session logger with search
 
 usage: clog [options]
+       clog mcp
+       clog serve [--http ADDR]
+       clog tui
+
+commands:
+  mcp                        serve search as Model Context Protocol tools on stdio
+  serve [--http ADDR]        serve a JSON API and web UI (default 127.0.0.1:7357;
+                             a bare :PORT binds to localhost)
+  tui                        browse and search sessions interactively
 
 options:
   -i, --ingest               read a Claude Code hook event from stdin
-  -e, --embed                embed unembedded messages
-  -s, --search QUERY         semantic search over embeddings
-  -t, --text-search PATTERN  case-insensitive substring search
-  -c, --commands PATTERN     search tool call events (use "*" for all)
+  -e, --embed                embed unembedded messages and session summaries
+  --reembed                  with -e, drop stored embeddings first and embed
+                             everything with the configured model
+  --watch                    harvest running sessions' transcripts as they grow,
+                             until interrupted; with -e, embed new messages too
+  --interval DUR             how often --watch checks transcripts (default 2s)
+  -s, --search QUERY         semantic search over embeddings; fields and phrases
+                             in QUERY pre-filter, plain words are embedded
+  --search-sessions QUERY    semantic search over session summaries
+  -t, --text-search QUERY    search message text
+  -c, --commands QUERY       search tool calls by name, input and output ("*" for all)
   --changelog                list session summaries
-  -v, --verbose              show tool responses (use with -c)
+  --session PREFIX           print a full session (messages and tool calls);
+                             with -s, -t or -c, search only that session
+  --offset NUM               skip NUM entries (use with --session)
+  --sessions                 list sessions with statistics (no LLM needed)
+  --sort KEY                 sort --sessions by start, end, duration, messages, tools
+  --stats                    usage analytics: sessions, prompts, tools, busiest hours
+  --bash PATTERN             search Bash commands and output (use "*" for all)
+  --failed                   only non-zero exits, errors and interruptions (implies --bash "*")
+  --file PATH                history of reads/edits/writes to a file or directory
+  --sql QUERY                read-only SQL over the store; views: prompts,
+                             bash_commands, file_edits, tool_executions
+  --format FMT               output format for any query mode: table (default), json,
+                             ndjson, markdown, tsv or csv; JSON is versioned (see README)
+  -v, --verbose              show tool responses (use with -c, --bash, --session)
   -n NUM                     max results/messages (default: varies per mode)
-  --since TIME               filter results after TIME (use with -s, -t, -c, --changelog)
-  --until TIME               filter results before TIME (use with -s, -t, -c, --changelog)
+  --context N                show N thread messages around each hit (use with -s, -t)
+  --group                    collapse hits per session, counting the rest (use with -s, -t)
+  --diversity F              rerank -s/-t hits by MMR; 0 = relevance only, 1 = novelty only
+  --role ROLE                only user or assistant messages (use with -s, -t)
+  --model NAME               only messages from models matching NAME (use with -s, -t)
+  --agent ID|TYPE            only subagent activity, e.g. --agent Explore (use with -s, -t, -c)
+  --since TIME               filter results after TIME (search and listing modes)
+  --until TIME               filter results before TIME (search and listing modes)
+
+  QUERY terms must all match: words and "quoted phrases" match text,
+  field:value restricts one attribute, and -term excludes. Fields:
+  role:user|assistant, session:PREFIX, model:NAME, agent:ID|TYPE,
+  tool:NAME, file:PATH, after:TIME, before:TIME. For example:
+    clog -t 'role:user tool:Bash "exact phrase" -vendor after:2w file:store.go'
 
   TIME can be a relative duration (30m, 2h, 1d, 1w) or a timestamp
   (2024-01-15, 2024-01-15T14:30, or full RFC3339).
 
 environment:
-  OLLAMA_EMBED_MODEL   local Ollama model (checked first)
+  CLOG_BACKEND         storage backend: duckdb (default) or sqlite
+  CLOG_AUTO_EMBED      embed new messages in the background after each harvest;
+                       1 for a 2m time cap per job, or a duration (e.g. 10m)
+  CLOG_EMBED_CACHE_MB  size of the embedding cache shared by all projects
+                       (default 512; 0 turns it off)
+  CLOG_EMBED_RPM       cap embedding requests per minute, retries included
+  CLOG_EMBED_ENDPOINT  any OpenAI-compatible embeddings URL (checked first);
+                       see the README for CLOG_EMBED_MODEL, CLOG_EMBED_API_KEY
+                       and the header and api-version settings
+  OLLAMA_EMBED_MODEL   local Ollama model
   OLLAMA_HOST          Ollama address (usually http://localhost:11434)
   OLLAMA_CHAT_MODEL    Ollama model for session summaries (e.g. llama3.2)
   VOYAGE_API_KEY       Voyage AI API key
   OPENAI_API_KEY       OpenAI API key
+                       (with none of these set, a built-in offline embedder
+                       is used)
 `)
 	}
 
@@ -86,13 +186,52 @@ environment:
 		*verbose = true
 	}
 
+	if *failed && *bash == "" {
+		*bash = "*"
+	}
+
 	tf, err := model.ParseTimeFilter(*since, *until)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "clog: %v\n", err)
 		os.Exit(2)
 	}
 
+	outFormat, err := output.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clog: %v\n", err)
+		os.Exit(2)
+	}
+
+	// --session selects a session to print on its own, and narrows a search otherwise.
+	searching := *search != "" || *text != "" || *commands != ""
+	filterSession := ""
+	if searching {
+		filterSession, *session = *session, ""
+	}
+	filter, err := model.NewFilter(*role, filterSession, *modelName, *agent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clog: %v\n", err)
+		os.Exit(2)
+	}
+	if filter != nil && !searching {
+		fmt.Fprintln(os.Stderr, "clog: --role, --model and --agent apply only to -s, -t and -c")
+		os.Exit(2)
+	}
+
+	// With --watch, -e embeds what is harvested rather than being a mode.
+	watchEmbed := *watchMode && *embed
+	if watchEmbed {
+		*embed = false
+	}
+	if *reembed && !*embed {
+		fmt.Fprintln(os.Stderr, "clog: --reembed applies only to -e")
+		os.Exit(2)
+	}
+
 	mode := 0
+	if *watchMode {
+		mode++
+	}
 	if *ingest {
 		mode++
 	}
@@ -102,6 +241,9 @@ environment:
 	if *search != "" {
 		mode++
 	}
+	if *searchSessions != "" {
+		mode++
+	}
 	if *text != "" {
 		mode++
 	}
@@ -111,13 +253,31 @@ environment:
 	if *changelog {
 		mode++
 	}
+	if *session != "" {
+		mode++
+	}
+	if *sessions {
+		mode++
+	}
+	if *stats {
+		mode++
+	}
+	if *sqlQuery != "" {
+		mode++
+	}
+	if *file != "" {
+		mode++
+	}
+	if *bash != "" {
+		mode++
+	}
 
 	if mode == 0 {
 		flag.Usage()
 		os.Exit(2)
 	}
 	if mode > 1 {
-		fmt.Fprintln(os.Stderr, "clog: specify only one of -i, -e, -s, -t, -c, --changelog")
+		fmt.Fprintln(os.Stderr, "clog: specify only one of -i, -e, --watch, -s, -t, -c, --changelog, --session, --sessions, --stats, --sql, --file, --bash, --search-sessions")
 		os.Exit(2)
 	}
 
@@ -127,31 +287,62 @@ environment:
 			fmt.Fprintf(os.Stderr, "clog: %v\n", err)
 		}
 		os.Exit(0) // never block Claude
+	case *watchMode:
+		err = runWatch(*interval, watchEmbed)
 	case *embed:
 		if *n == 0 {
 			*n = 10000
 		}
-		err = runEmbed(*n)
+		err = runEmbed(*n, *reembed)
 	case *search != "":
 		if *n == 0 {
 			*n = 10
 		}
-		err = runSearch(*search, *n, tf)
+		err = runSearch(*search, *n, *contextN, *group, rerank.Options{Diversity: *diversity}, tf, filter, outFormat)
+	case *searchSessions != "":
+		if *n == 0 {
+			*n = 10
+		}
+		err = runSearchSessions(*searchSessions, *n, tf, outFormat)
 	case *text != "":
 		if *n == 0 {
 			*n = 20
 		}
-		err = runTextSearch(*text, *n, tf)
+		err = runTextSearch(*text, *n, *contextN, *group, rerank.Options{Diversity: *diversity}, tf, filter, outFormat)
 	case *commands != "":
 		if *n == 0 {
 			*n = 20
 		}
-		err = runToolSearch(*commands, *n, *verbose, tf)
+		err = runToolSearch(*commands, *n, *verbose, tf, filter, outFormat)
 	case *changelog:
 		if *n == 0 {
 			*n = 20
 		}
-		err = runChangelog(*n, tf)
+		err = runChangelog(*n, tf, outFormat)
+	case *session != "":
+		if *n == 0 {
+			*n = 50
+		}
+		err = runSession(*session, *n, *offset, *verbose, outFormat)
+	case *sessions:
+		if *n == 0 {
+			*n = 20
+		}
+		err = runSessions(*n, *sortBy, tf, outFormat)
+	case *stats:
+		err = runStats(outFormat, tf)
+	case *sqlQuery != "":
+		err = runSQL(*sqlQuery, outFormat, *n)
+	case *file != "":
+		if *n == 0 {
+			*n = 50
+		}
+		err = runFile(*file, *n, tf, outFormat)
+	case *bash != "":
+		if *n == 0 {
+			*n = 20
+		}
+		err = runBash(*bash, *failed, *n, *verbose, tf, outFormat)
 	}
 
 	if err != nil {
@@ -160,6 +351,77 @@ environment:
 	}
 }
 
+// runCommand runs a subcommand with its own arguments.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "mcp":
+		if len(args) > 0 {
+			return fmt.Errorf("mcp takes no arguments")
+		}
+		return runMCP()
+	case "serve":
+		return runServe(args)
+	case "embed-job":
+		return runEmbedJob()
+	case "tui":
+		if len(args) > 0 {
+			return fmt.Errorf("tui takes no arguments")
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get cwd: %w", err)
+		}
+		return tui.Run(cwd)
+	}
+	return fmt.Errorf("unknown command %q (want mcp, serve or tui)", name)
+}
+
+// --- MCP server mode ---
+
+// runMCP serves the Model Context Protocol on stdin and stdout for the
+// project in the current directory, or the client's root.
+func runMCP() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+	return mcp.New(cwd).Serve(os.Stdin, os.Stdout)
+}
+
+// --- HTTP server mode ---
+
+// runServe serves the JSON API and web UI for the project in the current
+// directory until interrupted.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("http", "127.0.0.1:7357", "address to listen on; a bare :PORT binds to localhost")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("serve: unexpected argument %q", fs.Arg(0))
+	}
+	listen, err := web.ListenAddr(*addr)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "clog: serving %s on http://%s\n", cwd, ln.Addr())
+	// Keep the host as given, which may be a name; take the port from the
+	// listener in case it was 0.
+	host, _, _ := net.SplitHostPort(listen)
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return http.Serve(ln, web.New(cwd).Handler(net.JoinHostPort(host, port)))
+}
+
 // --- Hook mode (stdin, always exits 0) ---
 
 func runHook() error {
@@ -180,7 +442,20 @@ func runHook() error {
 		return fmt.Errorf("create log dir: %w", err)
 	}
 
-	st, err := store.Open(dbPath)
+	// Deferred before the store is closed, so this runs after it: a DuckDB
+	// file can't be opened by the job while the hook still has it.
+	harvested := 0
+	defer func() {
+		// The offline embedder is never started implicitly: its vectors
+		// would block a provider configured later until --reembed.
+		if harvested > 0 && cfg.AutoEmbed > 0 && embedding.Configured() {
+			if err := bgjob.Start(parsed.Session.CWD, cfg.EmbedLogPath(parsed.Session.CWD), "embed-job"); err != nil {
+				fmt.Fprintf(os.Stderr, "clog: start embed job: %v\n", err)
+			}
+		}
+	}()
+
+	st, err := store.Open(cfg.Backend, dbPath)
 	if err != nil {
 		return err
 	}
@@ -198,35 +473,72 @@ func runHook() error {
 		return fmt.Errorf("insert event: %w", err)
 	}
 
+	if activity, ok := model.ParseFileActivity(parsed.Event, parsed.Session.CWD); ok {
+		if err := st.InsertFileActivity(activity); err != nil {
+			return fmt.Errorf("insert file activity: %w", err)
+		}
+	}
+
+	if execution, ok := model.ParseBashExecution(parsed.Event); ok {
+		if err := st.InsertBashExecution(execution); err != nil {
+			return fmt.Errorf("insert bash execution: %w", err)
+		}
+	}
+
 	if parsed.Event.EventType == "Stop" && parsed.Session.TranscriptPath != "" {
-		if err := harvestMessages(st, parsed.Session.ID, parsed.Session.TranscriptPath); err != nil {
+		n, err := harvestMessages(st, parsed.Session.ID, parsed.Session.TranscriptPath, "")
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "clog: harvest: %v\n", err)
 		}
+		harvested += n
 		generateSummary(st, parsed.Session.ID)
 	}
 
+	if parsed.Event.EventType == "SubagentStop" && parsed.Event.AgentTranscriptPath != nil {
+		agentID := ""
+		if parsed.Event.AgentID != nil {
+			agentID = *parsed.Event.AgentID
+		}
+		n, err := harvestMessages(st, parsed.Session.ID, *parsed.Event.AgentTranscriptPath, agentID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "clog: harvest subagent: %v\n", err)
+		}
+		harvested += n
+	}
+
 	return nil
 }
 
-func harvestMessages(st *store.Store, sessionID, transcriptPath string) error {
+// harvestMessages stores new transcript messages and returns how many
+// there were. agentID, if set, is recorded on messages whose lines don't
+// name their subagent.
+func harvestMessages(st store.Store, sessionID, transcriptPath, agentID string) (int, error) {
 	offset, err := st.GetOffset(transcriptPath)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	result, err := transcript.Harvest(sessionID, transcriptPath, offset)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if len(result.Messages) == 0 {
-		return nil
+		return 0, nil
+	}
+	for i := range result.Messages {
+		if result.Messages[i].AgentID == "" {
+			result.Messages[i].AgentID = agentID
+		}
 	}
 
-	return st.SaveHarvestedMessages(result.Messages, transcriptPath, result.NewOffset)
+	if err := st.SaveHarvestedMessages(result.Messages, transcriptPath, result.NewOffset); err != nil {
+		return 0, err
+	}
+	return len(result.Messages), nil
 }
 
-func generateSummary(st *store.Store, sessionID string) {
+func generateSummary(st store.Store, sessionID string) {
 	summarizer, err := summary.NewFromEnv()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "clog: summary provider: %v\n", err)
@@ -258,7 +570,7 @@ func generateSummary(st *store.Store, sessionID string) {
 
 // --- Changelog mode ---
 
-func runChangelog(limit int, tf *model.TimeFilter) error {
+func runChangelog(limit int, tf *model.TimeFilter, format output.Format) error {
 	st, err := openCurrentProjectStore()
 	if err != nil {
 		return err
@@ -274,6 +586,9 @@ func runChangelog(limit int, tf *model.TimeFilter) error {
 	if err != nil {
 		return fmt.Errorf("list summaries: %w", err)
 	}
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.Summaries(results))
+	}
 
 	if len(results) == 0 {
 		fmt.Println("No session summaries found.")
@@ -281,259 +596,1367 @@ func runChangelog(limit int, tf *model.TimeFilter) error {
 	}
 
 	for i, r := range results {
-		sessionPrefix := r.SessionID
-		if len(sessionPrefix) > 8 {
-			sessionPrefix = sessionPrefix[:8]
-		}
 		fmt.Printf("[%d] %s  session=%s\n",
-			i+1, r.GeneratedAt.Format("2006-01-02 15:04"), sessionPrefix)
+			i+1, r.GeneratedAt.Format("2006-01-02 15:04"), shortID(r.SessionID))
 		fmt.Printf("    dir: %s\n", r.CWD)
 		fmt.Printf("    %s\n\n", r.Summary)
 	}
 	return nil
 }
 
-// --- Embed mode ---
+// --- Session listing mode ---
 
-func runEmbed(limit int) error {
+func runSessions(limit int, sortBy string, tf *model.TimeFilter, format output.Format) error {
 	st, err := openCurrentProjectStore()
 	if err != nil {
 		return err
 	}
 	defer st.Close()
 
-	emb, err := embedding.NewFromEnv()
+	results, err := st.ListSessions(limit, sortBy, tf)
 	if err != nil {
-		return err
-	}
-
-	if err := st.InitEmbeddingSchema(emb.Dimension()); err != nil {
-		return fmt.Errorf("init embedding schema: %w", err)
+		return fmt.Errorf("list sessions: %w", err)
 	}
-
-	messages, err := st.UnembeddedMessages(limit)
-	if err != nil {
-		return fmt.Errorf("query un-embedded messages: %w", err)
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.Sessions(results))
 	}
 
-	if len(messages) == 0 {
-		fmt.Println("All messages already embedded.")
+	if len(results) == 0 {
+		fmt.Println("No sessions found.")
 		return nil
 	}
 
-	fmt.Printf("Embedding %d messages...\n", len(messages))
-
-	for i := 0; i < len(messages); i += embeddingBatchSize {
-		end := i + embeddingBatchSize
-		if end > len(messages) {
-			end = len(messages)
-		}
-		batch := messages[i:end]
-
-		texts := make([]string, len(batch))
-		for j, m := range batch {
-			texts[j] = m.Content
+	for i, r := range results {
+		fmt.Printf("[%d] %s → %s (%s)  session=%s\n",
+			i+1, r.StartedAt.Format("2006-01-02 15:04"), r.EndedAt.Format("2006-01-02 15:04"),
+			formatDuration(r.Duration()), shortID(r.SessionID))
+		fmt.Printf("    messages=%d  tools=%d", r.MessageCount, r.ToolCount)
+		if len(r.Models) > 0 {
+			fmt.Printf("  models=%s", strings.Join(r.Models, ","))
 		}
-
-		embeddings, err := emb.Embed(texts)
-		if err != nil {
-			return fmt.Errorf("embed batch %d-%d: %w", i, end, err)
+		if r.EndReason != "" {
+			fmt.Printf("  end=%s", r.EndReason)
 		}
-
-		for j, m := range batch {
-			if err := st.SaveEmbedding(m.ID, embeddings[j]); err != nil {
-				fmt.Fprintf(os.Stderr, "save embedding for message %d: %v\n", m.ID, err)
-			}
+		fmt.Println()
+		if r.FirstPrompt != "" {
+			fmt.Printf("    > %s\n", truncate(firstLine(r.FirstPrompt), 120))
 		}
-
-		fmt.Printf("  %d / %d\n", end, len(messages))
+		fmt.Println()
 	}
-
-	fmt.Println("Done.")
 	return nil
 }
 
-// --- Search mode (semantic) ---
+// --- Usage analytics mode ---
 
-func runSearch(query string, limit int, tf *model.TimeFilter) error {
+func runStats(format output.Format, tf *model.TimeFilter) error {
 	st, err := openCurrentProjectStore()
 	if err != nil {
 		return err
 	}
 	defer st.Close()
 
-	emb, err := embedding.NewFromEnv()
+	stats, err := st.UsageStats(tf)
 	if err != nil {
-		return err
+		return fmt.Errorf("usage stats: %w", err)
 	}
 
-	if err := st.LoadVSS(); err != nil {
-		return fmt.Errorf("load vss: %w", err)
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.UsageStats(stats))
 	}
+	printStats(os.Stdout, stats)
+	return nil
+}
 
-	vecs, err := emb.Embed([]string{query})
-	if err != nil {
-		return fmt.Errorf("embed query: %w", err)
+// printStats renders stats as aligned plain-text tables.
+func printStats(w io.Writer, stats *model.UsageStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "sessions\t%d\n", stats.Sessions)
+	fmt.Fprintf(tw, "prompts\t%d\n", stats.Prompts)
+	fmt.Fprintf(tw, "prompts/session\t%.1f\n", stats.AvgPromptsPerSession)
+	fmt.Fprintf(tw, "avg session length\t%s\n", formatDuration(stats.AvgSessionLength))
+	fmt.Fprintf(tw, "tool calls\t%d\n", stats.ToolCalls)
+	fmt.Fprintf(tw, "tool errors\t%d (%s)\n", stats.ToolErrors, percent(stats.ToolErrors, stats.ToolCalls))
+	tw.Flush()
+
+	if len(stats.SessionsPerDay) > 0 {
+		fmt.Fprintln(w, "\nsessions per day")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for _, d := range stats.SessionsPerDay {
+			fmt.Fprintf(tw, "  %s\t%d\t\n", d.Day, d.Sessions)
+		}
+		tw.Flush()
 	}
 
-	results, err := st.SearchSimilar(vecs[0], limit, tf)
-	if err != nil {
-		return fmt.Errorf("search: %w", err)
+	if len(stats.Tools) > 0 {
+		fmt.Fprintln(w, "\ntools")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "  name\tcalls\terrors\trate\t")
+		for _, t := range stats.Tools {
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%s\t\n", t.Name, t.Calls, t.Errors, percent(t.Errors, t.Calls))
+		}
+		tw.Flush()
 	}
 
-	if len(results) == 0 {
-		fmt.Println("No results. Run 'clog embed' first to generate embeddings.")
-		return nil
+	if len(stats.Hours) > 0 {
+		fmt.Fprintln(w, "\nbusiest hours (UTC)")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for _, h := range stats.Hours {
+			fmt.Fprintf(tw, "  %02d:00\t%d\t\n", h.Hour, h.Events)
+		}
+		tw.Flush()
 	}
+}
 
-	printResults(results)
-	return nil
+// percent formats n/total as a percentage, or "-" when total is zero.
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
 }
 
-// --- Text search mode (ILIKE, no embeddings needed) ---
+// --- File history mode ---
+
+func runFile(path string, limit int, tf *model.TimeFilter, format output.Format) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	path = model.NormalizePath(path, cwd)
 
-func runTextSearch(pattern string, limit int, tf *model.TimeFilter) error {
 	st, err := openCurrentProjectStore()
 	if err != nil {
 		return err
 	}
 	defer st.Close()
 
-	results, err := st.TextSearch(pattern, limit, tf)
+	if err := st.InitCoreSchema(); err != nil {
+		return err
+	}
+
+	results, err := st.FileHistory(path, limit, tf)
 	if err != nil {
-		return fmt.Errorf("text search: %w", err)
+		return err
+	}
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.FileActivity(results))
 	}
 
 	if len(results) == 0 {
-		fmt.Println("No results.")
+		fmt.Printf("No activity recorded for %s.\n", path)
 		return nil
 	}
 
-	printResults(results)
-	return nil
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\tsession=%s\n",
+			r.Timestamp.Format("2006-01-02 15:04"), r.Operation, r.Path, shortID(r.SessionID))
+	}
+	return tw.Flush()
 }
 
-// --- Tool search mode ---
+// --- Bash history mode ---
 
-func runToolSearch(pattern string, limit int, verbose bool, tf *model.TimeFilter) error {
+func runBash(pattern string, failedOnly bool, limit int, verbose bool, tf *model.TimeFilter, format output.Format) error {
 	st, err := openCurrentProjectStore()
 	if err != nil {
 		return err
 	}
 	defer st.Close()
 
-	results, err := st.ToolSearch(pattern, limit, tf)
+	if err := st.InitCoreSchema(); err != nil {
+		return err
+	}
+
+	results, err := st.BashHistory(pattern, failedOnly, limit, tf)
 	if err != nil {
-		return fmt.Errorf("tool search: %w", err)
+		return err
+	}
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.BashExecutions(results))
 	}
 
 	if len(results) == 0 {
-		fmt.Println("No tool call events found.")
+		fmt.Println("No Bash commands found.")
 		return nil
 	}
 
 	for i, r := range results {
-		sessionPrefix := r.SessionID
-		if len(sessionPrefix) > 8 {
-			sessionPrefix = sessionPrefix[:8]
+		fmt.Printf("[%d] %s  %s", i+1, r.Timestamp.Format("2006-01-02 15:04"), bashStatus(r))
+		if d := r.Duration(); d > 0 {
+			fmt.Printf("  %s", d.Round(100*time.Millisecond))
 		}
-		fmt.Printf("[%d] %s  %s  session=%s\n",
-			i+1, r.Timestamp.Format("2006-01-02 15:04"), r.ToolName, sessionPrefix)
-		fmt.Printf("    %s\n", formatToolInput(r.ToolName, r.ToolInput))
-		if verbose && r.ToolResponse != "" {
-			fmt.Printf("    → %s\n", truncate(r.ToolResponse, 200))
+		fmt.Printf("  session=%s\n", shortID(r.SessionID))
+		fmt.Println(indent("$ "+r.Command, "    "))
+
+		if verbose {
+			if r.Stdout != "" {
+				fmt.Println(indent(truncate(r.Stdout, 2000), "    "))
+			}
+			if r.Stderr != "" {
+				fmt.Println(indent(truncate(r.Stderr, 2000), "    ! "))
+			}
+			if r.Error != "" && r.Stderr == "" {
+				fmt.Println(indent(truncate(r.Error, 2000), "    ! "))
+			}
+		} else if msg := bashErrorLine(r); msg != "" {
+			fmt.Printf("    ! %s\n", truncate(msg, 120))
 		}
 		fmt.Println()
 	}
 	return nil
 }
 
-// formatToolInput extracts the most useful field from the tool_input JSON
-// depending on which tool was called.
-func formatToolInput(toolName, rawInput string) string {
-	if rawInput == "" {
-		return "(no input)"
-	}
-
-	var m map[string]json.RawMessage
-	if err := json.Unmarshal([]byte(rawInput), &m); err != nil {
-		return truncate(rawInput, 120)
+// bashErrorLine picks the most telling line of a failed call's output:
+// the first line of stderr, else of the error message past its
+// "Exit code N" header, which bashStatus already shows.
+func bashErrorLine(b model.BashExecution) string {
+	if !b.Failed() {
+		return ""
 	}
-
-	var key string
-	switch toolName {
-	case "Bash":
-		key = "command"
-	case "Read":
-		key = "file_path"
-	case "Edit":
-		key = "file_path"
-	case "Write":
-		key = "file_path"
-	case "Glob":
-		key = "pattern"
-	case "Grep":
-		key = "pattern"
-	case "WebFetch":
-		key = "url"
-	case "WebSearch":
-		key = "query"
-	case "Task":
-		key = "prompt"
+	if msg := strings.TrimSpace(b.Stderr); msg != "" {
+		return firstLine(msg)
 	}
-
-	if key != "" {
-		if v, ok := m[key]; ok {
-			var s string
-			if err := json.Unmarshal(v, &s); err == nil {
-				return truncate(s, 120)
-			}
+	msg := strings.TrimSpace(b.Error)
+	if b.ExitCode != nil && strings.HasPrefix(msg, "Exit code") {
+		if i := strings.IndexByte(msg, '\n'); i >= 0 {
+			msg = strings.TrimSpace(msg[i+1:])
+		} else {
+			msg = ""
 		}
 	}
-
-	return truncate(rawInput, 120)
+	return firstLine(msg)
 }
 
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// bashStatus summarises how a Bash call ended.
+func bashStatus(b model.BashExecution) string {
+	switch {
+	case b.Interrupted:
+		return "interrupted"
+	case b.ExitCode != nil && *b.ExitCode == 0 && b.Error == "":
+		return "ok"
+	case b.ExitCode != nil:
+		return fmt.Sprintf("exit=%d", *b.ExitCode)
+	default:
+		return "error"
 	}
-	return s[:maxLen] + "..."
 }
 
-// --- Helpers ---
+// --- Ad-hoc SQL mode ---
+
+func runSQL(query string, format output.Format, limit int) error {
+	// Rows follow the query's own columns, so --sql JSON is a bare array
+	// rather than a versioned envelope.
+	write := func(w io.Writer, result *model.QueryResult) error {
+		return output.Write(w, format, output.Rows(result))
+	}
+	switch format {
+	case output.Table:
+		write = writeQueryTable
+	case output.JSON:
+		write = writeQueryJSON
+	}
 
-func openCurrentProjectStore() (*store.Store, error) {
+	// The hook's schema init keeps the curated views current, so this
+	// only ever needs read access.
 	cwd, err := os.Getwd()
 	if err != nil {
-		return nil, fmt.Errorf("get cwd: %w", err)
+		return fmt.Errorf("get cwd: %w", err)
 	}
-
 	cfg := config.Default()
-	dbPath := cfg.DBPath(cwd)
+	ro, err := store.OpenProjectReadOnly(cfg.Backend, cfg.DBPath(cwd))
+	if err != nil {
+		return err
+	}
+	defer ro.Close()
 
-	if !fileExists(dbPath) {
-		return nil, fmt.Errorf("no database found at %s — run a Claude Code session in this project first", dbPath)
+	result, err := ro.Query(query, limit)
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
 	}
+	return write(os.Stdout, result)
+}
 
-	return store.Open(dbPath)
+// writeQueryTable prints result as aligned columns, one line per row.
+func writeQueryTable(w io.Writer, result *model.QueryResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(result.Columns, "\t"))
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = truncate(strings.Join(strings.Fields(output.Cell(v)), " "), 80)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "(%d rows)\n", len(result.Rows))
+	return err
 }
 
-func printResults(results []model.SearchResult) {
-	for i, r := range results {
-		content := r.Content
-		if len(content) > 200 {
-			content = content[:200] + "..."
+// writeQueryJSON prints result as an array of objects whose keys keep
+// the query's column order.
+func writeQueryJSON(w io.Writer, result *model.QueryResult) error {
+	var b strings.Builder
+	b.WriteString("[")
+	for r, row := range result.Rows {
+		if r > 0 {
+			b.WriteString(",")
 		}
-		if r.Score > 0 {
-			fmt.Printf("[%d] score=%.4f  %s  [%s]  session=%s\n",
-				i+1, r.Score, r.Timestamp.Format("2006-01-02 15:04"), r.Role, r.SessionID[:8])
-		} else {
-			fmt.Printf("[%d] %s  [%s]  session=%s\n",
-				i+1, r.Timestamp.Format("2006-01-02 15:04"), r.Role, r.SessionID[:8])
+		b.WriteString("\n  {")
+		for i, v := range row {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			key, _ := json.Marshal(result.Columns[i])
+			val, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("encode column %s: %w", result.Columns[i], err)
+			}
+			b.Write(key)
+			b.WriteString(": ")
+			b.Write(val)
 		}
-		fmt.Printf("    %s\n\n", content)
+		b.WriteString("}")
 	}
+	if len(result.Rows) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatDuration renders d compactly, e.g. "45s", "12m", "2h05m".
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// firstLine returns s up to its first newline.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// --- Session viewer mode ---
+
+func runSession(prefix string, limit, offset int, verbose bool, format output.Format) error {
+	st, err := openCurrentProjectStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	sess, err := st.ResolveSession(prefix)
+	if err != nil {
+		return err
+	}
+
+	entries, err := st.SessionTimeline(sess.ID, limit, offset)
+	if err != nil {
+		return fmt.Errorf("session timeline: %w", err)
+	}
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.Timeline(entries))
+	}
+
+	fmt.Printf("session %s  started %s\n", sess.ID, sess.CreatedAt.Format("2006-01-02 15:04"))
+	fmt.Printf("dir: %s\n", sess.CWD)
+	if offset == 0 {
+		sum, err := st.SessionSummary(sess.ID)
+		if err != nil {
+			return fmt.Errorf("session summary: %w", err)
+		}
+		if sum != nil {
+			fmt.Printf("summary: %s\n", sum.Summary)
+		}
+	}
+	fmt.Println()
+
+	if len(entries) == 0 {
+		fmt.Println("No messages.")
+		return nil
+	}
+
+	for _, e := range entries {
+		ts := e.Timestamp.Format("2006-01-02 15:04:05")
+		if e.Kind == "tool" {
+			fmt.Printf("%s  [tool:%s]  %s\n", ts, e.ToolName, formatToolInput(e.ToolName, e.ToolInput))
+			if verbose && e.ToolResponse != "" {
+				fmt.Printf("    → %s\n", truncate(e.ToolResponse, 200))
+			}
+			continue
+		}
+		fmt.Printf("%s  [%s]\n", ts, e.Role)
+		fmt.Printf("%s\n\n", indent(e.Content, "    "))
+	}
+
+	if len(entries) == limit {
+		fmt.Printf("-- more: clog --session %s --offset %d\n", prefix, offset+limit)
+	}
+	return nil
+}
+
+// indent prefixes every line of s.
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// --- Watch mode ---
+
+const (
+	// watchRefresh is how often --watch re-reads the list of active sessions.
+	watchRefresh = 30 * time.Second
+	// watchWindow is how recently a session must have started or fired a
+	// hook to be watched; it drops sessions killed before SessionEnd.
+	watchWindow = 24 * time.Hour
+)
+
+// runWatch harvests the transcripts of the project's running sessions as
+// they grow, embedding new messages too if embed is set, until interrupted.
+// The store is opened only while harvesting and saving embeddings, so
+// hooks can still write. Embedding takes the project's embed lock, and a
+// poll leaves it to the embed job when one holds it.
+func runWatch(interval time.Duration, embed bool) error {
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	cfg := config.Default()
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+	lockPath := cfg.EmbedLockPath(cwd)
+	var emb embedding.Embedder
+	if embed {
+		if emb, err = embedding.NewFromEnv(); err != nil {
+			return err
+		}
+		if cache := openEmbedCache(cfg); cache != nil {
+			defer closeEmbedCache(cache)
+			emb = cache.Wrap(emb)
+		}
+		st, err := openCurrentProjectStore()
+		if err != nil {
+			return err
+		}
+		err = st.InitCoreSchema()
+		if err == nil {
+			err = st.InitEmbeddingSchema(emb.Dimension())
+		}
+		if err != nil {
+			st.Close()
+			return fmt.Errorf("init embedding schema: %w", err)
+		}
+		err = store.ClaimEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension())
+		st.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal kills a watcher stuck finishing a harvest.
+		<-ctx.Done()
+		stop()
+	}()
+
+	// deferred is set when a poll harvested messages but left embedding
+	// them to a running embed job, which may finish before it sees them.
+	deferred := false
+	w := &watch.Watcher{
+		List: func() ([]watch.Target, error) {
+			st, err := openCurrentProjectStore()
+			if err != nil {
+				return nil, err
+			}
+			defer st.Close()
+			sessions, err := st.ActiveSessions(time.Now().Add(-watchWindow))
+			if err != nil {
+				return nil, err
+			}
+			targets := make([]watch.Target, len(sessions))
+			for i, s := range sessions {
+				targets[i] = watch.Target{SessionID: s.ID, Path: s.TranscriptPath}
+			}
+			return targets, nil
+		},
+		Harvest: func(targets []watch.Target) error {
+			total := 0
+			err := withProjectStore(func(st store.Store) error {
+				for _, t := range targets {
+					n, err := harvestMessages(st, t.SessionID, t.Path, "")
+					if err != nil {
+						return fmt.Errorf("session %s: %w", shortID(t.SessionID), err)
+					}
+					if n > 0 {
+						fmt.Printf("%s  session=%s  +%d messages\n", time.Now().Format("15:04:05"), shortID(t.SessionID), n)
+					}
+					total += n
+				}
+				return nil
+			})
+			if err != nil || emb == nil || (total == 0 && !deferred) {
+				return err
+			}
+			release, err := bgjob.Lock(lockPath, embedLockStale(cfg))
+			if errors.Is(err, bgjob.ErrLocked) {
+				// An embed job is running and picks up these messages; if it
+				// finishes first, the next harvest embeds them.
+				deferred = true
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			defer release()
+			deferred = false
+			n, err := embedPending(ctx, emb, lockPath)
+			if n > 0 {
+				fmt.Printf("%s  embedded %d messages\n", time.Now().Format("15:04:05"), n)
+			}
+			return err
+		},
+		Interval: interval,
+		Refresh:  watchRefresh,
+		Logf: func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "clog: "+format+"\n", args...)
+		},
+	}
+
+	fmt.Fprintln(os.Stderr, "clog: watching active sessions; press Ctrl-C to stop")
+	err = w.Run(ctx)
+	fmt.Fprintln(os.Stderr, "clog: stopped watching")
+	return err
+}
+
+// --- Background embedding ---
+
+// runEmbedJob embeds the current project's new messages. Hooks start it
+// detached when CLOG_AUTO_EMBED is set; one job runs per project at a
+// time, and it is stopped at the configured time cap. The store is opened
+// only to read and save each batch, never while the provider is called.
+func runEmbedJob() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+	cfg := config.Default()
+	limit := embedJobCap(cfg)
+
+	lockPath := cfg.EmbedLockPath(cwd)
+	release, err := bgjob.Lock(lockPath, embedLockStale(cfg))
+	if errors.Is(err, bgjob.ErrLocked) {
+		// The running job picks up these messages before it exits.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+	// The cap is checked between batches, so the job returns normally and
+	// closes the cache and store; a batch in flight is finished first.
+	ctx, cancel := context.WithTimeout(context.Background(), limit)
+	defer cancel()
+
+	start := time.Now()
+	emb, err := embedding.NewFromEnv()
+	if err != nil {
+		return err
+	}
+	if cache := openEmbedCache(cfg); cache != nil {
+		defer closeEmbedCache(cache)
+		emb = cache.Wrap(emb)
+	}
+	err = withProjectStore(func(st store.Store) error {
+		// The core schema may predate the embedding_failures table.
+		if err := st.InitCoreSchema(); err != nil {
+			return err
+		}
+		if err := st.InitEmbeddingSchema(emb.Dimension()); err != nil {
+			return fmt.Errorf("init embedding schema: %w", err)
+		}
+		return store.ClaimEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension())
+	})
+	if err != nil {
+		return err
+	}
+
+	total, err := embedPending(ctx, emb, lockPath)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "%s embed job stopped after %v\n", time.Now().Format(time.RFC3339), limit)
+	}
+
+	fmt.Printf("%s embedded %d messages in %v\n", time.Now().Format(time.RFC3339), total, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// embedJobCap is how long one embed job may run.
+func embedJobCap(cfg config.Config) time.Duration {
+	if cfg.AutoEmbed <= 0 {
+		return config.DefaultAutoEmbedCap
+	}
+	return cfg.AutoEmbed
+}
+
+// embedLockStale is how old the embed lock must be before it is taken
+// over. Holders refresh it after each batch, so only a holder that died,
+// or one stuck on a single batch past a job's cap, loses it.
+func embedLockStale(cfg config.Config) time.Duration {
+	return embedJobCap(cfg) + time.Minute
+}
+
+// withProjectStore opens the current project's store for the length of fn.
+func withProjectStore(fn func(st store.Store) error) error {
+	st, err := openCurrentProjectStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	return fn(st)
+}
+
+// embedPending embeds the project's un-embedded messages and returns how
+// many it embedded. It stops early, without error, once ctx is done. The
+// store is opened only to read and save each batch, never while the
+// provider is called, so hooks aren't locked out of a DuckDB store while
+// a request waits or retries. After each batch it refreshes the lock at
+// lockPath, if one is given, so a long run keeps the lock.
+func embedPending(ctx context.Context, emb embedding.Embedder, lockPath string) (int, error) {
+	total := 0
+	fetch, _ := embedding.BatchLimits(emb)
+	for {
+		var messages []model.StoredMessage
+		err := withProjectStore(func(st store.Store) error {
+			var err error
+			messages, err = st.UnembeddedMessages(fetch)
+			return err
+		})
+		if err != nil {
+			return total, fmt.Errorf("query un-embedded messages: %w", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, batch := range messageBatches(emb, messages) {
+			if ctx.Err() != nil {
+				return total, nil
+			}
+			b, err := embedBatch(emb, batch)
+			if err != nil {
+				return total, fmt.Errorf("embed messages: %w", err)
+			}
+			if err := withProjectStore(b.save); err != nil {
+				return total, fmt.Errorf("save messages: %w", err)
+			}
+			// Every message is now embedded or recorded as skipped, so the
+			// next query can't return it again.
+			total += b.embedded()
+			if lockPath != "" {
+				if err := bgjob.Refresh(lockPath); err != nil {
+					return total, err
+				}
+			}
+		}
+	}
+	return total, nil
+}
+
+// --- Embed mode ---
+
+// runEmbed embeds the project's new messages and summaries. With reembed
+// it first drops every stored vector, e.g. after switching models.
+func runEmbed(limit int, reembed bool) error {
+	st, err := openCurrentProjectStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	emb, err := embedding.NewFromEnv()
+	if err != nil {
+		return err
+	}
+	if _, ok := emb.(*embedding.HashEmbedder); ok {
+		fmt.Println("No embedding provider set; using the built-in offline embedder (keyword similarity only).")
+	}
+	if cache := openEmbedCache(config.Default()); cache != nil {
+		defer closeEmbedCache(cache)
+		emb = cache.Wrap(emb)
+	}
+
+	// Summaries live in the core schema, which may predate them.
+	if err := st.InitCoreSchema(); err != nil {
+		return err
+	}
+	if reembed {
+		if err := st.ResetEmbeddings(); err != nil {
+			return err
+		}
+		fmt.Println("Dropped stored embeddings.")
+	}
+	if err := st.InitEmbeddingSchema(emb.Dimension()); err != nil {
+		return fmt.Errorf("init embedding schema: %w", err)
+	}
+	if err := store.ClaimEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension()); err != nil {
+		return err
+	}
+
+	if err := embedMessages(st, emb, limit); err != nil {
+		return err
+	}
+	if err := embedSummaries(st, emb, limit); err != nil {
+		return err
+	}
+
+	fmt.Println("Done.")
+	return nil
+}
+
+// openEmbedCache opens the embedding cache shared by every project. It
+// returns nil, so embedding goes straight to the provider, when the cache
+// is turned off or can't be opened.
+func openEmbedCache(cfg config.Config) *embedcache.Cache {
+	if cfg.EmbedCacheMB <= 0 {
+		return nil
+	}
+	if err := os.MkdirAll(cfg.LogBase, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "clog: embedding cache: %v\n", err)
+		return nil
+	}
+	cache, err := embedcache.Open(cfg.EmbedCachePath(), int64(cfg.EmbedCacheMB)<<20)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clog: %v\n", err)
+		return nil
+	}
+	return cache
+}
+
+// closeEmbedCache reports the cache's hit rate and closes it.
+func closeEmbedCache(cache *embedcache.Cache) {
+	if s, err := cache.Stats(); err == nil && s.Hits+s.Misses > 0 {
+		fmt.Printf("Cache: %d of %d texts reused (%.0f%%; %.0f%% overall), %d entries, %.1f MB\n",
+			s.Hits, s.Hits+s.Misses, 100*s.HitRate(), 100*s.TotalHitRate(), s.Entries, float64(s.Bytes)/(1<<20))
+	}
+	if err := cache.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "clog: %v\n", err)
+	}
+}
+
+func embedMessages(st store.Store, emb embedding.Embedder, limit int) error {
+	messages, err := st.UnembeddedMessages(limit)
+	if err != nil {
+		return fmt.Errorf("query un-embedded messages: %w", err)
+	}
+
+	if len(messages) == 0 {
+		fmt.Println("All messages already embedded.")
+		return nil
+	}
+
+	fmt.Printf("Embedding %d messages...\n", len(messages))
+
+	done := 0
+	for _, batch := range messageBatches(emb, messages) {
+		b, err := embedBatch(emb, batch)
+		if err != nil {
+			return fmt.Errorf("embed messages %d-%d: %w", done, done+len(batch), err)
+		}
+		// Each batch commits atomically, with all chunks of its messages; an
+		// interrupted run resumes from the last committed batch because only
+		// unembedded messages are selected.
+		if err := b.save(st); err != nil {
+			return fmt.Errorf("save messages %d-%d: %w", done, done+len(batch), err)
+		}
+		done += len(batch)
+		fmt.Printf("  %d / %d (%d chunks)\n", done, len(messages), len(b.refs))
+	}
+
+	return nil
+}
+
+// messageBatches groups messages into batches that fit emb's request
+// limits by estimated tokens.
+func messageBatches(emb embedding.Embedder, messages []model.StoredMessage) [][]model.StoredMessage {
+	maxTexts, maxTokens := embedding.BatchLimits(emb)
+	tokens := make([]int, len(messages))
+	for i, m := range messages {
+		tokens[i] = chunk.EstimateTokens(m.Content)
+	}
+	var out [][]model.StoredMessage
+	start := 0
+	for _, end := range batchEnds(tokens, maxTexts, maxTokens) {
+		out = append(out, messages[start:end])
+		start = end
+	}
+	return out
+}
+
+// batchEnds splits items with the given token estimates into consecutive
+// runs of at most maxItems items and maxTokens tokens, returning where
+// each run ends. An item over the token budget on its own gets a run to
+// itself.
+func batchEnds(tokens []int, maxItems, maxTokens int) []int {
+	var ends []int
+	n, sum := 0, 0
+	for i, t := range tokens {
+		if n > 0 && (n == maxItems || sum+t > maxTokens) {
+			ends = append(ends, i)
+			n, sum = 0, 0
+		}
+		n++
+		sum += t
+	}
+	if n > 0 {
+		ends = append(ends, len(tokens))
+	}
+	return ends
+}
+
+// embeddedBatch is a batch of messages embedded and ready to save.
+type embeddedBatch struct {
+	refs []model.MessageChunk
+	vecs [][]float32
+	// skipped are messages the provider rejected or that had no text to
+	// embed.
+	skipped []skippedMessage
+}
+
+// errNoText marks a message whose content splits into no chunks, such as
+// one of whitespace only.
+var errNoText = errors.New("no text to embed")
+
+// embedded counts the messages that got at least one chunk.
+func (b embeddedBatch) embedded() int {
+	ids := map[int64]bool{}
+	for _, r := range b.refs {
+		ids[r.MessageID] = true
+	}
+	return len(ids)
+}
+
+type skippedMessage struct {
+	id  int64
+	err error
+}
+
+// embedBatch embeds messages' chunks. When the provider rejects a batch's
+// input, the batch is halved until the message at fault is found, which is
+// skipped so one bad message doesn't stop the run.
+func embedBatch(emb embedding.Embedder, messages []model.StoredMessage) (embeddedBatch, error) {
+	refs, vecs, empty, err := embedChunks(emb, messages)
+	if err == nil {
+		b := embeddedBatch{refs: refs, vecs: vecs}
+		for _, id := range empty {
+			b.skipped = append(b.skipped, skippedMessage{id, errNoText})
+		}
+		return b, nil
+	}
+	var se *embedding.StatusError
+	if !errors.As(err, &se) || !se.Rejected() {
+		return embeddedBatch{}, err
+	}
+	if len(messages) == 1 {
+		return embeddedBatch{skipped: []skippedMessage{{messages[0].ID, err}}}, nil
+	}
+
+	mid := len(messages) / 2
+	a, err := embedBatch(emb, messages[:mid])
+	if err != nil {
+		return embeddedBatch{}, err
+	}
+	b, err := embedBatch(emb, messages[mid:])
+	if err != nil {
+		return embeddedBatch{}, err
+	}
+	return embeddedBatch{
+		refs:    append(a.refs, b.refs...),
+		vecs:    append(a.vecs, b.vecs...),
+		skipped: append(a.skipped, b.skipped...),
+	}, nil
+}
+
+// save stores the batch's embeddings and records its skipped messages, so
+// later runs don't try them again.
+func (b embeddedBatch) save(st store.Store) error {
+	if err := st.SaveChunkEmbeddings(b.refs, b.vecs); err != nil {
+		return err
+	}
+	for _, m := range b.skipped {
+		if m.err != errNoText {
+			fmt.Fprintf(os.Stderr, "clog: skipped message %d: %v\n", m.id, m.err)
+		}
+		if err := st.RecordEmbeddingFailure(m.id, m.err.Error()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embedChunks splits messages into windows that fit the provider and
+// embeds them. It also returns the messages that split into no windows.
+func embedChunks(emb embedding.Embedder, messages []model.StoredMessage) ([]model.MessageChunk, [][]float32, []int64, error) {
+	opts := chunk.Options{MaxTokens: chunk.DefaultMaxTokens, OverlapTokens: chunk.DefaultOverlapTokens}
+	if limit := embedding.TokenLimit(emb); limit < opts.MaxTokens {
+		opts.MaxTokens = limit
+	}
+
+	var refs []model.MessageChunk
+	var texts []string
+	var empty []int64
+	for _, m := range messages {
+		chunks := chunk.Split(m.Content, opts)
+		if len(chunks) == 0 {
+			empty = append(empty, m.ID)
+		}
+		for _, c := range chunks {
+			refs = append(refs, model.MessageChunk{MessageID: m.ID, Index: c.Index, Start: c.Start, End: c.End})
+			texts = append(texts, c.Text)
+		}
+	}
+
+	embeddings, err := embedInBatches(emb, texts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return refs, embeddings, empty, nil
+}
+
+// embedSummaries embeds new and regenerated session summaries for
+// --search-sessions.
+func embedSummaries(st store.Store, emb embedding.Embedder, limit int) error {
+	summaries, err := st.UnembeddedSummaries(limit)
+	if err != nil {
+		return fmt.Errorf("query un-embedded summaries: %w", err)
+	}
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Embedding %d session summaries...\n", len(summaries))
+
+	opts := chunk.Options{MaxTokens: embedding.TokenLimit(emb)}
+	texts := make([]string, len(summaries))
+	for i, r := range summaries {
+		// Summaries are short; the first window only guards against a
+		// runaway one exceeding the provider's input limit.
+		texts[i] = r.Summary
+		if chunks := chunk.Split(r.Summary, opts); len(chunks) > 0 {
+			texts[i] = chunks[0].Text
+		}
+	}
+
+	embeddings, err := embedInBatches(emb, texts)
+	if err != nil {
+		return fmt.Errorf("embed summaries: %w", err)
+	}
+	if err := st.SaveSummaryEmbeddings(summaries, embeddings); err != nil {
+		return fmt.Errorf("save summary embeddings: %w", err)
+	}
+	return nil
+}
+
+// embedInBatches embeds texts in requests that fit emb's limits.
+func embedInBatches(emb embedding.Embedder, texts []string) ([][]float32, error) {
+	maxTexts, maxTokens := embedding.BatchLimits(emb)
+	tokens := make([]int, len(texts))
+	for i, t := range texts {
+		tokens[i] = chunk.EstimateTokens(t)
+	}
+
+	out := make([][]float32, 0, len(texts))
+	start := 0
+	for _, end := range batchEnds(tokens, maxTexts, maxTokens) {
+		vecs, err := emb.Embed(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != end-start {
+			return nil, fmt.Errorf("provider returned %d embeddings for %d texts", len(vecs), end-start)
+		}
+		out = append(out, vecs...)
+		start = end
+	}
+	return out, nil
+}
+
+// --- Search mode (semantic) ---
+
+func runSearch(query string, limit, contextN int, group bool, rr rerank.Options, tf *model.TimeFilter, filter *model.Filter, format output.Format) error {
+	q, err := search.Parse(query)
+	if err != nil {
+		return err
+	}
+	text := q.Text()
+	if text == "" {
+		return fmt.Errorf("semantic search needs some plain words to embed; only filters were given")
+	}
+
+	st, err := openCurrentProjectStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	emb, err := embedding.NewFromEnv()
+	if err != nil {
+		return err
+	}
+	if err := store.CheckEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension()); err != nil {
+		return err
+	}
+
+	if err := st.LoadVSS(); err != nil {
+		return fmt.Errorf("load vss: %w", err)
+	}
+
+	vecs, err := emb.Embed([]string{text})
+	if err != nil {
+		return fmt.Errorf("embed query: %w", err)
+	}
+
+	results, err := st.SearchSimilar(vecs[0], q.Filters(), rr.Candidates(limit), group, tf, filter)
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	results = rerank.Apply(results, rr, limit)
+	if format != output.Table {
+		return writeResults(st, results, contextN, format)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No results. Run 'clog embed' first to generate embeddings.")
+		return nil
+	}
+
+	style := excerptStyle{terms: q.Highlights(), semantic: true, highlight: colorOutput()}
+	if contextN > 0 {
+		return printResultsWithContext(st, results, contextN, style)
+	}
+	printResults(results, style)
+	return nil
+}
+
+// --- Session search mode (semantic, over summaries) ---
+
+func runSearchSessions(query string, limit int, tf *model.TimeFilter, format output.Format) error {
+	st, err := openCurrentProjectStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	emb, err := embedding.NewFromEnv()
+	if err != nil {
+		return err
+	}
+	if err := store.CheckEmbeddingModel(st, embedding.ModelID(emb), emb.Dimension()); err != nil {
+		return err
+	}
+
+	if err := st.LoadVSS(); err != nil {
+		return fmt.Errorf("load vss: %w", err)
+	}
+
+	vecs, err := emb.Embed([]string{query})
+	if err != nil {
+		return fmt.Errorf("embed query: %w", err)
+	}
+
+	results, err := st.SearchSessions(vecs[0], limit, tf)
+	if err != nil {
+		return fmt.Errorf("search sessions: %w", err)
+	}
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.SessionMatches(results))
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No results. Summaries are embedded by 'clog -e' once generated.")
+		return nil
+	}
+
+	for i, r := range results {
+		fmt.Printf("[%d] score=%.4f  %s  messages=%d  session=%s\n",
+			i+1, r.Score, r.StartedAt.Format("2006-01-02 15:04"), r.MessageCount, shortID(r.SessionID))
+		fmt.Println(indent(r.Summary, "    "))
+		fmt.Println()
+	}
+	return nil
+}
+
+// --- Text search mode (query language, no embeddings needed) ---
+
+func runTextSearch(pattern string, limit, contextN int, group bool, rr rerank.Options, tf *model.TimeFilter, filter *model.Filter, format output.Format) error {
+	q, err := search.Parse(pattern)
+	if err != nil {
+		return err
+	}
+
+	st, err := openCurrentProjectStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	results, err := st.TextSearch(q, rr.Candidates(limit), group, tf, filter)
+	if err != nil {
+		return fmt.Errorf("text search: %w", err)
+	}
+	results = rerank.Apply(results, rr, limit)
+	if format != output.Table {
+		return writeResults(st, results, contextN, format)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No results.")
+		return nil
+	}
+
+	style := excerptStyle{terms: q.Highlights(), highlight: colorOutput()}
+	if contextN > 0 {
+		return printResultsWithContext(st, results, contextN, style)
+	}
+	printResults(results, style)
+	return nil
+}
+
+// --- Tool search mode ---
+
+func runToolSearch(pattern string, limit int, verbose bool, tf *model.TimeFilter, filter *model.Filter, format output.Format) error {
+	q, err := search.Parse(pattern)
+	if err != nil {
+		return err
+	}
+
+	st, err := openCurrentProjectStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	results, err := st.ToolSearch(q, limit, tf, filter)
+	if err != nil {
+		return fmt.Errorf("tool search: %w", err)
+	}
+	if format != output.Table {
+		return output.Write(os.Stdout, format, output.ToolCalls(results))
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No tool call events found.")
+		return nil
+	}
+
+	for i, r := range results {
+		fmt.Printf("[%d] %s  %s  session=%s\n",
+			i+1, r.Timestamp.Format("2006-01-02 15:04"), r.ToolName, shortID(r.SessionID))
+		fmt.Printf("    %s\n", formatToolInput(r.ToolName, r.ToolInput))
+		if verbose && r.ToolResponse != "" {
+			fmt.Printf("    → %s\n", truncate(r.ToolResponse, 200))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// formatToolInput extracts the most useful field from the tool_input JSON
+// depending on which tool was called.
+func formatToolInput(toolName, rawInput string) string {
+	if rawInput == "" {
+		return "(no input)"
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(rawInput), &m); err != nil {
+		return truncate(rawInput, 120)
+	}
+
+	var key string
+	switch toolName {
+	case "Bash":
+		key = "command"
+	case "Read":
+		key = "file_path"
+	case "Edit":
+		key = "file_path"
+	case "Write":
+		key = "file_path"
+	case "Glob":
+		key = "pattern"
+	case "Grep":
+		key = "pattern"
+	case "WebFetch":
+		key = "url"
+	case "WebSearch":
+		key = "query"
+	case "Task":
+		key = "prompt"
+	}
+
+	if key != "" {
+		if v, ok := m[key]; ok {
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				return truncate(s, 120)
+			}
+		}
+	}
+
+	return truncate(rawInput, 120)
+}
+
+// shortID abbreviates a session id to its first 8 characters, enough to
+// tell sessions apart and to pass to --session.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// truncate cuts s to maxLen runes, so multi-byte text is never split.
+func truncate(s string, maxLen int) string {
+	return snippet.Truncate(s, maxLen)
+}
+
+// --- Helpers ---
+
+func openCurrentProjectStore() (store.Store, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("get cwd: %w", err)
+	}
+
+	cfg := config.Default()
+	dbPath := cfg.DBPath(cwd)
+
+	if !fileExists(dbPath) {
+		return nil, fmt.Errorf("no database found at %s — run a Claude Code session in this project first", dbPath)
+	}
+
+	return store.Open(cfg.Backend, dbPath)
+}
+
+func printResults(results []model.SearchResult, style excerptStyle) {
+	for i, r := range results {
+		content := matchExcerpt(r, 200, style)
+		if r.Score > 0 {
+			fmt.Printf("[%d] score=%.4f  %s  [%s]  session=%s%s\n",
+				i+1, r.Score, r.Timestamp.Format("2006-01-02 15:04"), r.Role, shortID(r.SessionID), collapsedNote(r))
+		} else {
+			fmt.Printf("[%d] %s  [%s]  session=%s%s\n",
+				i+1, r.Timestamp.Format("2006-01-02 15:04"), r.Role, shortID(r.SessionID), collapsedNote(r))
+		}
+		fmt.Printf("    %s\n\n", content)
+	}
+}
+
+// collapsedNote mentions the hits folded into r by --group.
+func collapsedNote(r model.SearchResult) string {
+	switch r.Collapsed {
+	case 0:
+		return ""
+	case 1:
+		return "  (+1 more hit in session)"
+	default:
+		return fmt.Sprintf("  (+%d more hits in session)", r.Collapsed)
+	}
+}
+
+// excerptStyle says what a search looked for, so hits can be excerpted
+// around it.
+type excerptStyle struct {
+	// terms are the query's words and phrases.
+	terms []string
+	// semantic centres excerpts on the best sentence of the matched chunk
+	// instead of on literal matches.
+	semantic bool
+	// highlight marks the terms with ANSI colour.
+	highlight bool
+}
+
+// matchExcerpt returns up to max runes of the part of r that matched,
+// e.g. the best chunk of a long message or the places the query's terms
+// occur, with ellipses where it was cut from the surrounding content.
+func matchExcerpt(r model.SearchResult, max int, style excerptStyle) string {
+	return snippet.Excerpt(r.Content, r.MatchStart, r.MatchEnd, style.terms, snippet.Options{
+		Max:          max,
+		Fragments:    3,
+		BestSentence: style.semantic,
+		Highlight:    style.highlight,
+	})
+}
+
+// colorOutput reports whether stdout is a terminal that wants colour.
+func colorOutput() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// writeResults writes search hits in format, or with contextN the threads
+// around them, each message tagged with its hit.
+func writeResults(st store.Store, results []model.SearchResult, contextN int, format output.Format) error {
+	if contextN == 0 {
+		return output.Write(os.Stdout, format, output.Messages(results))
+	}
+	var threads []model.ContextMessage
+	for _, r := range results {
+		thread, err := st.MessageContext(r.ID, contextN)
+		if err != nil {
+			return fmt.Errorf("context for message %d: %w", r.ID, err)
+		}
+		threads = append(threads, thread...)
+	}
+	return output.Write(os.Stdout, format, output.Context(threads))
+}
+
+// printResultsWithContext prints each hit inside its surrounding thread,
+// marking the hit with ">".
+func printResultsWithContext(st store.Store, results []model.SearchResult, contextN int, style excerptStyle) error {
+	for i, r := range results {
+		thread, err := st.MessageContext(r.ID, contextN)
+		if err != nil {
+			return fmt.Errorf("context for message %d: %w", r.ID, err)
+		}
+		if r.Score > 0 {
+			fmt.Printf("[%d] score=%.4f  session=%s%s\n", i+1, r.Score, shortID(r.SessionID), collapsedNote(r))
+		} else {
+			fmt.Printf("[%d] session=%s%s\n", i+1, shortID(r.SessionID), collapsedNote(r))
+		}
+		for _, m := range thread {
+			marker, content := " ", truncate(m.Content, 200)
+			if m.Hit {
+				marker, content = ">", matchExcerpt(r, 200, style)
+			}
+			fmt.Printf("  %s %s  [%s]  %s\n",
+				marker, m.Timestamp.Format("2006-01-02 15:04"), m.Role, content)
+		}
+		fmt.Println()
+	}
+	return nil
 }
 
 func fileExists(path string) bool {
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"clog/internal/embedding"
+	"clog/internal/model"
 )
 
 // --- truncate ---
@@ -163,3 +170,279 @@ func TestFileExists_WhenFileDoesNotExist_ShouldReturnFalse(t *testing.T) {
 		t.Error("expected false for non-existent file")
 	}
 }
+
+// --- indent ---
+
+func TestIndent_WhenGivenMultipleLines_ShouldPrefixEachLine(t *testing.T) {
+	got := indent("a\nb", "  ")
+	if got != "  a\n  b" {
+		t.Errorf("expected each line indented, got %q", got)
+	}
+}
+
+// --- formatDuration ---
+
+func TestFormatDuration_WhenUnderAMinute_ShouldShowSeconds(t *testing.T) {
+	if got := formatDuration(45 * time.Second); got != "45s" {
+		t.Errorf("expected '45s', got %q", got)
+	}
+}
+
+func TestFormatDuration_WhenUnderAnHour_ShouldShowMinutes(t *testing.T) {
+	if got := formatDuration(12*time.Minute + 30*time.Second); got != "12m" {
+		t.Errorf("expected '12m', got %q", got)
+	}
+}
+
+func TestFormatDuration_WhenHoursLong_ShouldShowHoursAndMinutes(t *testing.T) {
+	if got := formatDuration(2*time.Hour + 5*time.Minute); got != "2h05m" {
+		t.Errorf("expected '2h05m', got %q", got)
+	}
+}
+
+// --- firstLine ---
+
+func TestFirstLine_WhenMultiline_ShouldReturnFirstLine(t *testing.T) {
+	if got := firstLine("one\ntwo"); got != "one" {
+		t.Errorf("expected 'one', got %q", got)
+	}
+}
+
+// --- percent ---
+
+func TestPercent_WhenTotalIsZero_ShouldReturnDash(t *testing.T) {
+	if got := percent(0, 0); got != "-" {
+		t.Errorf("expected '-', got %q", got)
+	}
+}
+
+func TestPercent_WhenGivenFraction_ShouldFormatOneDecimal(t *testing.T) {
+	if got := percent(1, 3); got != "33.3%" {
+		t.Errorf("expected '33.3%%', got %q", got)
+	}
+}
+
+// --- query output ---
+
+var testQueryResult = &model.QueryResult{
+	Columns: []string{"tool", "n", "note"},
+	Rows: [][]interface{}{
+		{"Bash", int64(3), nil},
+		{"Edit", int64(1), "a, \"quoted\" note"},
+	},
+}
+
+func TestWriteQueryJSON_ShouldKeepColumnOrder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeQueryJSON(&buf, testQueryResult); err != nil {
+		t.Fatal(err)
+	}
+	want := "[\n  {\"tool\": \"Bash\", \"n\": 3, \"note\": null},\n  {\"tool\": \"Edit\", \"n\": 1, \"note\": \"a, \\\"quoted\\\" note\"}\n]\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWriteQueryJSON_WhenNoRows_ShouldWriteEmptyArray(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeQueryJSON(&buf, &model.QueryResult{Columns: []string{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("expected empty array, got %q", buf.String())
+	}
+}
+
+func TestWriteQueryTable_ShouldAlignColumnsAndCountRows(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeQueryTable(&buf, testQueryResult); err != nil {
+		t.Fatal(err)
+	}
+	want := "tool  n  note\nBash  3  \nEdit  1  a, \"quoted\" note\n(2 rows)\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+// --- bashStatus ---
+
+func TestBashStatus_ShouldDescribeHowTheCallEnded(t *testing.T) {
+	zero, one := 0, 1
+	cases := []struct {
+		exec model.BashExecution
+		want string
+	}{
+		{model.BashExecution{ExitCode: &zero}, "ok"},
+		{model.BashExecution{ExitCode: &one, Error: "Exit code 1"}, "exit=1"},
+		{model.BashExecution{Interrupted: true}, "interrupted"},
+		{model.BashExecution{Error: "permission denied"}, "error"},
+	}
+	for _, c := range cases {
+		if got := bashStatus(c.exec); got != c.want {
+			t.Errorf("expected %q, got %q for %+v", c.want, got, c.exec)
+		}
+	}
+}
+
+// --- bashErrorLine ---
+
+func TestBashErrorLine_WhenOnlyErrorMessage_ShouldSkipExitCodeHeader(t *testing.T) {
+	one := 1
+	got := bashErrorLine(model.BashExecution{ExitCode: &one, Error: "Exit code 1\nvet: bad printf\nmore"})
+	if got != "vet: bad printf" {
+		t.Errorf("expected 'vet: bad printf', got %q", got)
+	}
+}
+
+func TestBashErrorLine_WhenStderrPresent_ShouldPreferStderr(t *testing.T) {
+	one := 1
+	got := bashErrorLine(model.BashExecution{ExitCode: &one, Stderr: "boom\n", Error: "Exit code 1\nother"})
+	if got != "boom" {
+		t.Errorf("expected 'boom', got %q", got)
+	}
+}
+
+func TestBashErrorLine_WhenSucceeded_ShouldBeEmpty(t *testing.T) {
+	zero := 0
+	if got := bashErrorLine(model.BashExecution{ExitCode: &zero, Stderr: "warning"}); got != "" {
+		t.Errorf("expected empty, got %q", got)
+	}
+}
+
+// --- matchExcerpt ---
+
+func TestMatchExcerpt_WhenWholeMessageMatched_ShouldShowItsStart(t *testing.T) {
+	got := matchExcerpt(model.SearchResult{Content: "hello world"}, 5, excerptStyle{})
+	if got != "hello..." {
+		t.Errorf("expected 'hello...', got %q", got)
+	}
+}
+
+func TestMatchExcerpt_WhenChunkMatched_ShouldShowChunkWithEllipses(t *testing.T) {
+	r := model.SearchResult{Content: "intro. the matching part. outro", MatchStart: 7, MatchEnd: 25}
+	if got := matchExcerpt(r, 100, excerptStyle{}); got != "...the matching part...." {
+		t.Errorf("expected chunk with ellipses, got %q", got)
+	}
+}
+
+func TestMatchExcerpt_WhenRangeInvalid_ShouldFallBackToWholeMessage(t *testing.T) {
+	r := model.SearchResult{Content: "short", MatchStart: 2, MatchEnd: 99}
+	if got := matchExcerpt(r, 100, excerptStyle{}); got != "short" {
+		t.Errorf("expected 'short', got %q", got)
+	}
+}
+
+// --- shortID ---
+
+func TestShortID_ShouldKeepEightCharactersAndShortIDsWhole(t *testing.T) {
+	if got := shortID("0123456789abcdef"); got != "01234567" {
+		t.Errorf("expected 01234567, got %q", got)
+	}
+	if got := shortID("abc"); got != "abc" {
+		t.Errorf("expected abc, got %q", got)
+	}
+}
+
+// --- collapsedNote ---
+
+func TestCollapsedNote_ShouldPluraliseHits(t *testing.T) {
+	if got := collapsedNote(model.SearchResult{}); got != "" {
+		t.Errorf("expected empty note, got %q", got)
+	}
+	if got := collapsedNote(model.SearchResult{Collapsed: 1}); got != "  (+1 more hit in session)" {
+		t.Errorf("unexpected singular note %q", got)
+	}
+	if got := collapsedNote(model.SearchResult{Collapsed: 3}); got != "  (+3 more hits in session)" {
+		t.Errorf("unexpected plural note %q", got)
+	}
+}
+
+// --- batchEnds ---
+
+func TestBatchEnds_ShouldRespectItemAndTokenLimits(t *testing.T) {
+	got := batchEnds([]int{10, 10, 10, 50, 100, 5}, 2, 60)
+	want := []int{2, 4, 5, 6}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := batchEnds(nil, 2, 60); len(got) != 0 {
+		t.Errorf("expected no batches, got %v", got)
+	}
+}
+
+// --- embedBatch ---
+
+// rejectingEmbedder refuses any request containing "poison", as a
+// provider does input that is too long.
+type rejectingEmbedder struct{ requests int }
+
+func (e *rejectingEmbedder) Embed(texts []string) ([][]float32, error) {
+	e.requests++
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		if strings.Contains(t, "poison") {
+			return nil, &embedding.StatusError{Provider: "Test", Code: 400, Body: "input too long"}
+		}
+		out[i] = []float32{1}
+	}
+	return out, nil
+}
+
+func (e *rejectingEmbedder) Dimension() int { return 1 }
+
+func TestEmbedBatch_WhenOneMessageRejected_ShouldSkipOnlyIt(t *testing.T) {
+	var messages []model.StoredMessage
+	for i := 1; i <= 5; i++ {
+		messages = append(messages, model.StoredMessage{ID: int64(i), Content: fmt.Sprintf("message %d", i)})
+	}
+	messages[3].Content = "poison"
+
+	b, err := embedBatch(&rejectingEmbedder{}, messages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.skipped) != 1 || b.skipped[0].id != 4 {
+		t.Fatalf("expected message 4 skipped, got %+v", b.skipped)
+	}
+	if len(b.refs) != 4 || len(b.vecs) != 4 {
+		t.Errorf("expected the other 4 messages embedded, got %d refs and %d vectors", len(b.refs), len(b.vecs))
+	}
+}
+
+func TestEmbedBatch_WhenMessageHasNoText_ShouldSkipIt(t *testing.T) {
+	messages := []model.StoredMessage{{ID: 1, Content: "hello"}, {ID: 2, Content: "\n\t\n"}}
+	b, err := embedBatch(embedding.NewHashing(), messages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.skipped) != 1 || b.skipped[0].id != 2 || b.skipped[0].err != errNoText {
+		t.Fatalf("expected message 2 skipped for having no text, got %+v", b.skipped)
+	}
+	if len(b.refs) != 1 || b.refs[0].MessageID != 1 {
+		t.Errorf("expected only message 1 embedded, got %+v", b.refs)
+	}
+}
+
+func TestEmbeddedBatch_ShouldCountMessagesNotChunks(t *testing.T) {
+	b := embeddedBatch{
+		refs:    []model.MessageChunk{{MessageID: 1, Index: 0}, {MessageID: 1, Index: 1}, {MessageID: 2}},
+		skipped: []skippedMessage{{3, errNoText}},
+	}
+	if n := b.embedded(); n != 2 {
+		t.Errorf("expected 2 messages embedded, got %d", n)
+	}
+}
+
+func TestEmbedBatch_WhenProviderFails_ShouldReturnError(t *testing.T) {
+	failing := embedderFunc(func([]string) ([][]float32, error) {
+		return nil, &embedding.StatusError{Provider: "Test", Code: 401, Body: "bad key"}
+	})
+	if _, err := embedBatch(failing, []model.StoredMessage{{ID: 1, Content: "a"}, {ID: 2, Content: "b"}}); err == nil {
+		t.Fatal("expected an auth failure to stop the run rather than skip messages")
+	}
+}
+
+type embedderFunc func([]string) ([][]float32, error)
+
+func (f embedderFunc) Embed(texts []string) ([][]float32, error) { return f(texts) }
+func (f embedderFunc) Dimension() int                            { return 1 }